@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/conformance"
+	"github.com/thomsmits/oftp2-client/oftp2/oftp2test"
+)
+
+var conformanceOptions = struct {
+	Password        string
+	Local           bool
+	ScenarioTimeout time.Duration
+}{}
+
+var conformanceCommand = &cobra.Command{
+	Use:   "conformance",
+	Short: "Check the partner's conformance to RFC 5024",
+	Long: `Runs a battery of scenarios against the partner and reports for each
+section of RFC 5024 whether the partner behaved as required.
+
+The scenarios negotiate sessions with the edge values of buffer size and
+credit, send files in all formats, use restart, buffer compression and secure
+authentication if the partner supports them, hand over the right to speak to
+collect end to end responses and send invalid commands. Each scenario runs in
+its own session.
+
+The scenarios send small test files named CONFORMANCE.* to the partner. Files
+the partner offers are refused, end to end responses are consumed.
+
+With --local the scenarios run against the built-in test partner instead, e.g.
+to check the client itself in CI.
+
+The exit status is 1 if a scenario failed.`,
+	Example: `oftp2 conformance -s oftp.example.com -i O0013000000CLIENT --password SECRET
+oftp2 conformance --local`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runConformance()
+	},
+}
+
+func init() {
+	conformanceCommand.Flags().StringVar(&conformanceOptions.Password, "password", "", "password sent to the partner")
+	conformanceCommand.Flags().BoolVar(&conformanceOptions.Local, "local", false, "run against the built-in test partner")
+	conformanceCommand.Flags().DurationVar(&conformanceOptions.ScenarioTimeout, "scenario-timeout", conformance.DefaultTimeout, "maximum duration of a single scenario")
+}
+
+func runConformance() {
+	target := &conformance.Target{
+		Client:   newClient(),
+		Password: conformanceOptions.Password,
+	}
+
+	if conformanceOptions.Local {
+		partner := &oftp2test.Partner{Restart: true, Compression: true}
+		target.Client.Dialer = partner.Dialer()
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	report := conformance.Run(ctx, target, conformance.Scenarios(), conformanceOptions.ScenarioTimeout)
+
+	err := report.WriteText(os.Stdout)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	if !report.Passed() {
+		os.Exit(exitError)
+	}
+}
@@ -0,0 +1,579 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/logging"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/manager"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/metrics"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/partner"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/retry"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/routing"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/spool"
+)
+
+var daemonConfig string
+var daemonScanInterval time.Duration
+var daemonOnce bool
+var daemonRetry *retryOptions
+var daemonMetricsListen string
+var daemonEERPOverdue time.Duration
+var daemonMaxSessions int
+var daemonMaxPartnerSessions int
+
+var daemonCommand = &cobra.Command{
+	Use:   "daemon",
+	Short: "Exchange files with partners via spool directories",
+	Long: `Runs as gateway between spool directories and the configured partners.
+
+Files dropped into the outbox of a partner are sent on the next scan of the
+outbox. In addition, a session is started with every partner after its poll
+interval to receive the files it has for us. Received files are stored in the
+inbox of the partner, sent files are moved to the archive and files that could
+not be sent to the error directory.
+
+Files failing with a temporary error stay in the outbox and are tried again
+after an exponential back-off. When the daemon gives up on a file, it is moved
+to the error directory with the reason code of the equivalent negative end
+response (NERP).
+
+Sessions with different partners run at the same time, up to --max-sessions
+in total and --max-partner-sessions per partner. The limit of a single partner
+can be changed with "max_sessions" in its configuration. A partner is not
+contacted again while it has as many sessions as allowed.
+
+Files sent are recorded in the ledger of the spool directory until their end
+to end response (EERP or NERP) arrives. With --metrics-listen, metrics of the
+sessions, transfers and pending end to end responses are served on /metrics in
+the Prometheus text format.
+
+As intermediate location, the daemon forwards files whose destination is not
+its own Odette ID according to the routes of the configuration. A forwarded
+file is put into the outbox of the onward partner unchanged. Its end to end
+response is passed back to the partner it came from when the onward partner
+answered, or a NERP if the daemon gives up on the file.
+
+Files for a group destination are broadcast to all members of the group. Local
+applications drop files for a group into the outbox of the group. A single
+end to end response is returned when all members answered: an EERP if all of
+them confirmed the file, otherwise a NERP. A request for a signed EERP cannot
+be fulfilled for a group, such files are answered with a NERP.
+
+The partners are read from a JSON configuration file:
+
+  {
+    "spool": "/var/spool/oftp2",
+    "partners": [
+      {"id": "O0013PARTNER", "host": "oftp.partner.example", "port": 3305,
+       "password": "SECRET", "poll_interval": "15m", "max_sessions": 1}
+    ],
+    "routes": [
+      {"destination": "O0013PARTNERSUB1", "partner": "O0013PARTNER"}
+    ],
+    "groups": [
+      {"id": "O0013ALLPLANTS", "members": ["O0013PARTNERSUB1", "O0013OTHER"]}
+    ]
+  }`,
+	Example: `oftp2 daemon -i O0013LOCAL --config /etc/oftp2/partners.json
+oftp2 daemon -i O0013LOCAL --config partners.json --once
+oftp2 daemon -i O0013LOCAL --config partners.json --metrics-listen :9464`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runDaemon()
+	},
+}
+
+func init() {
+	daemonCommand.Flags().StringVar(&daemonConfig, "config", "", "partner configuration file")
+	daemonCommand.Flags().DurationVar(&daemonScanInterval, "scan-interval", 30*time.Second, "time between two scans of the outboxes")
+	daemonCommand.Flags().BoolVar(&daemonOnce, "once", false, "start one session with every partner and exit")
+	daemonRetry = addRetryFlags(daemonCommand, retry.DefaultPolicy.MaxAttempts-1)
+	daemonCommand.Flags().StringVar(&daemonMetricsListen, "metrics-listen", "", "address to serve metrics on, e.g. :9464")
+	daemonCommand.Flags().IntVar(&daemonMaxSessions, "max-sessions", manager.DefaultMaxSessions, "maximum number of sessions running at the same time")
+	daemonCommand.Flags().IntVar(&daemonMaxPartnerSessions, "max-partner-sessions", manager.DefaultMaxPartnerSessions, "maximum number of sessions with a single partner running at the same time")
+	daemonCommand.Flags().DurationVar(&daemonEERPOverdue, "eerp-overdue", 24*time.Hour, "age after which a missing end to end response is reported as overdue")
+	_ = daemonCommand.MarkFlagRequired("config")
+}
+
+func runDaemon() {
+	config, err := partner.LoadConfig(daemonConfig)
+	if err != nil {
+		print(err.Error() + "\n")
+		os.Exit(1)
+	}
+
+	dirs := make([]string, 0)
+	for _, p := range config.Partners {
+		dirs = append(dirs, p.Directories()...)
+	}
+	for _, g := range config.Groups {
+		dirs = append(dirs, g.Directories()...)
+	}
+
+	for _, dir := range dirs {
+		err = os.MkdirAll(dir, 0755)
+		if err != nil {
+			print(err.Error() + "\n")
+			os.Exit(1)
+		}
+	}
+
+	ledgerPath := ""
+	if config.Spool != "" {
+		ledgerPath = filepath.Join(config.Spool, spool.LedgerFile)
+	}
+
+	ledger, err := spool.OpenLedger(ledgerPath)
+	if err != nil {
+		print(err.Error() + "\n")
+		os.Exit(1)
+	}
+
+	forwardsPath := ""
+	if config.Spool != "" {
+		forwardsPath = filepath.Join(config.Spool, spool.ForwardsFile)
+	}
+
+	forwards, err := spool.OpenForwards(forwardsPath)
+	if err != nil {
+		print(err.Error() + "\n")
+		os.Exit(1)
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	log := cliLogger()
+	nextPoll := make(map[string]time.Time)
+	tracker := retry.NewTracker(daemonRetry.policy())
+
+	registry := metrics.NewRegistry()
+	collector := metrics.NewCollector(registry, ledger, daemonEERPOverdue)
+
+	if daemonMetricsListen != "" {
+		serveMetrics(ctx, log, registry)
+	}
+
+	sessions := manager.New(manager.Limits{MaxSessions: daemonMaxSessions, MaxPartnerSessions: daemonMaxPartnerSessions})
+	for _, p := range config.Partners {
+		sessions.SetPartnerLimit(p.Id, p.MaxSessions)
+	}
+
+	// files taken by a running session must not be picked up by another one
+	busy := newFileSet()
+
+	for {
+		for _, g := range config.Groups {
+			broadcastGroupFiles(log, config, g, forwards)
+		}
+
+		for _, p := range config.Partners {
+			if ctx.Err() != nil {
+				break
+			}
+
+			outbox := partnerOutbox(p)
+			files, err := outbox.Scan()
+			if err != nil {
+				log.Error("cannot scan outbox", logging.KeyPartner, p.Id, "error", err)
+			}
+
+			files = busy.claim(readyFiles(files, tracker))
+
+			// end to end responses of forwarded files are passed back right away
+			if len(files) == 0 && len(forwards.Ready(p.Id)) == 0 && time.Now().Before(nextPoll[p.Id]) {
+				continue
+			}
+
+			p := p
+			sessionFiles := files
+			started := sessions.Start(ctx, p.Id, func(ctx context.Context) {
+				defer busy.release(sessionFiles)
+
+				err := exchangeWithPartner(ctx, config, p, outbox, sessionFiles, tracker, ledger, forwards, collector)
+				if err != nil {
+					log.Error("session failed", logging.KeyPartner, p.Id, "error", err)
+				}
+			})
+
+			if !started {
+				// the partner is busy, try again on the next scan
+				busy.release(files)
+				continue
+			}
+
+			nextPoll[p.Id] = time.Now().Add(time.Duration(p.PollInterval))
+		}
+
+		if daemonOnce {
+			sessions.Wait()
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			sessions.Wait()
+			return
+		case <-time.After(daemonScanInterval):
+		}
+	}
+}
+
+// fileSet is a set of outbox files that can be used by several goroutines
+type fileSet struct {
+	mutex sync.Mutex
+	paths map[string]bool
+}
+
+func newFileSet() *fileSet {
+	return &fileSet{paths: make(map[string]bool)}
+}
+
+// claim adds the files to the set and returns those which were not in it yet
+func (f *fileSet) claim(files []spool.OutboxFile) []spool.OutboxFile {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	result := make([]spool.OutboxFile, 0, len(files))
+	for _, file := range files {
+		if !f.paths[file.Path] {
+			f.paths[file.Path] = true
+			result = append(result, file)
+		}
+	}
+	return result
+}
+
+// release removes the files from the set
+func (f *fileSet) release(files []spool.OutboxFile) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, file := range files {
+		delete(f.paths, file.Path)
+	}
+}
+
+// serveMetrics starts the HTTP server for the metrics endpoint. It is shut
+// down when the context is done.
+func serveMetrics(ctx context.Context, log logging.Logger, registry *metrics.Registry) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+
+	server := &http.Server{Addr: daemonMetricsListen, Handler: mux}
+
+	go func() {
+		log.Info("serving metrics", "address", daemonMetricsListen)
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Error("cannot serve metrics", "error", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+}
+
+// partnerOutbox returns the outbox of the partner
+func partnerOutbox(p partner.Profile) *spool.Outbox {
+	return &spool.Outbox{
+		Dir:         p.Outbox,
+		ArchiveDir:  p.Archive,
+		ErrorDir:    p.Error,
+		Destination: p.Id,
+	}
+}
+
+// readyFiles removes the files waiting for their next attempt
+func readyFiles(files []spool.OutboxFile, tracker *retry.Tracker) []spool.OutboxFile {
+	result := make([]spool.OutboxFile, 0, len(files))
+	now := time.Now()
+
+	for _, f := range files {
+		if tracker.Ready(f.Path, now) {
+			result = append(result, f)
+		}
+	}
+
+	return result
+}
+
+// exchangeWithPartner runs a session with the partner: the files of the
+// outbox are sent, then the partner becomes speaker and can send its files.
+func exchangeWithPartner(ctx context.Context, config *partner.Config, p partner.Profile, outbox *spool.Outbox, files []spool.OutboxFile,
+	tracker *retry.Tracker, ledger *spool.Ledger, forwards *spool.Forwards, collector *metrics.Collector) error {
+	log := cliLogger().With(logging.KeyPartner, p.Id)
+
+	c := newClient()
+	c.ServerHost = p.Host
+	c.ServerPort = p.Port
+	c.FallbackEndpoints = make([]client.Endpoint, 0, len(p.Endpoints))
+	for _, e := range p.Endpoints {
+		c.FallbackEndpoints = append(c.FallbackEndpoints, client.Endpoint{Host: e.Host, Port: e.Port})
+	}
+	if p.ConnectTimeout > 0 {
+		c.ConnectTimeout = time.Duration(p.ConnectTimeout)
+	}
+	c.Capability = p.Capability
+
+	observe := collector.Observer(p.Id)
+	c.Observer = func(event client.Event) {
+		observe(event)
+
+		if event.Kind == client.EventFileSent && event.File != nil {
+			err := ledger.Add(p.Id, *event.File, event.Time)
+			if err != nil {
+				log.Error("cannot record file in ledger", logging.KeyDataset, event.DatasetName, "error", err)
+			}
+		}
+	}
+
+	// failAll handles a failure of the session for all files not sent yet
+	failAll := func(files []spool.OutboxFile, err error) error {
+		for _, f := range files {
+			fileFailed(log, outbox, tracker, forwards, f, err)
+		}
+		return err
+	}
+
+	// proxy and TLS of the profile take precedence over the command line
+	if p.Proxy != "" {
+		c.Proxy, _ = p.ProxyConfig() // checked when the configuration was loaded
+	}
+	if p.TLS {
+		tlsConfig, err := p.TLSConfig()
+		if err != nil {
+			return failAll(files, err)
+		}
+		c.TLSConfig = tlsConfig
+	}
+
+	s, err := c.Open(ctx, p.Password, false, false, false)
+	if err != nil {
+		return failAll(files, err)
+	}
+	defer s.Close()
+
+	for _, f := range forwards.Ready(p.Id) {
+		receipt := f.Receipt()
+		err = s.SendReceipt(ctx, receipt)
+		if err != nil {
+			return failAll(files, err)
+		}
+
+		log.Info("end to end response passed on", logging.KeyDataset, f.DatasetName, "originator", f.Originator, "negative", receipt.Negative)
+		if err = forwards.Remove(f); err != nil {
+			log.Error("cannot update forwarded files", logging.KeyDataset, f.DatasetName, "error", err)
+		}
+	}
+
+	queue := client.NewSendQueue()
+	outboxFiles := make(map[string]spool.OutboxFile)
+
+	for _, f := range files {
+		queued, err := f.QueuedFile()
+		if err != nil {
+			fileFailed(log, outbox, tracker, forwards, f, err)
+			continue
+		}
+		outboxFiles[queued.Id] = f
+		queue.Push(s.PartnerId(), queued)
+	}
+
+	// remaining returns the files left in the queue
+	remaining := func() []spool.OutboxFile {
+		result := make([]spool.OutboxFile, 0)
+		for f, ok := queue.Pop(s.PartnerId()); ok; f, ok = queue.Pop(s.PartnerId()) {
+			result = append(result, outboxFiles[f.Id])
+		}
+		return result
+	}
+
+	inbox := &routing.Inbox{
+		From:     p.Id,
+		LocalId:  c.OdetteId,
+		Local:    &spool.Inbox{Dir: p.Inbox, LocalId: c.OdetteId},
+		Config:   config,
+		Forwards: forwards,
+	}
+
+	// the queue is drained again if the partner requested the right to speak
+	// before all files were sent
+	for {
+		results, err := s.DrainQueue(ctx, queue)
+
+		for _, r := range results {
+			f := outboxFiles[r.File.Id]
+			if r.Err != nil {
+				fileFailed(log, outbox, tracker, forwards, f, r.Err)
+				continue
+			}
+
+			tracker.Forget(f.Path)
+			err := outbox.Archive(f)
+			if err != nil {
+				log.Error("cannot archive file", "file", f.Path, "error", err)
+			}
+		}
+
+		if err != nil {
+			return failAll(remaining(), err)
+		}
+
+		// the receipts received before a failure were confirmed to the
+		// partner already, they are processed in any case
+		exchange, err := s.ReceiveFiles(ctx, inbox)
+		confirmReceipts(log, ledger, forwards, exchange.Receipts)
+		if err != nil {
+			return failAll(remaining(), err)
+		}
+
+		if exchange.SessionEnded {
+			// the files left are sent in the next session
+			return nil
+		}
+
+		if queue.Len(s.PartnerId()) == 0 {
+			return s.End(ctx)
+		}
+	}
+}
+
+// confirmReceipts records the end to end responses received from the partner
+// in the ledger and for the forwarded files
+func confirmReceipts(log logging.Logger, ledger *spool.Ledger, forwards *spool.Forwards, receipts []client.Receipt) {
+	for _, r := range receipts {
+		_, found, err := ledger.Confirm(r)
+		if err != nil {
+			log.Error("cannot update ledger", logging.KeyDataset, r.DatasetName, "error", err)
+		} else if !found {
+			log.Warn("end to end response for unknown file", logging.KeyDataset, r.DatasetName, "originator", r.Originator)
+		}
+
+		forward, found, err := forwards.Confirm(r)
+		if err != nil {
+			log.Error("cannot update forwarded files", logging.KeyDataset, r.DatasetName, "error", err)
+		} else if found {
+			forwardAnswered(log, forwards, forward)
+		}
+
+		if r.Negative {
+			log.Warn("negative end response received", logging.KeyDataset, r.DatasetName, "error", r.Err())
+		} else {
+			log.Info("end to end response received", logging.KeyDataset, r.DatasetName)
+		}
+	}
+}
+
+// fileFailed handles a file that could not be sent. If the error is temporary
+// and retries are left, the file stays in the outbox. Otherwise it is moved to
+// the error directory together with its final status. For a forwarded file
+// the NERP is passed back to the partner it came from.
+func fileFailed(log logging.Logger, outbox *spool.Outbox, tracker *retry.Tracker, forwards *spool.Forwards, f spool.OutboxFile, err error) {
+	if client.IsRetryable(err) {
+		next, ok := tracker.Failed(f.Path, time.Now())
+		if ok {
+			log.Warn("file will be retried", "file", f.Path, "next_attempt", next, "error", err)
+			return
+		}
+	} else {
+		tracker.Forget(f.Path)
+	}
+
+	receipt := client.FailureReceipt(f.Metadata.VirtualFile(), activeOptions.OdetteId, err)
+	log.Error("giving up on file", "file", f.Path, "nerp_reason", receipt.ReasonCode, "error", err)
+
+	forward, found, confirmErr := forwards.Confirm(receipt)
+	if confirmErr != nil {
+		log.Error("cannot update forwarded files", "file", f.Path, "error", confirmErr)
+	} else if found {
+		forwardAnswered(log, forwards, forward)
+	}
+
+	failErr := outbox.Fail(f, errors.New(fmt.Sprintf("%v\nNERP reason %02d: %s", err, receipt.ReasonCode, receipt.ReasonText)))
+	if failErr != nil {
+		log.Error("cannot move file to error directory", "file", f.Path, "error", failErr)
+	}
+}
+
+// forwardAnswered handles the answer of an onward partner for a forwarded
+// file. When all onward partners answered, the end to end response is passed
+// back in the next session with the partner the file came from. For a file of
+// a local application, it is only logged.
+func forwardAnswered(log logging.Logger, forwards *spool.Forwards, forward spool.Forward) {
+	if !forward.Complete() {
+		return
+	}
+
+	if forward.From != "" {
+		log.Info("end to end response of forwarded file ready", logging.KeyDataset, forward.DatasetName, "from", forward.From)
+		return
+	}
+
+	receipt := forward.Receipt()
+	if receipt.Negative {
+		log.Warn("negative end response for group file", logging.KeyDataset, forward.DatasetName, "group", forward.Destination, "error", receipt.Err())
+	} else {
+		log.Info("end to end response for group file", logging.KeyDataset, forward.DatasetName, "group", forward.Destination)
+	}
+
+	err := forwards.Remove(forward)
+	if err != nil {
+		log.Error("cannot update forwarded files", logging.KeyDataset, forward.DatasetName, "error", err)
+	}
+}
+
+// broadcastGroupFiles puts a copy of each file in the outbox of the group into
+// the outboxes of its members. Broadcast files are moved to the archive of the
+// group, files that cannot be broadcast to its error directory.
+func broadcastGroupFiles(log logging.Logger, config *partner.Config, g partner.Group, forwards *spool.Forwards) {
+	outbox := &spool.Outbox{Dir: g.Outbox, ArchiveDir: g.Archive, ErrorDir: g.Error, Destination: g.Id}
+
+	files, err := outbox.Scan()
+	if err != nil {
+		log.Error("cannot scan outbox", "group", g.Id, "error", err)
+		return
+	}
+
+	router := &routing.Inbox{LocalId: activeOptions.OdetteId, Config: config, Forwards: forwards}
+
+	for _, f := range files {
+		file := f.Metadata.VirtualFile()
+		file.Destination = g.Id
+
+		err = broadcastFile(router, f.Path, file)
+		if err != nil {
+			log.Error("cannot broadcast file", "file", f.Path, "group", g.Id, "error", err)
+			if failErr := outbox.Fail(f, err); failErr != nil {
+				log.Error("cannot move file to error directory", "file", f.Path, "error", failErr)
+			}
+			continue
+		}
+
+		log.Info("file broadcast", logging.KeyDataset, file.DatasetName, "group", g.Id)
+		if err = outbox.Archive(f); err != nil {
+			log.Error("cannot archive file", "file", f.Path, "error", err)
+		}
+	}
+}
+
+// broadcastFile submits the file at the path to the router
+func broadcastFile(router *routing.Inbox, path string, file client.VirtualFile) error {
+	r, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return router.Submit(file, r)
+}
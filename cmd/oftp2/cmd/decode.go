@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+)
+
+var decodeInputFormat string
+
+var decodeCommand = &cobra.Command{
+	Use:   "decode [FILE]",
+	Short: "Decode captured OFTP2 traffic",
+	Long: `Decodes a captured OFTP2 TCP stream and prints every command with its fields.
+
+The input is read from FILE or from stdin if no file (or "-") is given. It can
+either be a raw binary capture (e.g. a TCP stream extracted from a pcap file) or
+a hex dump consisting of hexadecimal digits and whitespace (e.g. the output of
+"xxd -p"). The format is detected automatically unless it is set with --input.`,
+	Example: `oftp2 decode capture.bin
+xxd -p capture.bin | oftp2 decode --input hex`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "-"
+		if len(args) > 0 {
+			path = args[0]
+		}
+		decodeCapture(path)
+	},
+}
+
+func init() {
+	decodeCommand.Flags().StringVar(&decodeInputFormat, "input", "auto", "input format: auto, hex or raw")
+}
+
+func decodeCapture(path string) {
+
+	var data []byte
+	var err error
+
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+
+	if err != nil {
+		print(err.Error() + "\n")
+		os.Exit(1)
+	}
+
+	stream, err := decodeInput(data, decodeInputFormat)
+	if err != nil {
+		print(err.Error() + "\n")
+		os.Exit(1)
+	}
+
+	dissections, err := client.DissectStream(stream)
+
+	for i, d := range dissections {
+		printDissection(i, d)
+	}
+
+	if err != nil {
+		fmt.Printf("stream error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+var hexDumpPattern = regexp.MustCompile(`^[0-9a-fA-F\s]*$`)
+
+// decodeInput converts the input into the raw stream according to the given format.
+func decodeInput(data []byte, format string) ([]byte, error) {
+	switch format {
+	case "raw":
+		return data, nil
+	case "hex":
+		return hex.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+	case "auto":
+		if len(data) > 0 && hexDumpPattern.Match(data) {
+			return decodeInput(data, "hex")
+		}
+		return data, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown input format %s", format))
+	}
+}
+
+func printDissection(index int, d client.Dissection) {
+
+	name := d.Type
+	if name == "" {
+		name = "unknown"
+	}
+
+	fmt.Printf("#%d %s (offset %d, %d bytes)\n", index+1, name, d.Offset, d.Length)
+
+	for _, f := range d.Fields {
+		if f.Name == "DATABUF" {
+			fmt.Printf("    %-10s = %d bytes\n", f.Name, len(f.Raw))
+			continue
+		}
+
+		if f.Meaning != "" {
+			fmt.Printf("    %-10s = %q (%s)\n", f.Name, f.Value, f.Meaning)
+		} else {
+			fmt.Printf("    %-10s = %q\n", f.Name, f.Value)
+		}
+	}
+
+	userData := 0
+	for i, r := range d.SubRecords {
+		flags := ""
+		if r.EndOfRecord {
+			flags += " EoR"
+		}
+		if r.Compressed {
+			flags += " CF"
+		}
+
+		expanded := r.Expand()
+		userData += len(expanded)
+
+		fmt.Printf("      sub-record %3d: offset %5d, count %2d%s, %q\n", i+1, r.Offset, r.Count, flags, string(expanded))
+	}
+
+	if d.SubRecords != nil {
+		fmt.Printf("    %d sub-records, %d bytes of user data\n", len(d.SubRecords), userData)
+	}
+
+	if d.Err != nil {
+		fmt.Printf("    error: %v\n", d.Err)
+	}
+
+	fmt.Printf("\n")
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+)
+
+// Exit codes of the commands communicating with a partner
+const (
+	exitOK                  = 0 // everything worked
+	exitError               = 1 // any other error, e.g. invalid arguments or local files
+	exitSessionEnded        = 2 // the partner ended the session (ESID)
+	exitStartFileRejected   = 3 // the partner refused a file (SFNA)
+	exitEndFileRejected     = 4 // the partner refused a file after transmission (EFNA)
+	exitNegativeEndResponse = 5 // a file could not be delivered (NERP)
+	exitTimeout             = 6 // the partner did not answer in time or the command timed out
+	exitConnection          = 7 // the connection could not be established or broke down
+)
+
+const exitCodeDoc = `
+Exit status:
+  0  success
+  1  general error, e.g. invalid arguments or local files
+  2  the partner ended the session (ESID)
+  3  the partner refused a file (SFNA)
+  4  the partner refused a file after its transmission (EFNA)
+  5  a file could not be delivered (NERP)
+  6  time out
+  7  connection failure`
+
+// exitCode returns the exit code for the category of the error
+func exitCode(err error) int {
+	var sessionEnded *client.SessionEndedError
+	var sfna *client.StartFileRejectedError
+	var efna *client.EndFileRejectedError
+	var nerp *client.NegativeEndResponseError
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &sessionEnded):
+		return exitSessionEnded
+	case errors.As(err, &sfna):
+		return exitStartFileRejected
+	case errors.As(err, &efna):
+		return exitEndFileRejected
+	case errors.As(err, &nerp):
+		return exitNegativeEndResponse
+	case errors.Is(err, client.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return exitTimeout
+	case client.IsRetryable(err):
+		// the remaining retryable errors are network errors
+		return exitConnection
+	default:
+		return exitError
+	}
+}
+
+// exitWithError prints the error and exits with the exit code of its category
+func exitWithError(err error) {
+	print(err.Error() + "\n")
+	os.Exit(exitCode(err))
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/fuzz"
+	"github.com/thomsmits/oftp2-client/oftp2/oftp2test"
+)
+
+var fuzzOptions = struct {
+	Password        string
+	Local           bool
+	Commands        []string
+	MutationTimeout time.Duration
+	Record          string
+}{}
+
+var fuzzCommand = &cobra.Command{
+	Use:   "fuzz",
+	Short: "Send malformed commands to the partner",
+	Long: `Sends deliberately malformed SSID, SFID and DATA commands to the partner
+and reports how it reacted to each of them.
+
+The fields of the commands are mutated according to their format in RFC 5024:
+values one octet longer or shorter than allowed, characters outside of the
+character set of the field and, for DATA, broken sub-record headers. Each
+mutation is sent in its own session. Mutated SFID and DATA commands are sent
+with a test file named FUZZ.*.
+
+A conforming partner refuses a malformed command with an ESID, SFNA or EFNA.
+The inputs after which the partner dropped the connection are listed at the
+end of the report. With --record they are also written to the directory, one
+file per input, to replay them later.
+
+With --local the mutations are sent to the built-in test partner instead.
+
+The exit status is 1 if the partner dropped the connection.`,
+	Example: `oftp2 fuzz -s oftp.example.com -i O0013000000CLIENT --password SECRET --record findings
+oftp2 fuzz --local --command SSID`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runFuzz()
+	},
+}
+
+func init() {
+	fuzzCommand.Flags().StringVar(&fuzzOptions.Password, "password", "", "password sent to the partner")
+	fuzzCommand.Flags().BoolVar(&fuzzOptions.Local, "local", false, "send to the built-in test partner")
+	fuzzCommand.Flags().StringSliceVar(&fuzzOptions.Commands, "command", []string{"SSID", "SFID", "DATA"}, "commands to mutate")
+	fuzzCommand.Flags().DurationVar(&fuzzOptions.MutationTimeout, "mutation-timeout", fuzz.DefaultTimeout, "maximum duration of the session of a single mutation")
+	fuzzCommand.Flags().StringVar(&fuzzOptions.Record, "record", "", "directory to write the inputs to, after which the partner dropped the connection")
+}
+
+func runFuzz() {
+	mutations, err := selectMutations(fuzzOptions.Commands)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	target := &fuzz.Target{
+		Client:   newClient(),
+		Password: fuzzOptions.Password,
+	}
+
+	if fuzzOptions.Local {
+		partner := &oftp2test.Partner{}
+		target.Client.Dialer = partner.Dialer()
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	report := fuzz.Run(ctx, target, mutations, fuzzOptions.MutationTimeout)
+
+	err = report.WriteText(os.Stdout)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	if fuzzOptions.Record != "" {
+		err = recordDropped(fuzzOptions.Record, report)
+		if err != nil {
+			exitWithError(err)
+		}
+	}
+
+	if len(report.Dropped()) > 0 {
+		os.Exit(exitError)
+	}
+}
+
+// selectMutations returns the mutations of the given commands
+func selectMutations(commands []string) ([]fuzz.Mutation, error) {
+	all := fuzz.Mutations()
+
+	known := make(map[string]bool)
+	for _, m := range all {
+		known[m.Command] = true
+	}
+
+	selected := make(map[string]bool)
+	for _, c := range commands {
+		c = strings.ToUpper(c)
+		if !known[c] {
+			return nil, errors.New(fmt.Sprintf("cannot mutate command %s, use SSID, SFID or DATA", c))
+		}
+		selected[c] = true
+	}
+
+	result := make([]fuzz.Mutation, 0)
+	for _, m := range all {
+		if selected[m.Command] {
+			result = append(result, m)
+		}
+	}
+
+	return result, nil
+}
+
+// recordDropped writes the inputs after which the partner dropped the
+// connection into the directory
+func recordDropped(dir string, report *fuzz.Report) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	for i, result := range report.Dropped() {
+		name := fmt.Sprintf("%03d-%s-%s-%s.bin", i+1, result.Mutation.Command, result.Mutation.Field,
+			strings.ReplaceAll(result.Mutation.Kind.String(), " ", "-"))
+		err = os.WriteFile(filepath.Join(dir, name), result.Input, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
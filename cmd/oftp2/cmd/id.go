@@ -1,39 +1,114 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"strconv"
 
 	"github.com/spf13/cobra"
 	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
 )
 
 var idCommand = &cobra.Command{
-	Use:     "id",
-	Short:   "Try to determine the server's ODETTE id",
-	Long:    `Connects to the server with the id LOCAL (or the id set via -i flag) and tries to find the server's id.`,
+	Use:   "id",
+	Short: "Try to determine the server's ODETTE id",
+	Long: `Connects to the server with the id O0000LOCAL (or the id set via -i flag) and tries to find the server's id.
+
+The subcommands generate and parse build an Odette ID from its components and
+split it up again.`,
 	Example: `oftp2 id`,
+	Args:    cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		determineId()
 	},
 }
 
+var idGenerateCommand = &cobra.Command{
+	Use:   "generate INTERNATIONAL-CODE ORGANISATION [SUB-ADDRESS]",
+	Short: "Generate an Odette ID from its components",
+	Long: `Generates an Odette ID from the international code (ICD) of the organisation
+code, the organisation code of up to 14 characters and the optional computer
+sub-address of up to 6 characters. The organisation code is padded with spaces
+if a sub-address is given.`,
+	Example: `oftp2 id generate 13 EXAMPLE
+oftp2 id generate 13 EXAMPLE SUB`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		generateId(args)
+	},
+}
+
+var idParseCommand = &cobra.Command{
+	Use:   "parse ODETTE-ID",
+	Short: "Check an Odette ID and split it into its components",
+	Long: `Checks the Odette ID and prints its international code, organisation code and
+sub-address. The ID is converted to upper case first. The exit status is 1 if
+the ID is invalid.`,
+	Example: `oftp2 id parse O0013000000CLIENT
+oftp2 id parse "O0013EXAMPLE       SUB"`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		parseId(args[0])
+	},
+}
+
+func init() {
+	idCommand.AddCommand(idGenerateCommand)
+	idCommand.AddCommand(idParseCommand)
+}
+
 func determineId() {
 
-	r := client.OFTP2Client{
-		ServerHost: activeOptions.Server,
-		ServerPort: activeOptions.Port,
-		OdetteId:   activeOptions.OdetteId,
-		Verbose:    activeOptions.Verbose,
+	r := newClient()
+
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	ssid, err := r.QueryServerCapabilitiesContext(ctx)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	fmt.Printf("Server's id is: '%s'\n", ssid.Id)
+}
+
+func generateId(args []string) {
+	intCode, err := strconv.Atoi(args[0])
+	if err != nil || intCode < 0 || intCode > 9999 {
+		exitWithError(errors.New(fmt.Sprintf("international code %q is not a number with up to 4 digits", args[0])))
 	}
 
-	ssid, err := r.QueryServerCapabilities()
+	subAddress := ""
+	if len(args) == 3 {
+		subAddress = args[2]
+	}
+
+	// the components are not truncated silently
+	if len(args[1]) > 14 {
+		exitWithError(errors.New(fmt.Sprintf("organisation code %q is longer than 14 characters", args[1])))
+	}
+	if len(subAddress) > 6 {
+		exitWithError(errors.New(fmt.Sprintf("sub-address %q is longer than 6 characters", subAddress)))
+	}
+
+	id, err := client.NormaliseOdetteId(client.GenerateOdetteId(intCode, args[1], subAddress))
 	if err != nil {
-		print(err.Error() + "\n")
-		os.Exit(1)
+		exitWithError(err)
 	}
 
-	r.Close()
+	fmt.Println(id)
+}
 
-	fmt.Printf("Server's id is: '%s'\n", ssid.Id)
+func parseId(value string) {
+	normalised, err := client.NormaliseOdetteId(value)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	id, _ := client.ParseOdetteId(normalised)
+
+	fmt.Printf("Odette ID         : %s\n", normalised)
+	fmt.Printf("International code: %04d\n", id.InternationalCode)
+	fmt.Printf("Organisation code : %s\n", id.Organisation)
+	fmt.Printf("Sub-address       : %s\n", id.SubAddress)
 }
@@ -1,14 +1,241 @@
 package cmd
 
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/logging"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/partner"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/proxy"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/retry"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/trace"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+)
+
 type Options struct {
-	Server   string
-	Port     int
-	OdetteId string
-	Verbose  bool
+	Server    string
+	Port      int
+	OdetteId  string
+	Verbose   bool
+	TraceFile string
+	LogLevel  string
+	LogFormat string
+	Proxy     string
+	TLS       bool
+	CAFile    string
+	Endpoints []string
+
+	ConnectTimeout time.Duration
+
+	Timeout           time.Duration
+	ResponseTimeout   time.Duration
+	InactivityTimeout time.Duration
 }
 
 var activeOptions = &Options{
-	Server:   "localhost",
-	Port:     3305,
-	OdetteId: "LOCAL",
+	Server:    "localhost",
+	Port:      3305,
+	OdetteId:  "O0000LOCAL",
+	LogLevel:  "warn",
+	LogFormat: "text",
+}
+
+// sharedMutex guards the creation of the recorder and the logger, which are
+// shared by sessions running concurrently
+var sharedMutex sync.Mutex
+
+// recorder is the trace recorder shared by all clients of the command
+var recorder *trace.Recorder
+
+// traceRecorder returns the recorder for the trace file given on the command
+// line or nil if no trace was requested. The file is created on first use.
+func traceRecorder() *trace.Recorder {
+	sharedMutex.Lock()
+	defer sharedMutex.Unlock()
+
+	if activeOptions.TraceFile == "" || recorder != nil {
+		return recorder
+	}
+
+	file, err := os.Create(activeOptions.TraceFile)
+	if err != nil {
+		print(err.Error() + "\n")
+		os.Exit(1)
+	}
+
+	recorder = trace.NewRecorder(file)
+	recorder.Redact = session.RedactPassword
+	return recorder
+}
+
+// logger is the logger shared by all clients of the command
+var logger logging.Logger
+
+// cliLogger returns the logger configured on the command line. Log messages are
+// written to stderr to keep them apart from the output of the commands.
+func cliLogger() logging.Logger {
+	sharedMutex.Lock()
+	defer sharedMutex.Unlock()
+
+	if logger != nil {
+		return logger
+	}
+
+	level, err := logging.ParseLevel(activeOptions.LogLevel)
+	if err != nil {
+		print(err.Error() + "\n")
+		os.Exit(1)
+	}
+
+	if activeOptions.Verbose {
+		level = logging.LevelDebug
+	}
+
+	switch activeOptions.LogFormat {
+	case "text":
+		logger = logging.NewTextLogger(os.Stderr, level)
+	case "json":
+		logger = logging.NewJSONLogger(os.Stderr, level)
+	default:
+		print("unknown log format " + activeOptions.LogFormat + "\n")
+		os.Exit(1)
+	}
+
+	return logger
+}
+
+// newClient creates a client configured with the options given on the command
+// line.
+func newClient() client.OFTP2Client {
+	return client.OFTP2Client{
+		ServerHost:        activeOptions.Server,
+		ServerPort:        activeOptions.Port,
+		OdetteId:          activeOptions.OdetteId,
+		Verbose:           activeOptions.Verbose,
+		Trace:             traceRecorder(),
+		Logger:            cliLogger(),
+		ResponseTimeout:   activeOptions.ResponseTimeout,
+		InactivityTimeout: activeOptions.InactivityTimeout,
+		ConnectTimeout:    activeOptions.ConnectTimeout,
+		FallbackEndpoints: cliEndpoints(),
+		Proxy:             cliProxy(),
+		TLSConfig:         cliTLSConfig(),
+	}
+}
+
+// cliEndpoints returns the fallback endpoints given on the command line as
+// host:port, IPv6 addresses in brackets
+func cliEndpoints() []client.Endpoint {
+	endpoints := make([]client.Endpoint, 0, len(activeOptions.Endpoints))
+
+	for _, address := range activeOptions.Endpoints {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			print(err.Error() + "\n")
+			os.Exit(1)
+		}
+
+		number, err := strconv.Atoi(port)
+		if err != nil || number <= 0 || number > 65535 {
+			print("invalid port in endpoint " + address + "\n")
+			os.Exit(1)
+		}
+
+		endpoints = append(endpoints, client.Endpoint{Host: host, Port: number})
+	}
+	return endpoints
+}
+
+// cliProxy returns the proxy given on the command line or nil
+func cliProxy() *proxy.Proxy {
+	if activeOptions.Proxy == "" {
+		return nil
+	}
+
+	p, err := proxy.Parse(activeOptions.Proxy)
+	if err != nil {
+		print(err.Error() + "\n")
+		os.Exit(1)
+	}
+	return p
+}
+
+// cliTLSConfig returns the TLS configuration given on the command line or nil
+// if TLS is not used
+func cliTLSConfig() *tls.Config {
+	profile := partner.Profile{TLS: activeOptions.TLS, CAFile: activeOptions.CAFile}
+
+	config, err := profile.TLSConfig()
+	if err != nil {
+		print(err.Error() + "\n")
+		os.Exit(1)
+	}
+	return config
+}
+
+// commandContext returns the context for the communication of a command. It is
+// cancelled on interrupt or when the timeout given on the command line expires.
+func commandContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if activeOptions.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, activeOptions.Timeout)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(interrupt)
+	}()
+
+	return ctx, cancel
+}
+
+// retryOptions are the command line options controlling retries
+type retryOptions struct {
+	Retries  int
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// addRetryFlags adds the retry options to the command, using the given default
+// number of retries.
+func addRetryFlags(cmd *cobra.Command, retries int) *retryOptions {
+	options := &retryOptions{}
+	cmd.Flags().IntVar(&options.Retries, "retries", retries, "number of retries for files failing with a temporary error")
+	cmd.Flags().DurationVar(&options.Delay, "retry-delay", retry.DefaultPolicy.InitialDelay, "time to wait before the first retry, doubled for every further retry")
+	cmd.Flags().DurationVar(&options.MaxDelay, "retry-max-delay", retry.DefaultPolicy.MaxDelay, "maximum time to wait between two retries")
+	return options
+}
+
+// policy returns the retry policy described by the options
+func (o *retryOptions) policy() retry.Policy {
+	return retry.Policy{
+		MaxAttempts:  o.Retries + 1,
+		InitialDelay: o.Delay,
+		MaxDelay:     o.MaxDelay,
+		Multiplier:   retry.DefaultPolicy.Multiplier,
+	}
+}
+
+// printFailure prints the final status of a file the command gave up on
+func printFailure(file client.VirtualFile, err error) {
+	receipt := client.FailureReceipt(file, activeOptions.OdetteId, err)
+	fmt.Printf("%s: failed: %v\n", file.DatasetName, err)
+	fmt.Printf("%s: NERP reason %02d: %s\n", file.DatasetName, receipt.ReasonCode, receipt.ReasonText)
 }
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+)
+
+// progressBarWidth is the number of characters of the bar itself
+const progressBarWidth = 30
+
+// progressInterval limits how often the progress bar is redrawn
+const progressInterval = 200 * time.Millisecond
+
+// progressBar renders the progress of file transfers reported by the client
+type progressBar struct {
+	out      io.Writer
+	lastDraw time.Time
+}
+
+// observe is used as observer of the client
+func (p *progressBar) observe(event client.Event) {
+	switch event.Kind {
+	case client.EventProgress:
+		if time.Since(p.lastDraw) < progressInterval {
+			return
+		}
+		p.draw(event)
+	case client.EventCreditExhausted:
+		p.draw(event)
+	case client.EventFileSent:
+		p.draw(event)
+		fmt.Fprintf(p.out, "\n")
+	}
+}
+
+// draw prints the progress line, overwriting the previous one
+func (p *progressBar) draw(event client.Event) {
+	p.lastDraw = time.Now()
+	stats := event.Stats
+
+	line := fmt.Sprintf("%-26s ", event.DatasetName)
+
+	if event.Size > 0 {
+		fraction := float64(stats.Bytes) / float64(event.Size)
+		if fraction > 1 {
+			fraction = 1
+		}
+		done := int(fraction * progressBarWidth)
+		line += fmt.Sprintf("[%s%s] %3d%% %s/%s",
+			strings.Repeat("#", done), strings.Repeat("-", progressBarWidth-done),
+			int(fraction*100), formatBytes(float64(stats.Bytes)), formatBytes(float64(event.Size)))
+	} else {
+		line += formatBytes(float64(stats.Bytes))
+	}
+
+	line += fmt.Sprintf(" %s/s", formatBytes(stats.Throughput()))
+
+	if event.Kind == client.EventCreditExhausted {
+		line += " (waiting for credit)"
+	}
+
+	fmt.Fprintf(p.out, "\r%-100s", line)
+}
+
+// formatStats returns the final statistics of a transfer as text
+func formatStats(stats client.TransferStats) string {
+	return fmt.Sprintf("%s in %v (%s/s), %d buffers, %d credit stalls (%v), compression ratio %.2f",
+		formatBytes(float64(stats.Bytes)), stats.Duration().Round(time.Millisecond), formatBytes(stats.Throughput()),
+		stats.Buffers, stats.CreditStalls, stats.StallTime.Round(time.Millisecond), stats.CompressionRatio())
+}
+
+// formatBytes formats a number of bytes with binary units
+func formatBytes(bytes float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", bytes, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", bytes, units[unit])
+}
@@ -1,44 +1,78 @@
 package cmd
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
-	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/capability"
+	"github.com/thomsmits/oftp2-client/oftp2/oftp2test"
 )
 
+var queryOptions = struct {
+	Password   string
+	JSON       bool
+	ProbeFiles bool
+	Local      bool
+}{}
+
 var queryCommand = &cobra.Command{
-	Use:     "query",
-	Short:   "Query the server",
-	Long:    `Queries the server to determine its capabilities.`,
-	Example: `oftp2 query -i LOCAL`,
+	Use:   "query",
+	Short: "Query the server",
+	Long: `Queries the server to determine its capabilities.
+
+The report tells whether the partner accepts our Odette ID and password, the
+protocol release it speaks, the session parameters it supports including the
+largest and smallest buffer size and credit, and whether it agrees to secure
+authentication. With --tls the accepted TLS versions and TLS 1.2 cipher suites
+are probed and the certificate chain of the partner is shown.
+
+With --probe-files empty files named OFTP2.PROBE.* are sent to find out which
+cipher suites and which file compression the partner accepts in a Start File
+command.
+
+With --json the report is written as JSON document, e.g. for the onboarding
+checklist of a partner.`,
+	Example: `oftp2 query -i O0013000000CLIENT
+oftp2 query -s oftp.example.com -i O0013000000CLIENT --password SECRET --tls --probe-files --json`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		queryClient()
 	},
 }
 
+func init() {
+	queryCommand.Flags().StringVar(&queryOptions.Password, "password", "", "password sent to the partner")
+	queryCommand.Flags().BoolVar(&queryOptions.JSON, "json", false, "write the report as JSON")
+	queryCommand.Flags().BoolVar(&queryOptions.ProbeFiles, "probe-files", false, "send empty files to probe the accepted cipher suites and compression")
+	queryCommand.Flags().BoolVar(&queryOptions.Local, "local", false, "query the built-in test partner")
+}
+
 func queryClient() {
+	target := &capability.Target{
+		Client:     newClient(),
+		Password:   queryOptions.Password,
+		ProbeFiles: queryOptions.ProbeFiles,
+	}
 
-	r := client.OFTP2Client{
-		ServerHost: activeOptions.Server,
-		ServerPort: activeOptions.Port,
-		OdetteId:   activeOptions.OdetteId,
-		Verbose:    activeOptions.Verbose,
+	if queryOptions.Local {
+		partner := &oftp2test.Partner{Restart: true, Compression: true}
+		target.Client.Dialer = partner.Dialer()
 	}
 
-	ssid, err := r.QueryServerCapabilities()
+	ctx, cancel := commandContext()
+	defer cancel()
 
+	report, err := capability.Probe(ctx, target)
 	if err != nil {
-		print(err.Error() + "\n")
-		os.Exit(1)
+		exitWithError(err)
 	}
 
-	fmt.Printf("\nData received from remote system:\n")
-	fmt.Printf("------------------------------------------\n")
-	fmt.Printf("%s", ssid.String())
-	fmt.Printf("------------------------------------------\n")
-	r.Close()
-
-	os.Exit(0)
+	if queryOptions.JSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		exitWithError(err)
+	}
 }
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/trace"
+)
+
+var replayOptions = struct {
+	Listen string
+	Strict bool
+	Once   bool
+}{}
+
+var replayCommand = &cobra.Command{
+	Use:   "replay TRACEFILE",
+	Short: "Replay a recorded trace as mock partner",
+	Long: `Listens for connections and plays the partner of the session recorded in
+TRACEFILE (see --trace). Every buffer the recording side received is sent to the
+connecting client, every buffer it sent is expected from the client.`,
+	Example: `oftp2 --trace session.trace send O20222CUSTOMER /tmp/data DATA22
+oftp2 replay session.trace --listen localhost:3305 --once`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		replayTrace(args[0])
+	},
+}
+
+func init() {
+	replayCommand.Flags().StringVar(&replayOptions.Listen, "listen", "localhost:3305", "address to listen on")
+	replayCommand.Flags().BoolVar(&replayOptions.Strict, "strict", false, "compare complete buffers instead of command codes")
+	replayCommand.Flags().BoolVar(&replayOptions.Once, "once", false, "exit after the first replayed connection")
+}
+
+func replayTrace(path string) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		print(err.Error() + "\n")
+		os.Exit(1)
+	}
+
+	replayer, err := trace.NewReplayer(file)
+	file.Close()
+	if err != nil {
+		print(err.Error() + "\n")
+		os.Exit(1)
+	}
+
+	replayer.Strict = replayOptions.Strict
+
+	listener, err := net.Listen("tcp", replayOptions.Listen)
+	if err != nil {
+		print(err.Error() + "\n")
+		os.Exit(1)
+	}
+	defer listener.Close()
+
+	fmt.Printf("replaying %d records on %s\n", len(replayer.Records), listener.Addr())
+
+	for {
+		con, err := listener.Accept()
+		if err != nil {
+			print(err.Error() + "\n")
+			os.Exit(1)
+		}
+
+		err = replayer.ServeConn(con)
+		con.Close()
+
+		if err != nil {
+			fmt.Printf("%s: replay failed: %v\n", con.RemoteAddr(), err)
+		} else {
+			fmt.Printf("%s: replay completed\n", con.RemoteAddr())
+		}
+
+		if replayOptions.Once {
+			if err != nil {
+				os.Exit(1)
+			}
+			return
+		}
+	}
+}
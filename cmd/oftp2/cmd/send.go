@@ -1,70 +1,309 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/retry"
 )
 
+var sendManifest string
+var sendRetry *retryOptions
+var sendProgress bool
+
 var sendCommand = &cobra.Command{
-	Use:     "send ODETTEID FILEPATH DATASETNAME",
-	Short:   "Send file to server",
-	Long:    `Sends a file to the server`,
-	Example: `oftp2 send O20222CUSTOMER /tmp/data DATA22`,
+	Use:   "send ODETTEID FILEPATH DATASETNAME [FILEPATH DATASETNAME]...",
+	Short: "Send files to server",
+	Long: `Sends one or more files to the server within a single session.
+
+The files are either given on the command line as pairs of file path and
+dataset name, all for the same receiving Odette ID, or in a manifest file. Each
+line of the manifest describes one file:
+
+    ODETTEID FILEPATH DATASETNAME [PRIORITY]
+
+Empty lines and lines starting with # are ignored. Files with a higher priority
+are sent first (default 0), files with the same priority in the given order.
+
+Files refused by the partner with a temporary error (e.g. SFNA with the retry
+indicator set or ESID "resources not available") are tried again in a new
+session after an exponential back-off if --retries is given. For files failing
+permanently, the reason code of the equivalent negative end response (NERP) is
+printed.
+
+The exit status is taken from the first file that failed.
+` + exitCodeDoc,
+	Example: `oftp2 send O20222CUSTOMER /tmp/data DATA22
+oftp2 send O20222CUSTOMER /tmp/data1 DATA1 /tmp/data2 DATA2
+oftp2 send --manifest /tmp/outbox.txt`,
 	Args: func(cmd *cobra.Command, args []string) error {
-		if len(args) < 3 {
-			return errors.New("please provide an receiving ODETTE ID, a file path and a dataset name\n")
+		if sendManifest != "" {
+			if len(args) > 0 {
+				return errors.New("please provide either files or a manifest\n")
+			}
+			return nil
+		}
+		if len(args) < 3 || len(args)%2 != 1 {
+			return errors.New("please provide an receiving ODETTE ID and pairs of file path and dataset name\n")
 		}
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		sendFile(args[0], args[1], args[2])
+		var files []manifestEntry
+		var err error
+
+		if sendManifest != "" {
+			files, err = readManifest(sendManifest)
+			if err != nil {
+				print(err.Error() + "\n")
+				os.Exit(1)
+			}
+		} else {
+			for i := 1; i < len(args); i += 2 {
+				files = append(files, manifestEntry{Destination: args[0], Path: args[i], DatasetName: args[i+1]})
+			}
+		}
+
+		sendFiles(files)
 	},
 }
 
-func sendFile(odetteId, filePath, datasetName string) {
+func init() {
+	sendCommand.Flags().StringVar(&sendManifest, "manifest", "", "file listing the files to send")
+	sendRetry = addRetryFlags(sendCommand, 0)
+	sendCommand.Flags().BoolVar(&sendProgress, "progress", false, "show the progress of the transfers on stderr")
+}
 
-	s := client.OFTP2Client{
-		ServerHost: activeOptions.Server,
-		ServerPort: activeOptions.Port,
-		Verbose:    activeOptions.Verbose,
-		OdetteId:   activeOptions.OdetteId,
-	}
+// manifestEntry is a single file to be sent
+type manifestEntry struct {
+	Destination string
+	Path        string
+	DatasetName string
+	Priority    int
+}
 
-	err := s.Connect()
+// readManifest reads the list of files to send from the manifest file. Each
+// listed file must exist.
+func readManifest(path string) ([]manifestEntry, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	defer file.Close()
 
-	err = s.StartSession("", false, false, false)
-	if err != nil {
-		fmt.Printf("start session failed: %v\n", err)
-		os.Exit(1)
+	result := make([]manifestEntry, 0)
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 || len(fields) > 4 {
+			return nil, errors.New(fmt.Sprintf("%s:%d: expected ODETTEID FILEPATH DATASETNAME [PRIORITY]", path, lineNumber))
+		}
+
+		entry := manifestEntry{Destination: fields[0], Path: fields[1], DatasetName: fields[2]}
+
+		if len(fields) == 4 {
+			entry.Priority, err = strconv.Atoi(fields[3])
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("%s:%d: invalid priority %s", path, lineNumber, fields[3]))
+			}
+		}
+
+		if _, err := os.Stat(entry.Path); err != nil {
+			return nil, errors.New(fmt.Sprintf("%s:%d: %v", path, lineNumber, err))
+		}
+
+		result = append(result, entry)
+	}
+
+	return result, scanner.Err()
+}
+
+func sendFiles(files []manifestEntry) {
+
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	pending := make([]client.QueuedFile, 0, len(files))
+
+	for _, f := range files {
+		queued, err := client.NewQueuedFile(f.Path, client.VirtualFile{
+			DatasetName: f.DatasetName,
+			Destination: f.Destination,
+			Format:      client.FileFormatUnstructured,
+		}, f.Priority)
+
+		if err != nil {
+			fmt.Printf("cannot send %s: %v\n", f.Path, err)
+			os.Exit(1)
+		}
+
+		pending = append(pending, queued)
+	}
+
+	tracker := retry.NewTracker(sendRetry.policy())
+	var firstFailure error
+
+	for len(pending) > 0 {
+		results, remaining, err := sendSession(ctx, pending)
+
+		retries := make([]client.QueuedFile, 0)
+		var nextAttempt time.Time
+
+		// retryLater checks if the file may be retried and remembers the time
+		// of the earliest retry
+		retryLater := func(f client.QueuedFile) bool {
+			next, ok := tracker.Failed(f.Id, time.Now())
+			if ok && (nextAttempt.IsZero() || next.Before(nextAttempt)) {
+				nextAttempt = next
+			}
+			return ok
+		}
+
+		// files that failed on their own
+		for _, r := range results {
+			if r.Err == nil {
+				fmt.Printf("%s: sent %s\n", r.File.File.DatasetName, formatStats(r.Stats))
+				continue
+			}
+
+			if client.IsRetryable(r.Err) {
+				if retryLater(r.File) {
+					fmt.Printf("%s: will be retried: %v\n", r.File.File.DatasetName, r.Err)
+					retries = append(retries, r.File)
+					continue
+				}
+			}
+
+			if firstFailure == nil {
+				firstFailure = r.Err
+			}
+			printFailure(r.File.File, r.Err)
+		}
+
+		// files not sent because the session failed
+		if err != nil {
+			fmt.Printf("session failed: %v\n", err)
+
+			for _, f := range remaining {
+				if client.IsRetryable(err) {
+					if retryLater(f) {
+						retries = append(retries, f)
+						continue
+					}
+				}
+
+				if firstFailure == nil {
+					firstFailure = err
+				}
+				printFailure(f.File, err)
+			}
+		}
+
+		pending = retries
+		if len(pending) == 0 {
+			break
+		}
+
+		// wait until the first file may be tried again
+		wait := time.Until(nextAttempt)
+		fmt.Printf("retrying %d file(s) in %v\n", len(pending), wait)
+
+		select {
+		case <-ctx.Done():
+			fmt.Printf("cancelled: %v\n", ctx.Err())
+			os.Exit(1)
+		case <-time.After(wait):
+		}
+	}
+
+	os.Exit(exitCode(firstFailure))
+}
+
+// sendSession sends the files within one session. It returns the results of
+// the files that were tried and the files that were not tried because the
+// session failed.
+func sendSession(ctx context.Context, files []client.QueuedFile) ([]client.QueueResult, []client.QueuedFile, error) {
+
+	c := newClient()
+
+	if sendProgress {
+		progress := &progressBar{out: os.Stderr}
+		c.Observer = progress.observe
 	}
 
-	err = s.SendFile(datasetName,
-		filePath,
-		client.FileFormatUnstructured,
-		//"O2010CUSTOMER",
-		odetteId,
-		client.SecurityLevelNone,
-		false,
-		false,
-		false,
-		false)
+	s, err := c.Open(ctx, "", false, false, false)
 	if err != nil {
-		fmt.Printf("send file failed: %v\n", err)
-		os.Exit(1)
+		return nil, files, err
+	}
+	defer s.Close()
+
+	queue := client.NewSendQueue()
+	for _, f := range files {
+		queue.Push(s.PartnerId(), f)
 	}
 
-	err = s.EndSession()
+	// remaining returns the files left in the queue
+	remaining := func() []client.QueuedFile {
+		result := make([]client.QueuedFile, 0)
+		for f, ok := queue.Pop(s.PartnerId()); ok; f, ok = queue.Pop(s.PartnerId()) {
+			result = append(result, f)
+		}
+		return result
+	}
+
+	results := make([]client.QueueResult, 0)
+	for {
+		sent, err := s.DrainQueue(ctx, queue)
+		results = append(results, sent...)
+		if err != nil {
+			return results, remaining(), err
+		}
+
+		if queue.Len(s.PartnerId()) == 0 {
+			break
+		}
+
+		// the partner requested the right to speak before all files were sent,
+		// it is handed back after the files of the partner were refused
+		exchange, err := s.ReceiveFiles(ctx, refusingInbox{})
+		if err != nil {
+			return results, remaining(), err
+		}
+		if exchange.SessionEnded {
+			return results, remaining(), client.ErrNotConnected
+		}
+	}
+
+	err = s.End(ctx)
 	if err != nil {
+		// all files have been sent, only the end of the session failed
 		fmt.Printf("end session failed: %v\n", err)
-		os.Exit(1)
 	}
 
-	s.Close()
+	return results, nil, nil
+}
+
+// refusingInbox refuses the files offered by the partner, the send command
+// does not receive files
+type refusingInbox struct{}
+
+func (refusingInbox) Create(file client.VirtualFile) (client.InboxFile, error) {
+	return nil, &client.FileRefusal{ReasonCode: 99, ReasonText: "Files are not received by the send command."}
 }
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadManifest(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.txt")
+	if err := ioutil.WriteFile(data, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.txt")
+
+	tests := []struct {
+		name     string
+		manifest string
+		expected []manifestEntry
+		err      string
+	}{
+		{
+			name:     "valid entries",
+			manifest: "# files for the partner\n\nO0013000000PARTNER " + data + " FIRST\n  O0013000000OTHER " + data + " SECOND 7  \n",
+			expected: []manifestEntry{
+				{Destination: "O0013000000PARTNER", Path: data, DatasetName: "FIRST"},
+				{Destination: "O0013000000OTHER", Path: data, DatasetName: "SECOND", Priority: 7},
+			},
+		},
+		{
+			name:     "empty",
+			manifest: "# nothing to send\n",
+			expected: []manifestEntry{},
+		},
+		{
+			name:     "bad priority",
+			manifest: "O0013000000PARTNER " + data + " FIRST\nO0013000000PARTNER " + data + " SECOND high\n",
+			err:      ":2: invalid priority high",
+		},
+		{
+			name:     "missing fields",
+			manifest: "O0013000000PARTNER " + data + "\n",
+			err:      ":1: expected ODETTEID FILEPATH DATASETNAME [PRIORITY]",
+		},
+		{
+			name:     "missing file",
+			manifest: "O0013000000PARTNER " + data + " FIRST\n\nO0013000000PARTNER " + missing + " SECOND\n",
+			err:      ":3: stat " + missing,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, "manifest"+string(rune('0'+i)))
+			if err := ioutil.WriteFile(path, []byte(test.manifest), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := readManifest(path)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), path+test.err) {
+					t.Fatalf("expected error %q, got %v", path+test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(entries, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, entries)
+			}
+		})
+	}
+}
+
+func TestReadManifestNotFound(t *testing.T) {
+	_, err := readManifest(filepath.Join(t.TempDir(), "manifest"))
+	if !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
@@ -1,7 +1,10 @@
 module github.com/thomsmits/oftp2-client
 
-go 1.15
+go 1.18
+
+require github.com/spf13/cobra v1.1.1
 
 require (
-	github.com/spf13/cobra v1.1.1
+	github.com/inconshreveable/mousetrap v1.0.0 // indirect
+	github.com/spf13/pflag v1.0.5 // indirect
 )
@@ -0,0 +1,353 @@
+package capability
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+)
+
+// Limits of the session parameters, see RFC 5024 5.3.2
+const (
+	minBufferSize = 128
+	maxBufferSize = 99999
+	minCredit     = 1
+	maxCredit     = 999
+)
+
+// Offsets of the fields of an SSID in the buffer seen by the Fuzzer, which
+// starts with the stream transmission header
+const (
+	ssidBufferSizeOffset = wire.StreamHeaderLength + 1 + 1 + 25 + 8
+	ssidCreditOffset     = ssidBufferSizeOffset + 5 + 4
+)
+
+// cipherSuites are the cipher suites probed with a Start File command
+var cipherSuites = []struct {
+	code int
+	name string
+}{
+	{1, "3DES_EDE_CBC_3KEY, RSA_PKCS1_15, SHA-1"},
+	{2, "AES_256_CBC, RSA_PKCS1_15, SHA-1"},
+	{3, "AES_256_CBC, RSA_PKCS1_15, SHA-256"},
+	{4, "AES_256_CBC, RSA_PKCS1_15, SHA-512"},
+}
+
+// Target is the partner whose capabilities are determined
+type Target struct {
+	// Client is the configuration used for the sessions. Each probe uses a
+	// copy of it, the Fuzzer is set by the probes.
+	Client client.OFTP2Client
+
+	// Password sent in the SSID
+	Password string
+
+	// ProbeFiles sends empty files to find out which cipher suites and which
+	// file compression the partner accepts
+	ProbeFiles bool
+}
+
+// Outcome is the answer of the partner to a probe
+type Outcome struct {
+	// Accepted tells whether the partner accepted the probe
+	Accepted bool `json:"accepted"`
+
+	// Note describes the answer of the partner, e.g. the reason of a refusal
+	Note string `json:"note,omitempty"`
+}
+
+// Limits are the answers of the partner to the extreme values of buffer size
+// and credit
+type Limits struct {
+	// MaxBufferSize and MaxCredit are the values the partner answered to the
+	// largest values allowed
+	MaxBufferSize uint32 `json:"max_buffer_size"`
+	MaxCredit     uint32 `json:"max_credit"`
+
+	// Maximum is the answer to the offer of the largest values
+	Maximum Outcome `json:"maximum"`
+
+	// Minimum is the answer to the offer of the smallest values
+	Minimum Outcome `json:"minimum"`
+}
+
+// FileProbe is the answer of the partner to a Start File command
+type FileProbe struct {
+	// DatasetName of the empty file sent
+	DatasetName string `json:"dataset_name"`
+
+	// CipherSuite announced for the file, 0 if only compression is probed
+	CipherSuite int `json:"cipher_suite"`
+
+	// Description of the cipher suite
+	Description string `json:"description"`
+
+	// Compressed tells whether the file was announced as compressed
+	Compressed bool `json:"compressed"`
+
+	Outcome
+}
+
+// Report describes the capabilities of the partner
+type Report struct {
+	// Endpoint the partner was reached at
+	Endpoint string `json:"endpoint"`
+
+	// PartnerId is the Odette ID the partner sent in its SSID
+	PartnerId string `json:"partner_id"`
+
+	// ProtocolLevel and ProtocolRelease of the SSID of the partner. They are
+	// empty if the partner refused the session.
+	ProtocolLevel   string `json:"protocol_level"`
+	ProtocolRelease string `json:"protocol_release"`
+
+	// Login is the answer of the partner to our Odette ID and password
+	Login Outcome `json:"login"`
+
+	// Capability of the partner: S (send only), R (receive only) or B (both)
+	Capability string `json:"capability"`
+
+	// Compression and Restart tell whether the partner agreed to buffer
+	// compression and restart
+	Compression bool `json:"compression"`
+	Restart     bool `json:"restart"`
+
+	// SecureAuthentication is the answer to the request for secure
+	// authentication
+	SecureAuthentication Outcome `json:"secure_authentication"`
+
+	// Limits are the answers to the extreme values of buffer size and credit
+	Limits Limits `json:"limits"`
+
+	// TLS describes the TLS setup of the partner, it is nil without TLS
+	TLS *TLSReport `json:"tls,omitempty"`
+
+	// Files are the answers to the Start File commands probing cipher suites
+	// and compression
+	Files []FileProbe `json:"file_probes,omitempty"`
+
+	// endpoint is used for all sessions after the first one
+	endpoint client.Endpoint
+}
+
+// Probe determines the capabilities of the partner. An error is returned if
+// the partner cannot be reached, refusals of the partner are part of the
+// report. The probes after the first one are only run if the partner accepted
+// our Odette ID and password.
+func Probe(ctx context.Context, target *Target) (*Report, error) {
+	report := &Report{}
+
+	err := report.probeSession(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	// the following sessions use the endpoint that answered
+	probeTarget := *target
+	probeTarget.Client.ServerHost = report.endpoint.Host
+	probeTarget.Client.ServerPort = report.endpoint.Port
+	probeTarget.Client.FallbackEndpoints = nil
+
+	if report.Login.Accepted {
+		report.probeLimits(ctx, &probeTarget)
+		report.probeAuthentication(ctx, &probeTarget)
+
+		if target.ProbeFiles {
+			report.probeFiles(ctx, &probeTarget)
+		}
+	}
+
+	if report.TLS != nil {
+		report.TLS.probe(ctx, &probeTarget)
+	}
+
+	return report, nil
+}
+
+// probeSession starts a session with our Odette ID and password, requesting
+// buffer compression and restart
+func (r *Report) probeSession(ctx context.Context, target *Target) error {
+	c := target.Client
+	s, err := c.Dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	r.endpoint = s.Endpoint()
+	r.Endpoint = r.endpoint.String()
+	if state, ok := s.TLSConnectionState(); ok {
+		r.TLS = newTLSReport(state)
+	}
+
+	err = s.Start(ctx, target.Password, true, true, false)
+
+	var levelError *session.LevelError
+	var ended *client.SessionEndedError
+	switch {
+	case errors.As(err, &levelError):
+		r.ProtocolLevel = levelError.Level
+		r.Login = Outcome{Note: fmt.Sprintf("protocol level %s is not supported by the client", levelError.Level)}
+	case errors.As(err, &ended):
+		r.Login = Outcome{Note: fmt.Sprintf("refused with ESID reason %02d (%s): %s", ended.ReasonCode, ended.Reason, ended.Text)}
+	case err != nil:
+		return err
+	default:
+		p := s.Parameters()
+		r.ProtocolLevel = session.ProtocolLevel
+		r.Login = Outcome{Accepted: true}
+		r.PartnerId = p.Id
+		r.Capability = p.Capability
+		r.Compression = p.Compression
+		r.Restart = p.Restart
+		_ = s.End(ctx)
+	}
+
+	if r.ProtocolLevel != "" {
+		r.ProtocolRelease = session.ReleaseName(r.ProtocolLevel)
+	}
+	return nil
+}
+
+// probeLimits offers the largest and the smallest buffer size and credit
+func (r *Report) probeLimits(ctx context.Context, target *Target) {
+	s, err := open(ctx, target, false, sessionParameters(maxBufferSize, maxCredit))
+	if err != nil {
+		r.Limits.Maximum = Outcome{Note: err.Error()}
+	} else {
+		p := s.Parameters()
+		r.Limits.MaxBufferSize = p.BufferSize
+		r.Limits.MaxCredit = p.Credit
+		r.Limits.Maximum = Outcome{Accepted: true, Note: fmt.Sprintf("offered buffer size %d, credit %d", maxBufferSize, maxCredit)}
+		end(ctx, s)
+	}
+
+	s, err = open(ctx, target, false, sessionParameters(minBufferSize, minCredit))
+	if err != nil {
+		r.Limits.Minimum = Outcome{Note: err.Error()}
+	} else {
+		r.Limits.Minimum = Outcome{Accepted: true, Note: fmt.Sprintf("offered buffer size %d, credit %d", minBufferSize, minCredit)}
+		end(ctx, s)
+	}
+}
+
+// probeAuthentication requests secure authentication. The partner has to
+// answer with the same flag or refuse the session; the authentication itself
+// needs certificates and is not performed.
+func (r *Report) probeAuthentication(ctx context.Context, target *Target) {
+	s, err := open(ctx, target, true, nil)
+
+	var ended *client.SessionEndedError
+	switch {
+	case errors.As(err, &ended):
+		r.SecureAuthentication = Outcome{Note: fmt.Sprintf("refused with ESID reason %02d (%s)", ended.ReasonCode, ended.Reason)}
+	case errors.Is(err, client.ErrAuthenticationMismatch):
+		r.SecureAuthentication = Outcome{Note: "answered without secure authentication"}
+	case err != nil:
+		r.SecureAuthentication = Outcome{Note: err.Error()}
+	default:
+		r.SecureAuthentication = Outcome{Accepted: true, Note: "agreed, the authentication itself was not performed"}
+		end(ctx, s)
+	}
+}
+
+// probeFiles sends empty files announced with each cipher suite and with
+// compression. All files are sent in one session as long as the partner does
+// not end it.
+func (r *Report) probeFiles(ctx context.Context, target *Target) {
+	probes := make([]FileProbe, 0, len(cipherSuites)+1)
+	for _, suite := range cipherSuites {
+		probes = append(probes, FileProbe{
+			DatasetName: fmt.Sprintf("OFTP2.PROBE.CIPHER%02d", suite.code),
+			CipherSuite: suite.code,
+			Description: suite.name,
+		})
+	}
+	probes = append(probes, FileProbe{DatasetName: "OFTP2.PROBE.COMPRESSED", Description: "ZLIB", Compressed: true})
+
+	var s *client.Session
+	defer func() {
+		if s != nil {
+			end(ctx, s)
+		}
+	}()
+
+	for _, probe := range probes {
+		if s == nil {
+			var err error
+			s, err = open(ctx, target, false, nil)
+			if err != nil {
+				probe.Outcome = Outcome{Note: err.Error()}
+				r.Files = append(r.Files, probe)
+				continue
+			}
+		}
+
+		file := client.VirtualFile{
+			DatasetName: probe.DatasetName,
+			Destination: r.PartnerId,
+			CipherSuite: probe.CipherSuite,
+			Compressed:  probe.Compressed,
+		}
+		if probe.CipherSuite != 0 {
+			file.SecurityLevel = client.SecurityLevelSigned
+		}
+
+		err := s.SendStream(ctx, file, bytes.NewReader(nil), 0)
+
+		var sfna *client.StartFileRejectedError
+		var efna *client.EndFileRejectedError
+		switch {
+		case err == nil:
+			probe.Outcome = Outcome{Accepted: true, Note: "empty file delivered"}
+		case errors.As(err, &efna):
+			probe.Outcome = Outcome{Accepted: true, Note: fmt.Sprintf("empty file refused with EFNA reason %02d (%s)", efna.ReasonCode, efna.Reason)}
+		case errors.As(err, &sfna):
+			probe.Outcome = Outcome{Note: fmt.Sprintf("refused with SFNA reason %02d (%s): %s", sfna.ReasonCode, sfna.Reason, sfna.Text)}
+		default:
+			probe.Outcome = Outcome{Note: err.Error()}
+		}
+
+		if s.Phase() != client.PhaseSession {
+			// the session is broken, the next probe opens a new one
+			_ = s.Close()
+			s = nil
+		}
+
+		r.Files = append(r.Files, probe)
+	}
+}
+
+// open starts a session with the partner, using the fuzzer if it is not nil
+func open(ctx context.Context, target *Target, authentication bool, fuzzer func([]byte) []byte) (*client.Session, error) {
+	c := target.Client
+	c.Fuzzer = fuzzer
+	return c.Open(ctx, target.Password, false, false, authentication)
+}
+
+// end ends the session, errors are ignored as the probe is complete
+func end(ctx context.Context, s *client.Session) {
+	if s.Phase() == client.PhaseSession {
+		_ = s.End(ctx)
+	}
+	_ = s.Close()
+}
+
+// sessionParameters patches the buffer size and credit of the SSID sent by the
+// client
+func sessionParameters(bufferSize, credit int) func([]byte) []byte {
+	return func(data []byte) []byte {
+		if len(data) < ssidCreditOffset+3 || string(data[wire.StreamHeaderLength:wire.StreamHeaderLength+1]) != session.SSIDCMD {
+			return data
+		}
+
+		patched := append([]byte(nil), data...)
+		copy(patched[ssidBufferSizeOffset:], fmt.Sprintf("%05d", bufferSize))
+		copy(patched[ssidCreditOffset:], fmt.Sprintf("%03d", credit))
+		return patched
+	}
+}
@@ -0,0 +1,218 @@
+package capability_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/capability"
+	"github.com/thomsmits/oftp2-client/oftp2"
+	"github.com/thomsmits/oftp2-client/oftp2/oftp2test"
+)
+
+const clientId = "O0013000000CLIENT"
+
+func TestProbe(t *testing.T) {
+	partner := &oftp2test.Partner{
+		Restart:     true,
+		Compression: true,
+		StartFile: func(file oftp2.VirtualFile) oftp2test.Answer {
+			if file.CipherSuite == 1 {
+				return oftp2test.Reject(3, "cipher suite not supported")
+			}
+			return oftp2test.Accept()
+		},
+	}
+	target := &capability.Target{Client: *partner.Client(clientId), ProbeFiles: true}
+
+	report, err := capability.Probe(context.Background(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Login.Accepted || report.ProtocolLevel != "5" || report.ProtocolRelease != "Revision 2.0" {
+		t.Errorf("wrong login: %+v", report)
+	}
+	if report.PartnerId != oftp2test.DefaultOdetteId || report.Capability != "B" || !report.Compression || !report.Restart {
+		t.Errorf("wrong parameters: %+v", report)
+	}
+	if report.SecureAuthentication.Accepted || report.SecureAuthentication.Note != "answered without secure authentication" {
+		t.Errorf("wrong secure authentication: %v", report.SecureAuthentication)
+	}
+
+	limits := report.Limits
+	if limits.MaxBufferSize != oftp2test.DefaultBufferSize || limits.MaxCredit != oftp2test.DefaultCredit ||
+		!limits.Maximum.Accepted || !limits.Minimum.Accepted {
+		t.Errorf("wrong limits: %+v", limits)
+	}
+
+	if len(report.Files) != 5 {
+		t.Fatalf("wrong file probes: %+v", report.Files)
+	}
+	for _, probe := range report.Files {
+		if probe.Accepted != (probe.CipherSuite != 1) {
+			t.Errorf("wrong outcome for %s: %v", probe.DatasetName, probe.Outcome)
+		}
+	}
+	if !report.Files[4].Compressed || report.TLS != nil {
+		t.Errorf("wrong report: %+v", report)
+	}
+
+	if err = partner.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the accepted probes are delivered as empty files
+	if n := len(partner.Received()); n != 4 {
+		t.Errorf("expected 4 files, got %d", n)
+	}
+}
+
+func TestProbeLoginRefused(t *testing.T) {
+	partner := &oftp2test.Partner{ExpectedPassword: "SECRET"}
+	target := &capability.Target{Client: *partner.Client(clientId), Password: "WRONG", ProbeFiles: true}
+
+	report, err := capability.Probe(context.Background(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Login.Accepted || !strings.Contains(report.Login.Note, "ESID reason 04") || report.ProtocolLevel != "" {
+		t.Errorf("wrong login: %v", report.Login)
+	}
+	if len(report.Files) != 0 || report.Limits.Maximum.Accepted {
+		t.Errorf("probes run after refused login: %+v", report)
+	}
+}
+
+func TestProbeUnreachable(t *testing.T) {
+	partner := &oftp2test.Partner{}
+	c := partner.Client(clientId)
+	c.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: context.DeadlineExceeded}
+	}
+
+	if _, err := capability.Probe(context.Background(), &capability.Target{Client: *c}); err == nil {
+		t.Error("no error for an unreachable partner")
+	}
+}
+
+func TestProbeTLS(t *testing.T) {
+	serverTLS, clientTLS := certificate(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	partner := &oftp2test.Partner{}
+	go func() { _ = partner.ServeListener(tls.NewListener(listener, serverTLS)) }()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	c := oftp2.Client{ServerHost: addr.IP.String(), ServerPort: addr.Port, OdetteId: clientId, TLSConfig: clientTLS}
+
+	report, err := capability.Probe(context.Background(), &capability.Target{Client: c})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := report.TLS
+	if r == nil || r.Version != "TLS 1.3" || len(r.Certificates) != 1 || r.Certificates[0].Subject != "CN=partner" {
+		t.Fatalf("wrong TLS report: %+v", r)
+	}
+
+	accepted := make(map[string]bool)
+	for _, version := range r.Versions {
+		accepted[version.Version] = version.Accepted
+	}
+	if len(r.Versions) != 4 || !accepted["TLS 1.2"] || !accepted["TLS 1.3"] {
+		t.Errorf("wrong TLS versions: %+v", r.Versions)
+	}
+
+	// only the cipher suites for the ECDSA certificate are accepted
+	if len(r.CipherSuites) == 0 {
+		t.Errorf("no cipher suites")
+	}
+	for _, suite := range r.CipherSuites {
+		if !strings.Contains(suite.Name, "ECDSA") {
+			t.Errorf("cipher suite %s accepted", suite.Name)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	partner := &oftp2test.Partner{}
+	report, err := capability.Probe(context.Background(), &capability.Target{Client: *partner.Client(clientId)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var text bytes.Buffer
+	if err = report.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"Login                   : accepted\n", "Protocol level          : 5 (Revision 2.0)\n", "Max credit              : 8\n"} {
+		if !strings.Contains(text.String(), expected) {
+			t.Errorf("%q missing in\n%s", expected, text.String())
+		}
+	}
+
+	var document bytes.Buffer
+	if err = report.WriteJSON(&document); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := make(map[string]interface{})
+	if err = json.Unmarshal(document.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["partner_id"] != oftp2test.DefaultOdetteId || decoded["limits"].(map[string]interface{})["max_buffer_size"] != float64(oftp2test.DefaultBufferSize) {
+		t.Errorf("wrong JSON document:\n%s", document.String())
+	}
+}
+
+// certificate creates a self-signed certificate for 127.0.0.1 and returns
+// the configurations for the server and the client
+func certificate(t *testing.T) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "partner"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	return server, &tls.Config{RootCAs: roots}
+}
@@ -0,0 +1,14 @@
+// The capability package determines what an OFTP2 partner supports, e.g. to
+// complete the onboarding checklist of a new partner.
+//
+// The probe opens several sessions with the partner. It checks whether the
+// partner accepts our Odette ID and password, which protocol release it speaks,
+// which session parameters it supports including the extremes of buffer size
+// and credit, and whether it agrees to secure authentication. If the partner is
+// reached through TLS, the TLS versions and the TLS 1.2 cipher suites accepted
+// by the partner are probed and its certificate chain is reported.
+//
+// Optionally the cipher suites and the file compression the partner accepts in
+// a Start File command are probed. This sends empty files named OFTP2.PROBE.*
+// to the partner.
+package capability
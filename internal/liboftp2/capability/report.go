@@ -0,0 +1,90 @@
+package capability
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// capabilityNames describes the values of SSIDSR
+var capabilityNames = map[string]string{
+	"S": "send only",
+	"R": "receive only",
+	"B": "send and receive",
+}
+
+// String returns the outcome as "accepted" or "refused" followed by the note
+func (o Outcome) String() string {
+	result := "refused"
+	if o.Accepted {
+		result = "accepted"
+	}
+	if o.Note != "" {
+		result += ": " + o.Note
+	}
+	return result
+}
+
+// WriteJSON writes the report as indented JSON document
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes the report for humans
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	line := func(label string, format string, args ...interface{}) {
+		fmt.Fprintf(&b, "%-24s: %s\n", label, fmt.Sprintf(format, args...))
+	}
+
+	line("Endpoint", "%s", r.Endpoint)
+	line("Login", "%v", r.Login)
+	if r.ProtocolLevel != "" {
+		line("Protocol level", "%s (%s)", r.ProtocolLevel, r.ProtocolRelease)
+	}
+
+	if r.Login.Accepted {
+		line("OdetteId", "%s", r.PartnerId)
+		line("Capability", "%s (%s)", r.Capability, capabilityNames[r.Capability])
+		line("Compression supported", "%t", r.Compression)
+		line("Restart supported", "%t", r.Restart)
+		line("Secure authentication", "%v", r.SecureAuthentication)
+		line("Max buffer size", "%d", r.Limits.MaxBufferSize)
+		line("Max credit", "%d", r.Limits.MaxCredit)
+		line("Largest values", "%v", r.Limits.Maximum)
+		line("Smallest values", "%v", r.Limits.Minimum)
+	}
+
+	if r.TLS != nil {
+		line("TLS", "%s, %s", r.TLS.Version, r.TLS.CipherSuite)
+		for _, version := range r.TLS.Versions {
+			line("  "+version.Version, "%v", version.Outcome)
+		}
+		for _, suite := range r.TLS.CipherSuites {
+			insecure := ""
+			if suite.Insecure {
+				insecure = " (insecure)"
+			}
+			line("  TLS 1.2 cipher suite", "%s%s", suite.Name, insecure)
+		}
+		for i, cert := range r.TLS.Certificates {
+			line(fmt.Sprintf("  Certificate %d", i+1), "%s, issued by %s, valid %s to %s", cert.Subject, cert.Issuer,
+				cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+		}
+	}
+
+	for _, probe := range r.Files {
+		label := "File compression"
+		if probe.CipherSuite != 0 {
+			label = fmt.Sprintf("File cipher suite %02d", probe.CipherSuite)
+		}
+		line(label, "%v", probe.Outcome)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
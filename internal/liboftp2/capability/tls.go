@@ -0,0 +1,151 @@
+package capability
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+)
+
+// tlsVersions are the TLS versions probed
+var tlsVersions = []struct {
+	id   uint16
+	name string
+}{
+	{tls.VersionTLS10, "TLS 1.0"},
+	{tls.VersionTLS11, "TLS 1.1"},
+	{tls.VersionTLS12, "TLS 1.2"},
+	{tls.VersionTLS13, "TLS 1.3"},
+}
+
+// TLSReport describes the TLS setup of the partner
+type TLSReport struct {
+	// Version and CipherSuite negotiated with the TLS configuration of the
+	// client
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipher_suite"`
+
+	// Versions are the answers to the offer of a single TLS version
+	Versions []TLSVersion `json:"versions"`
+
+	// CipherSuites are the TLS 1.2 cipher suites the partner accepted. Only
+	// the cipher suites the client implements can be probed.
+	CipherSuites []TLSCipherSuite `json:"cipher_suites"`
+
+	// Certificates is the chain presented by the partner, starting with its
+	// own certificate
+	Certificates []Certificate `json:"certificates"`
+}
+
+// TLSVersion is the answer of the partner to the offer of a TLS version
+type TLSVersion struct {
+	Version string `json:"version"`
+
+	Outcome
+}
+
+// TLSCipherSuite is a cipher suite accepted by the partner
+type TLSCipherSuite struct {
+	Name string `json:"name"`
+
+	// Insecure tells whether the cipher suite has known security issues
+	Insecure bool `json:"insecure"`
+}
+
+// Certificate describes a certificate presented by the partner
+type Certificate struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	DNSNames  []string  `json:"dns_names,omitempty"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+// newTLSReport describes the TLS connection of the first session
+func newTLSReport(state tls.ConnectionState) *TLSReport {
+	r := &TLSReport{
+		Version:     versionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+	}
+
+	for _, cert := range state.PeerCertificates {
+		r.Certificates = append(r.Certificates, Certificate{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			DNSNames:  cert.DNSNames,
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+		})
+	}
+
+	return r
+}
+
+// probe connects with each TLS version and with each TLS 1.2 cipher suite
+// on its own
+func (r *TLSReport) probe(ctx context.Context, target *Target) {
+	for _, version := range tlsVersions {
+		config := target.Client.TLSConfig.Clone()
+		config.MinVersion = version.id
+		config.MaxVersion = version.id
+
+		state, err := handshake(ctx, target, config)
+		if err != nil {
+			r.Versions = append(r.Versions, TLSVersion{Version: version.name, Outcome: Outcome{Note: err.Error()}})
+			continue
+		}
+		r.Versions = append(r.Versions, TLSVersion{Version: version.name, Outcome: Outcome{Accepted: true, Note: tls.CipherSuiteName(state.CipherSuite)}})
+	}
+
+	suites := append(tls.CipherSuites(), tls.InsecureCipherSuites()...)
+	for _, suite := range suites {
+		if !supportsVersion(suite, tls.VersionTLS12) {
+			continue
+		}
+
+		config := target.Client.TLSConfig.Clone()
+		config.MinVersion = tls.VersionTLS12
+		config.MaxVersion = tls.VersionTLS12
+		config.CipherSuites = []uint16{suite.ID}
+
+		if _, err := handshake(ctx, target, config); err == nil {
+			r.CipherSuites = append(r.CipherSuites, TLSCipherSuite{Name: suite.Name, Insecure: suite.Insecure})
+		}
+	}
+}
+
+// handshake connects to the partner with the TLS configuration and waits for
+// its ready message
+func handshake(ctx context.Context, target *Target, config *tls.Config) (tls.ConnectionState, error) {
+	c := target.Client
+	c.TLSConfig = config
+
+	s, err := c.Dial(ctx)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer s.Close()
+
+	state, _ := s.TLSConnectionState()
+	return state, nil
+}
+
+// supportsVersion tells whether the cipher suite can be used with the version
+func supportsVersion(suite *tls.CipherSuite, version uint16) bool {
+	for _, v := range suite.SupportedVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// versionName returns the name of the TLS version
+func versionName(version uint16) string {
+	for _, v := range tlsVersions {
+		if v.id == version {
+			return v.name
+		}
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
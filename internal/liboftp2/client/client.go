@@ -1,14 +1,24 @@
 package client
 
 import (
-	"fmt"
+	"context"
+	"crypto/tls"
 	"net"
-	"strings"
+	"time"
 
-	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/logging"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/proxy"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/trace"
 )
 
-// OFTP2Client represents a communication facility to speak OFTP2 with a server.
+// OFTP2Client holds the configuration to speak OFTP2 with a server. Sessions
+// are opened with Dial or Open; they do not change the client, so it can be
+// used for several sessions, also at the same time. Changes to the
+// configuration apply to sessions opened afterwards.
+//
+// The deprecated methods, e.g. Connect and SendFile, are the exception: they
+// keep their session in the client. A client used with them has a single
+// session and must not be used concurrently.
 type OFTP2Client struct {
 	// ServerHost is the hostname of the server to connect to
 	ServerHost string
@@ -16,30 +26,105 @@ type OFTP2Client struct {
 	// ServerPort is the port of the server to connect to
 	ServerPort int
 
+	// FallbackEndpoints are further endpoints of the server, e.g. a backup
+	// system. They are tried in the given order if the connection to
+	// ServerHost and ServerPort or the ready message (SSRM) fails.
+	FallbackEndpoints []Endpoint
+
+	// ConnectTimeout limits the time to connect to a single endpoint,
+	// including the proxy and the TLS handshake. If it is zero,
+	// DefaultConnectTimeout is used.
+	ConnectTimeout time.Duration
+
+	// Dialer opens the connection to the server. If it is nil, a TCP
+	// connection to ServerHost and ServerPort is opened. It can be set to
+	// connect through other transports, e.g. to an in-memory partner in tests
+	// (see package oftp2test).
+	Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// Proxy is the SOCKS5 or HTTP CONNECT proxy the connection to the server
+	// is opened through (see proxy.Parse). If it is nil, the server is
+	// connected directly. The Dialer is used to connect to the proxy.
+	Proxy *proxy.Proxy
+
+	// TLSConfig secures the connection with TLS (OFTP2 over TLS, see RFC 5024,
+	// 10.1) if it is set. If it does not contain a ServerName, the host of the
+	// endpoint connected to, ServerHost or a fallback endpoint, is used to
+	// verify the certificate of the server.
+	TLSConfig *tls.Config
+
 	// OdetteId is the clients Odette ID (see GenerateOdetteId())
 	OdetteId string
 
-	// Verbose sets verbose output during communication with the server
+	// Capability is announced to the server in the start session command
+	// (SSID): CapabilitySend, CapabilityReceive or CapabilityBoth. A server
+	// sends files only to a client able to receive them. If it is empty,
+	// CapabilityBoth is used.
+	Capability string
+
+	// Verbose sets verbose output during communication with the server. It is
+	// only used if no Logger is set and writes debug messages to stderr.
 	Verbose bool
 
+	// Logger receives log messages about the communication with the server. If
+	// no logger is set, nothing is logged (see Verbose).
+	Logger logging.Logger
+
 	// Fuzzer is a function that gets each data package before it is sent to the
 	// server and can perform changes on it. Please note that the data shown to the
 	// fuzzer in data is the raw data that goes on the wire. Therefore, it contains
 	// all OFTP2 TCP headers, all sub record headers and so on. Simply writing to a
 	// location may invalidate the data from the OFTP2 protocol point of view, which
 	// may (or may not) be what you want.
-	Fuzzer                        func(data []byte) []byte
-	con                           *net.Conn // Network connection
-	serverId                      string    // Odette ID of the server we are talking to
-	serverPassword                string    // Server password
-	serverBufferSize              uint32    // Server's maximum buffer size
-	serverCapability              string    // Capabilities of the server
-	serverCompress                bool      // Server supports compression
-	serverRestartSupported        bool      // Server supports restart
-	serverSpecial                 bool      // Server supports special commands
-	serverCredit                  uint32    // Number of data buffers, server accepts before CDT command
-	serverAuthenticationSupported bool      // Server supports authentication
-	serverUserData                string    // User data string send by the server
+	Fuzzer func(data []byte) []byte
+
+	// ResponseTimeout is the time the client waits for the answer of the server
+	// to a command (response timer of the RFC). If it is zero,
+	// DefaultResponseTimeout is used. When the timer expires, the session is
+	// aborted with an ESID "Time out".
+	ResponseTimeout time.Duration
+
+	// InactivityTimeout is the time the client waits for the server to send a
+	// command on its own initiative, e.g. the ready message after connecting
+	// (inactivity timer of the RFC). If it is zero, DefaultInactivityTimeout is
+	// used. When the timer expires, the session is aborted with an ESID "Time
+	// out".
+	InactivityTimeout time.Duration
+
+	// Observer is called for the events of the session and its file transfers,
+	// e.g. to display the progress of a transfer. It is called synchronously
+	// and should return quickly.
+	Observer func(event Event)
+
+	// Trace records all exchange buffers sent to and received from the server.
+	// The buffers are recorded as they go over the wire, i.e. after the Fuzzer
+	// has been applied.
+	Trace *trace.Recorder
+
+	// current is the session of the deprecated methods which use the client
+	// as a session, e.g. Connect and SendFile. It is the only state changed
+	// after the client was configured and it is not guarded.
+	current *Session
+}
+
+// Capabilities of the client announced in the SSID (SSIDSR)
+const (
+	// CapabilitySend announces that the client only sends files
+	CapabilitySend = "S"
+
+	// CapabilityReceive announces that the client only receives files
+	CapabilityReceive = "R"
+
+	// CapabilityBoth announces that the client sends and receives files
+	CapabilityBoth = "B"
+)
+
+// capability returns the capability announced to the server
+func (c *OFTP2Client) capability() string {
+	if c.Capability != "" {
+		return c.Capability
+	}
+	return CapabilityBoth
 }
 
 // OFTP2FileFormat specifies the file formats supported by the protocol
@@ -79,9 +164,3 @@ const (
 func (o OFTP2FileFormat) String() string {
 	return string(o)
 }
-
-// GenerateOdetteId generates a RFC-compliant ODETTE id for the given
-// international code, organization code and computer address
-func GenerateOdetteId(intCode int, orgCode, subAddress string) string {
-	return strings.Trim(fmt.Sprintf("O%04d%s%s", intCode, wire.TruncateAndPadString(orgCode, 14), wire.TruncateAndPadString(subAddress, 6)), " ")
-}
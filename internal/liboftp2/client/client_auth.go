@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -8,11 +9,11 @@ import (
 	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/authentication"
 )
 
-// AnswerChallenge answers a challenge received from the server and then sends a
-// new challenge to the server for mutual authentication
-func (s *OFTP2Client) AnswerChallenge(answer, ownChallenge, expectedResult []byte) error {
+// AnswerChallenge answers a challenge received from the server and then sends
+// a new challenge to the server for mutual authentication
+func (s *Session) AnswerChallenge(ctx context.Context, answer, ownChallenge, expectedResult []byte) error {
 
-	if s.serverAuthenticationSupported == false {
+	if s.partner.SecureAuthentication == false {
 		return errors.New("server does not support authentication")
 	}
 
@@ -21,13 +22,13 @@ func (s *OFTP2Client) AnswerChallenge(answer, ownChallenge, expectedResult []byt
 		Response: answer,
 	}
 
-	err := s.write(aurp.Marshal())
+	err := s.write(ctx, aurp.Marshal())
 	if err != nil {
 		return err
 	}
 
 	// Read answer from communication partner
-	buffer, err := s.read()
+	buffer, err := s.read(ctx)
 
 	_, t, err := DetermineMessageType(buffer)
 	if err != nil {
@@ -45,13 +46,13 @@ func (s *OFTP2Client) AnswerChallenge(answer, ownChallenge, expectedResult []byt
 		Challenge: ownChallenge,
 	}
 
-	err = s.write(auch.Marshal())
+	err = s.write(ctx, auch.Marshal())
 	if err != nil {
 		return err
 	}
 
 	// Read answer from communication partner
-	buffer, err = s.read()
+	buffer, err = s.read(ctx)
 	if err != nil {
 		return err
 	}
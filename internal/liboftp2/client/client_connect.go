@@ -1,43 +1,200 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"net"
 	"strconv"
-	"strings"
 
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/logging"
 	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
 )
 
-// Connect connects to the OFTP2 server
-func (s *OFTP2Client) Connect() error {
+// Endpoint is an address the OFTP2 server can be reached at
+type Endpoint struct {
+	Host string
+	Port int
+}
+
+// String returns the endpoint as host:port, IPv6 addresses are enclosed in
+// brackets, e.g. [2001:db8::1]:3305
+func (e Endpoint) String() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// endpoints returns ServerHost and ServerPort followed by the fallback
+// endpoints
+func (c *OFTP2Client) endpoints() []Endpoint {
+	result := []Endpoint{{Host: c.ServerHost, Port: c.ServerPort}}
+	return append(result, c.FallbackEndpoints...)
+}
+
+// Dial connects to the OFTP2 server and waits for its ready message. The
+// context limits the time to establish the connection and to wait for the
+// ready message. The returned session has to be started with Session.Start.
+//
+// If the connection to ServerHost and ServerPort fails or the server does not
+// send its ready message, the FallbackEndpoints are tried in their order. The
+// error of the last endpoint is returned if none of them can be reached.
+func (c *OFTP2Client) Dial(ctx context.Context) (*Session, error) {
+	endpoints := c.endpoints()
+
+	var err error
+	for i, endpoint := range endpoints {
+		var s *Session
+		s, err = c.dialEndpoint(ctx, endpoint)
+		if err == nil {
+			return s, nil
+		}
+
+		if i < len(endpoints)-1 && ctx.Err() == nil {
+			c.baseLogger().Warn("endpoint not available, trying next", "remote", endpoint.String(),
+				"next", endpoints[i+1].String(), "error", err)
+		} else {
+			break
+		}
+	}
+
+	// the failure is reported once, not for every endpoint
+	newSession(c).sessionFailed(err)
+	return nil, err
+}
+
+// dialEndpoint connects to a single endpoint and waits for the ready message.
+// Failures are not reported to the observer.
+func (c *OFTP2Client) dialEndpoint(ctx context.Context, endpoint Endpoint) (*Session, error) {
+	s := newSession(c)
+	s.client.Observer = nil
 
 	// open TCP connection to server
-	addr := strings.Join([]string{s.ServerHost, strconv.Itoa(s.ServerPort)}, ":")
-	connection, err := net.Dial("tcp", addr)
+	addr := endpoint.String()
 
+	connectCtx, cancel := context.WithTimeout(ctx, c.connectTimeout())
+	connection, err := c.connect(connectCtx, endpoint)
+	cancel()
 	if err != nil {
-		return err
+		if ctx.Err() == nil && connectCtx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("%w: cannot connect to %s within %v", ErrTimeout, addr, c.connectTimeout())
+		}
+		return nil, err
 	}
 
-	s.con = &connection
+	s.con = connection
+	s.endpoint = endpoint
+	s.log = c.baseLogger().With(logging.KeySession, nextSessionId(), "remote", addr)
+	if c.Proxy != nil {
+		s.log = s.log.With("proxy", c.Proxy.String())
+	}
+	if tlsConnection, ok := connection.(*tls.Conn); ok {
+		state := tlsConnection.ConnectionState()
+		s.log = s.log.With("tls_version", tlsVersionName(state.Version), "cipher_suite", tls.CipherSuiteName(state.CipherSuite))
+	}
 
-	// Read Odette MessageenvelopeIndicator
-	buff, err := s.read()
+	// Read Odette MessageenvelopeIndicator. The server sends it on its own
+	// initiative, therefore the inactivity timer applies.
+	buff, err := s.readTimeout(ctx, c.inactivityTimeout())
 	if err != nil {
-		return err
+		_ = s.Close()
+		return nil, err
 	}
 
 	ssrm := session.SSRM{}
 	err = ssrm.Parse(buff)
 	if err != nil {
-		return err
+		_ = s.Close()
+		return nil, err
 	}
-	return nil
+
+	s.client.Observer = c.Observer
+	s.log.Info("connected")
+
+	return s, nil
+}
+
+// tlsVersions names the TLS versions for the log
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// tlsVersionName returns the name of the TLS version
+func tlsVersionName(version uint16) string {
+	if name, ok := tlsVersions[version]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
+
+// connect opens the connection to the endpoint, through the proxy if one is
+// configured, and performs the TLS handshake if TLS is enabled. Without a
+// ServerName in the TLS configuration, the certificate is verified against the
+// host of the endpoint, which is a fallback endpoint after a failover.
+func (c *OFTP2Client) connect(ctx context.Context, endpoint Endpoint) (net.Conn, error) {
+	addr := endpoint.String()
+
+	dial := c.Dialer
+	if dial == nil {
+		dialer := net.Dialer{}
+		dial = dialer.DialContext
+	}
+
+	var connection net.Conn
+	var err error
+
+	if c.Proxy != nil {
+		connection, err = c.Proxy.Dial(ctx, dial, "tcp", addr)
+	} else {
+		connection, err = dial(ctx, "tcp", addr)
+	}
+	if err != nil || c.TLSConfig == nil {
+		return connection, err
+	}
+
+	config := c.TLSConfig.Clone()
+	if config.ServerName == "" {
+		config.ServerName = endpoint.Host
+	}
+
+	tlsConnection := tls.Client(connection, config)
+	err = tlsConnection.HandshakeContext(ctx)
+	if err != nil {
+		connection.Close()
+		return nil, errors.New(fmt.Sprintf("TLS handshake with %s failed: %v", addr, err))
+	}
+
+	return tlsConnection, nil
+}
+
+// Open connects to the OFTP2 server and starts a session, see Dial and
+// Session.Start
+func (c *OFTP2Client) Open(ctx context.Context, password string, compression, restart, authentication bool) (*Session, error) {
+	s, err := c.Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Start(ctx, password, compression, restart, authentication)
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+
+	return s, nil
 }
 
 // Close closes the connection to the server
-func (s *OFTP2Client) Close() error {
-	err := (*s.con).Close()
+func (s *Session) Close() error {
+	if s.con == nil {
+		return nil
+	}
+
+	s.machine.Close()
+
+	err := s.con.Close()
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,303 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/logging"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+	"github.com/thomsmits/oftp2-client/oftp2/oftp2test"
+)
+
+// closedPort returns a port nobody listens on
+func closedPort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return port
+}
+
+// silentServer accepts connections but never sends the ready message
+func silentServer(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		connections := make([]net.Conn, 0)
+		defer func() {
+			for _, con := range connections {
+				con.Close()
+			}
+		}()
+
+		for {
+			con, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			connections = append(connections, con)
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// syncBuffer is a buffer for the log, which is written while the test reads it
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+func TestEndpointString(t *testing.T) {
+	tests := map[client.Endpoint]string{
+		{Host: "oftp.example.com", Port: 3305}: "oftp.example.com:3305",
+		{Host: "192.0.2.1", Port: 6619}:        "192.0.2.1:6619",
+		{Host: "2001:db8::1", Port: 3305}:      "[2001:db8::1]:3305",
+	}
+
+	for endpoint, expected := range tests {
+		if endpoint.String() != expected {
+			t.Errorf("expected %s, got %s", expected, endpoint.String())
+		}
+	}
+}
+
+func TestFailover(t *testing.T) {
+	host, port := readyServer(t)
+	var log syncBuffer
+	failures := 0
+
+	c := &client.OFTP2Client{
+		ServerHost: "127.0.0.1",
+		ServerPort: closedPort(t),
+		FallbackEndpoints: []client.Endpoint{
+			{Host: "127.0.0.1", Port: silentServer(t)},
+			{Host: host, Port: port},
+		},
+		OdetteId:          "O0013LOCAL",
+		InactivityTimeout: 100 * time.Millisecond,
+		Logger:            logging.NewTextLogger(&log, logging.LevelInfo),
+		Observer: func(event client.Event) {
+			if event.Kind == client.EventSessionFailed {
+				failures++
+			}
+		},
+	}
+
+	s, err := c.Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if s.Endpoint() != c.FallbackEndpoints[1] {
+		t.Errorf("connected to wrong endpoint %v", s.Endpoint())
+	}
+	if failures != 0 {
+		t.Errorf("failures of the other endpoints reported to the observer: %d", failures)
+	}
+
+	text := log.String()
+	if strings.Count(text, "endpoint not available") != 2 {
+		t.Errorf("failed endpoints not logged:\n%s", text)
+	}
+	if !strings.Contains(text, `msg=connected`) || !strings.Contains(text, "remote="+c.FallbackEndpoints[1].String()) {
+		t.Errorf("chosen endpoint not logged:\n%s", text)
+	}
+}
+
+func TestAllEndpointsFail(t *testing.T) {
+	failures := 0
+
+	c := &client.OFTP2Client{
+		ServerHost:        "127.0.0.1",
+		ServerPort:        closedPort(t),
+		FallbackEndpoints: []client.Endpoint{{Host: "127.0.0.1", Port: silentServer(t)}},
+		OdetteId:          "O0013LOCAL",
+		InactivityTimeout: 50 * time.Millisecond,
+		Observer: func(event client.Event) {
+			if event.Kind == client.EventSessionFailed {
+				failures++
+			}
+		},
+	}
+
+	_, err := c.Dial(context.Background())
+	if !errors.Is(err, client.ErrTimeout) {
+		t.Errorf("expected the timeout of the last endpoint, got %v", err)
+	}
+	if failures != 1 {
+		t.Errorf("expected one failure, got %d", failures)
+	}
+}
+
+func TestConnectTimeout(t *testing.T) {
+	host, port := readyServer(t)
+
+	c := &client.OFTP2Client{
+		ServerHost:        "192.0.2.1",
+		ServerPort:        3305,
+		FallbackEndpoints: []client.Endpoint{{Host: host, Port: port}},
+		OdetteId:          "O0013LOCAL",
+		ConnectTimeout:    50 * time.Millisecond,
+	}
+
+	// the first endpoint does not answer at all
+	var timedOut error
+	c.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address == "192.0.2.1:3305" {
+			<-ctx.Done()
+			timedOut = ctx.Err()
+			return nil, ctx.Err()
+		}
+		dialer := net.Dialer{}
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	start := time.Now()
+	s, err := c.Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if timedOut != context.DeadlineExceeded || time.Since(start) > 2*time.Second {
+		t.Errorf("connect timeout not applied: %v", timedOut)
+	}
+
+	c.FallbackEndpoints = nil
+	_, err = c.Dial(context.Background())
+	if !errors.Is(err, client.ErrTimeout) {
+		t.Errorf("expected timeout, got %v", err)
+	}
+}
+
+func TestIPv6(t *testing.T) {
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 not available:", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		con, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer con.Close()
+		ssrm := session.SSRM{}
+		_, _ = con.Write(wire.EncodeStreamBuffer(ssrm.Marshal()))
+		_, _ = wire.ReadStreamBuffer(con)
+	}()
+
+	c := &client.OFTP2Client{ServerHost: "::1", ServerPort: listener.Addr().(*net.TCPAddr).Port, OdetteId: "O0013LOCAL"}
+	s, err := c.Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+}
+
+// certificate creates a self-signed certificate for the host name and returns
+// the configurations for the server and the client
+func certificate(t *testing.T, host string) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{host},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	return server, &tls.Config{RootCAs: roots}
+}
+
+func TestFailoverTLS(t *testing.T) {
+	serverTLS, clientTLS := certificate(t, "backup.example")
+	partner := &oftp2test.Partner{}
+
+	c := &client.OFTP2Client{
+		ServerHost:        "primary.example",
+		ServerPort:        6619,
+		FallbackEndpoints: []client.Endpoint{{Host: "backup.example", Port: 6619}},
+		OdetteId:          clientId,
+		TLSConfig:         clientTLS,
+		Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if address != "backup.example:6619" {
+				return nil, errors.New("connection refused")
+			}
+
+			local, remote := net.Pipe()
+			go func() { _ = partner.Serve(tls.Server(remote, serverTLS)) }()
+			return local, nil
+		},
+	}
+
+	// the certificate of the backup is verified against its own name
+	s, err := c.Open(context.Background(), "", false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, ok := s.TLSConnectionState()
+	if !ok || state.ServerName != "backup.example" {
+		t.Errorf("wrong TLS connection state: %+v", state)
+	}
+
+	if err = s.End(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
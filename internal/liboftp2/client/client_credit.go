@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/logging"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/transfer"
+)
+
+// creditWindow tracks the DATA buffers received from the partner against the
+// credit granted to it (see RFC 5024, 5.3.7). The window is opened with the
+// negotiated credit when a file is started and reopened with each CDT.
+type creditWindow struct {
+	credit    uint32 // credit negotiated in the SSID exchange
+	remaining uint32 // buffers the partner may still send
+}
+
+// newCreditWindow creates an open window for the negotiated credit
+func newCreditWindow(credit uint32) creditWindow {
+	return creditWindow{credit: credit, remaining: credit}
+}
+
+// receive uses one credit for a received DATA buffer. An error is returned if
+// the partner sent more buffers than it was granted.
+func (w *creditWindow) receive() error {
+	if w.remaining == 0 {
+		return errors.New(fmt.Sprintf("credit of %d buffers exceeded", w.credit))
+	}
+	w.remaining--
+	return nil
+}
+
+// exhausted tells whether the partner has to wait for a CDT
+func (w *creditWindow) exhausted() bool {
+	return w.remaining == 0
+}
+
+// reopen grants the full credit again
+func (w *creditWindow) reopen() {
+	w.remaining = w.credit
+}
+
+// grantCredit sends a CDT once the partner used up its credit. The data
+// received so far is made durable before, so a slow storage holds back the
+// partner instead of letting unwritten data pile up. If the data cannot be
+// made durable, no credit is granted and the session is aborted with an ESID
+// "Resources not available"; the partner cannot be answered with an EFNA
+// before it got the credit to send the rest of the file.
+func (s *Session) grantCredit(ctx context.Context, r *fileReceiver) error {
+	if !r.window.exhausted() {
+		return nil
+	}
+
+	start := time.Now()
+	if err := r.sync(); err != nil {
+		s.logger().Error("cannot store file", logging.KeyDataset, r.virtualFile.DatasetName, "error", err)
+		s.abort(esidReasonResourcesNotAvailable, "Resources not available")
+		return fmt.Errorf("cannot store file %s: %w", r.virtualFile.DatasetName, err)
+	}
+	s.logger().Debug("granting credit", logging.KeyDataset, r.virtualFile.DatasetName,
+		"credit", r.window.credit, "storage_wait", time.Since(start))
+
+	cdt := transfer.CDT{}
+	err := s.write(ctx, cdt.Marshal())
+	if err != nil {
+		return err
+	}
+
+	r.window.reopen()
+	return nil
+}
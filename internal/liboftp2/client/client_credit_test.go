@@ -0,0 +1,183 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/trace"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/transfer"
+	"github.com/thomsmits/oftp2-client/oftp2"
+	"github.com/thomsmits/oftp2-client/oftp2/oftp2test"
+)
+
+// slowInbox stores the files in memory and delays each Sync like a slow
+// storage. It remembers how much data was durable when a CDT was sent. With
+// syncErr, Sync fails like a full disk.
+type slowInbox struct {
+	mutex   sync.Mutex
+	delay   time.Duration
+	syncErr error
+	written int
+	synced  int
+	syncs   int
+	data    bytes.Buffer
+}
+
+type slowFile struct {
+	inbox *slowInbox
+}
+
+func (i *slowInbox) Create(file client.VirtualFile) (client.InboxFile, error) {
+	return slowFile{i}, nil
+}
+
+func (f slowFile) Write(p []byte) (int, error) {
+	f.inbox.mutex.Lock()
+	defer f.inbox.mutex.Unlock()
+	f.inbox.written += len(p)
+	return f.inbox.data.Write(p)
+}
+
+func (f slowFile) Sync() error {
+	time.Sleep(f.inbox.delay)
+	f.inbox.mutex.Lock()
+	defer f.inbox.mutex.Unlock()
+	f.inbox.syncs++
+	if f.inbox.syncErr != nil {
+		return f.inbox.syncErr
+	}
+	f.inbox.synced = f.inbox.written
+	return nil
+}
+
+func (f slowFile) Commit() error { return nil }
+func (f slowFile) Abort() error  { return nil }
+
+func TestCreditWindow(t *testing.T) {
+	tests := []struct {
+		credit uint32
+		size   int
+	}{
+		// 63 bytes of user data fit into a buffer of 128 bytes
+		{credit: 1, size: 63 * 20},
+		{credit: 999, size: 63*999*2 + 100},
+	}
+
+	for _, test := range tests {
+		partner := &oftp2test.Partner{
+			BufferSize: 128,
+			Credit:     test.credit,
+			Files: []oftp2test.File{
+				{File: oftp2.VirtualFile{DatasetName: "LARGE"}, Data: bytes.Repeat([]byte("x"), test.size)},
+			},
+		}
+
+		inbox := &slowInbox{delay: time.Millisecond}
+		var traced bytes.Buffer
+		cdts := 0
+
+		c := partner.Client(clientId)
+		c.Trace = trace.NewRecorder(&traced)
+		c.Fuzzer = func(data []byte) []byte {
+			if len(data) > wire.StreamHeaderLength && string(data[wire.StreamHeaderLength:wire.StreamHeaderLength+1]) == transfer.CDTCMD {
+				cdts++
+				inbox.mutex.Lock()
+				if inbox.synced != inbox.written || inbox.written == 0 {
+					t.Errorf("credit %d: CDT sent with %d of %d bytes durable", test.credit, inbox.synced, inbox.written)
+				}
+				inbox.mutex.Unlock()
+			}
+			return data
+		}
+
+		s := open(t, c)
+		result, err := s.ReceiveFiles(context.Background(), inbox)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = s.End(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err = partner.Wait(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(result.Files) != 1 || inbox.data.Len() != test.size {
+			t.Errorf("credit %d: received %d of %d bytes", test.credit, inbox.data.Len(), test.size)
+		}
+
+		// the partner never sent more buffers than granted, and got a CDT
+		// after each used up window
+		records, err := trace.ReadTrace(&traced)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buffers, total := uint32(0), 0
+		for _, record := range records {
+			switch {
+			case record.Direction == trace.Inbound && record.Command == transfer.DATACMD:
+				buffers++
+				total++
+				if buffers > test.credit {
+					t.Fatalf("credit %d: partner sent %d buffers without CDT", test.credit, buffers)
+				}
+			case record.Direction == trace.Outbound && record.Command == transfer.CDTCMD:
+				if buffers != test.credit {
+					t.Errorf("credit %d: CDT sent after %d buffers", test.credit, buffers)
+				}
+				buffers = 0
+			}
+		}
+
+		if expected := total / int(test.credit); cdts != expected || inbox.syncs != expected {
+			t.Errorf("credit %d: %d buffers, expected %d CDTs and syncs, got %d and %d", test.credit, total, expected, cdts, inbox.syncs)
+		}
+	}
+}
+
+func TestNoCreditWhenStorageFails(t *testing.T) {
+	partner := &oftp2test.Partner{
+		BufferSize: 128,
+		Credit:     2,
+		Files: []oftp2test.File{
+			{File: oftp2.VirtualFile{DatasetName: "LARGE"}, Data: bytes.Repeat([]byte("x"), 63*10)},
+		},
+	}
+
+	inbox := &slowInbox{syncErr: errors.New("no space left on device")}
+	cdts := 0
+
+	c := partner.Client(clientId)
+	c.Fuzzer = func(data []byte) []byte {
+		if len(data) > wire.StreamHeaderLength && string(data[wire.StreamHeaderLength:wire.StreamHeaderLength+1]) == transfer.CDTCMD {
+			cdts++
+		}
+		return data
+	}
+
+	s := open(t, c)
+	result, err := s.ReceiveFiles(context.Background(), inbox)
+	if !errors.Is(err, inbox.syncErr) {
+		t.Fatalf("storage failure not reported: %v", err)
+	}
+	if len(result.Files) != 0 || cdts != 0 || inbox.syncs != 1 {
+		t.Errorf("credit granted after failed sync: %d files, %d CDTs, %d syncs", len(result.Files), cdts, inbox.syncs)
+	}
+	if s.Phase() != client.PhaseClosed {
+		t.Errorf("session not aborted")
+	}
+
+	// the partner was told why the session ended
+	_ = partner.Wait()
+	if err = partner.Err(); err == nil || !strings.Contains(err.Error(), "ESID reason 08") {
+		t.Errorf("partner got no ESID 08: %v", err)
+	}
+}
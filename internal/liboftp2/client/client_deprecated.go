@@ -0,0 +1,196 @@
+package client
+
+import (
+	"context"
+	"io"
+)
+
+// The methods in this file use the client itself as session. They keep
+// programs working that were written before Session was introduced. Each
+// Connect replaces the session of the client, so a client used with these
+// methods has a single session and is not safe for concurrent use.
+
+// session returns the session of the deprecated methods
+func (c *OFTP2Client) session() (*Session, error) {
+	if c.current == nil {
+		return nil, ErrNotConnected
+	}
+	return c.current, nil
+}
+
+// Connect connects to the OFTP2 server
+//
+// Deprecated: Use Dial, which returns the session.
+func (c *OFTP2Client) Connect() error {
+	return c.ConnectContext(context.Background())
+}
+
+// ConnectContext connects to the OFTP2 server. The context limits the time to
+// establish the connection and to wait for the ready message of the server.
+//
+// Deprecated: Use Dial, which returns the session.
+func (c *OFTP2Client) ConnectContext(ctx context.Context) error {
+	s, err := c.Dial(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.current = s
+	return nil
+}
+
+// Close closes the connection to the server
+//
+// Deprecated: Use Session.Close.
+func (c *OFTP2Client) Close() error {
+	if c.current == nil {
+		return nil
+	}
+	return c.current.Close()
+}
+
+// StartSession opens a session with the server
+//
+// Deprecated: Use Session.Start.
+func (c *OFTP2Client) StartSession(password string, compression, restart, authentication bool) error {
+	return c.StartSessionContext(context.Background(), password, compression, restart, authentication)
+}
+
+// StartSessionContext opens a session with the server. If the context is done
+// before the server answered, the session is aborted.
+//
+// Deprecated: Use Session.Start.
+func (c *OFTP2Client) StartSessionContext(ctx context.Context, password string, compression, restart, authentication bool) error {
+	s, err := c.session()
+	if err != nil {
+		return err
+	}
+	return s.Start(ctx, password, compression, restart, authentication)
+}
+
+// EndSession closes the session
+//
+// Deprecated: Use Session.End.
+func (c *OFTP2Client) EndSession() error {
+	return c.EndSessionContext(context.Background())
+}
+
+// EndSessionContext closes the session
+//
+// Deprecated: Use Session.End.
+func (c *OFTP2Client) EndSessionContext(ctx context.Context) error {
+	s, err := c.session()
+	if err != nil {
+		return err
+	}
+	return s.End(ctx)
+}
+
+// PartnerId returns the Odette ID of the partner of the current session. It is
+// empty if no session has been started.
+//
+// Deprecated: Use Session.PartnerId.
+func (c *OFTP2Client) PartnerId() string {
+	if c.current == nil {
+		return ""
+	}
+	return c.current.PartnerId()
+}
+
+// SendFile sends a file to the OFTP2 server
+//
+// Deprecated: Use Session.SendFile.
+func (c *OFTP2Client) SendFile(datasetName string, filePath string, format OFTP2FileFormat, destination string, securityLevel OFTP2SecurityLevel, cipher, compression, envelope, signed bool) error {
+	return c.SendFileContext(context.Background(), datasetName, filePath, format, destination, securityLevel, cipher, compression, envelope, signed)
+}
+
+// SendFileContext sends a file to the OFTP2 server. If the context is done
+// before the transfer is finished, the session is aborted.
+//
+// Deprecated: Use Session.SendFile.
+func (c *OFTP2Client) SendFileContext(ctx context.Context, datasetName string, filePath string, format OFTP2FileFormat, destination string, securityLevel OFTP2SecurityLevel, cipher, compression, envelope, signed bool) error {
+	s, err := c.session()
+	if err != nil {
+		return err
+	}
+	return s.SendFile(ctx, datasetName, filePath, format, destination, securityLevel, cipher, compression, envelope, signed)
+}
+
+// SendStream sends the data read from r as virtual file to the OFTP2 server.
+//
+// Deprecated: Use Session.SendStream.
+func (c *OFTP2Client) SendStream(virtualFile VirtualFile, r io.Reader, size int64) error {
+	return c.SendStreamContext(context.Background(), virtualFile, r, size)
+}
+
+// SendStreamContext sends the data read from r as virtual file to the OFTP2
+// server. If the context is done before the transfer is finished, the session
+// is aborted.
+//
+// Deprecated: Use Session.SendStream.
+func (c *OFTP2Client) SendStreamContext(ctx context.Context, virtualFile VirtualFile, r io.Reader, size int64) error {
+	s, err := c.session()
+	if err != nil {
+		return err
+	}
+	return s.SendStream(ctx, virtualFile, r, size)
+}
+
+// DrainQueue sends all files queued for the partner of the current session.
+//
+// Deprecated: Use Session.DrainQueue.
+func (c *OFTP2Client) DrainQueue(queue *SendQueue) ([]QueueResult, error) {
+	return c.DrainQueueContext(context.Background(), queue)
+}
+
+// DrainQueueContext sends all files queued for the partner of the current
+// session in the order of their priority.
+//
+// Deprecated: Use Session.DrainQueue.
+func (c *OFTP2Client) DrainQueueContext(ctx context.Context, queue *SendQueue) ([]QueueResult, error) {
+	s, err := c.session()
+	if err != nil {
+		return nil, err
+	}
+	return s.DrainQueue(ctx, queue)
+}
+
+// ReceiveFiles hands over the right to speak to the partner and receives the
+// files and end to end responses it has for us.
+//
+// Deprecated: Use Session.ReceiveFiles.
+func (c *OFTP2Client) ReceiveFiles(inbox Inbox) (ExchangeResult, error) {
+	return c.ReceiveFilesContext(context.Background(), inbox)
+}
+
+// ReceiveFilesContext hands over the right to speak to the partner and
+// receives the files and end to end responses it has for us.
+//
+// Deprecated: Use Session.ReceiveFiles.
+func (c *OFTP2Client) ReceiveFilesContext(ctx context.Context, inbox Inbox) (ExchangeResult, error) {
+	s, err := c.session()
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	return s.ReceiveFiles(ctx, inbox)
+}
+
+// AnswerChallenge answers a challenge received from the server and then sends a
+// new challenge to the server for mutual authentication
+//
+// Deprecated: Use Session.AnswerChallenge.
+func (c *OFTP2Client) AnswerChallenge(answer, ownChallenge, expectedResult []byte) error {
+	return c.AnswerChallengeContext(context.Background(), answer, ownChallenge, expectedResult)
+}
+
+// AnswerChallengeContext answers a challenge received from the server and then
+// sends a new challenge to the server for mutual authentication
+//
+// Deprecated: Use Session.AnswerChallenge.
+func (c *OFTP2Client) AnswerChallengeContext(ctx context.Context, answer, ownChallenge, expectedResult []byte) error {
+	s, err := c.session()
+	if err != nil {
+		return err
+	}
+	return s.AnswerChallenge(ctx, answer, ownChallenge, expectedResult)
+}
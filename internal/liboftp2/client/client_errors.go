@@ -0,0 +1,203 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/endfile"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/startfile"
+)
+
+// SessionEndedError is returned if the partner ended the session with an ESID
+// while the client expected another answer, e.g. when it rejects the SSID.
+type SessionEndedError struct {
+	// ReasonCode is the ESID reason code (ESIDREAS)
+	ReasonCode int
+
+	// Reason is the description of the reason code given in the RFC
+	Reason string
+
+	// Text is the reason text sent by the partner (ESIDREAST)
+	Text string
+}
+
+func (e *SessionEndedError) Error() string {
+	return fmt.Sprintf("partner ended session: ESID reason %02d (%s): %s", e.ReasonCode, e.Reason, e.Text)
+}
+
+// Retryable reports whether the session may succeed later on. This is the case
+// for emergency close down (05), resources not available (08) and time out
+// (09), as well as for a normal end of the session (00) while a file was
+// pending.
+func (e *SessionEndedError) Retryable() bool {
+	return retryableESIDReasons[e.ReasonCode]
+}
+
+// SessionAbortedError describes the ESID the client sent when it aborted the
+// session, e.g. after a protocol violation of the partner. It is reported to
+// the observer with EventSessionFailed.
+type SessionAbortedError struct {
+	// ReasonCode is the ESID reason code (ESIDREAS)
+	ReasonCode int
+
+	// Reason is the description of the reason code given in the RFC
+	Reason string
+
+	// Text is the reason text sent to the partner (ESIDREAST)
+	Text string
+}
+
+func (e *SessionAbortedError) Error() string {
+	return fmt.Sprintf("session aborted: ESID reason %02d (%s): %s", e.ReasonCode, e.Reason, e.Text)
+}
+
+// StartFileRejectedError is returned if the partner refused a file with an
+// SFNA. The session is still intact and further files can be sent.
+type StartFileRejectedError struct {
+	// DatasetName is the name of the refused file
+	DatasetName string
+
+	// ReasonCode is the SFNA reason code (SFNAREAS)
+	ReasonCode int
+
+	// Reason is the description of the reason code given in the RFC
+	Reason string
+
+	// Text is the reason text sent by the partner (SFNAREAST)
+	Text string
+
+	// RetryIndicator is set if the partner suggested to try again later
+	RetryIndicator bool
+}
+
+func (e *StartFileRejectedError) Error() string {
+	return fmt.Sprintf("partner does not accept file %s: SFNA reason %02d (%s), retry %v: %s",
+		e.DatasetName, e.ReasonCode, e.Reason, e.RetryIndicator, e.Text)
+}
+
+// Retryable returns the retry indicator sent by the partner
+func (e *StartFileRejectedError) Retryable() bool {
+	return e.RetryIndicator
+}
+
+// EndFileRejectedError is returned if the partner refused a file after it was
+// transmitted with an EFNA. The session is still intact and further files can
+// be sent.
+type EndFileRejectedError struct {
+	// DatasetName is the name of the refused file
+	DatasetName string
+
+	// ReasonCode is the EFNA reason code (EFNAREAS)
+	ReasonCode int
+
+	// Reason is the description of the reason code given in the RFC
+	Reason string
+
+	// Text is the reason text sent by the partner (EFNAREAST)
+	Text string
+}
+
+func (e *EndFileRejectedError) Error() string {
+	return fmt.Sprintf("partner does not accept file %s: EFNA reason %02d (%s): %s", e.DatasetName, e.ReasonCode, e.Reason, e.Text)
+}
+
+// Retryable reports whether the transmission may succeed if it is repeated.
+// This is the case for invalid record count (10), invalid byte count (11) and
+// access method failure (12).
+func (e *EndFileRejectedError) Retryable() bool {
+	return retryableEFNAReasons[e.ReasonCode]
+}
+
+// NegativeEndResponseError describes a negative end response (NERP): a file
+// was transmitted but could not be delivered to or processed by its final
+// recipient. It is returned by Receipt.Err for negative receipts.
+type NegativeEndResponseError struct {
+	// DatasetName and DateTime identify the file
+	DatasetName string
+	DateTime    time.Time
+
+	// Destination and Originator of the NERP, i.e. the originator and
+	// destination of the file
+	Destination string
+	Originator  string
+
+	// Creator is the Odette ID of the node that created the NERP
+	Creator string
+
+	// ReasonCode is the NERP reason code (NERPREAS)
+	ReasonCode int
+
+	// Reason is the description of the reason code given in the RFC
+	Reason string
+
+	// Text is the reason text of the NERP (NERPREAST)
+	Text string
+}
+
+func (e *NegativeEndResponseError) Error() string {
+	return fmt.Sprintf("file %s not delivered: NERP reason %02d (%s) from %s: %s", e.DatasetName, e.ReasonCode, e.Reason, e.Creator, e.Text)
+}
+
+// Retryable is always false, the file has already been delivered to the
+// partner, which gave up on it.
+func (e *NegativeEndResponseError) Retryable() bool {
+	return false
+}
+
+// newSessionEndedError creates the error for an ESID of the partner
+func newSessionEndedError(esid *session.ESID) *SessionEndedError {
+	return &SessionEndedError{
+		ReasonCode: esid.ReasonCode,
+		Reason:     session.ESIDReasonText(esid.ReasonCode),
+		Text:       esid.ReasonText,
+	}
+}
+
+// newRejectionError creates the error for an SFNA or EFNA of the partner
+func newRejectionError(datasetName string, answer wire.Protocol) error {
+	switch a := answer.(type) {
+	case *startfile.SFNA:
+		return &StartFileRejectedError{
+			DatasetName:    datasetName,
+			ReasonCode:     a.ReasonCode,
+			Reason:         startfile.SFNAReasonText(a.ReasonCode),
+			Text:           a.ReasonText,
+			RetryIndicator: a.RetryIndicator,
+		}
+	case *endfile.EFNA:
+		return &EndFileRejectedError{
+			DatasetName: datasetName,
+			ReasonCode:  a.ReasonCode,
+			Reason:      endfile.EFNAReasonText(a.ReasonCode),
+			Text:        a.AnswerText,
+		}
+	}
+
+	return errors.New(fmt.Sprintf("partner does not accept file %s: %v", datasetName, answer))
+}
+
+// isRejection checks if the error was caused by the partner refusing a file
+// as opposed to a failure of the session.
+func isRejection(err error) bool {
+	var sfna *StartFileRejectedError
+	var efna *EndFileRejectedError
+	return errors.As(err, &sfna) || errors.As(err, &efna)
+}
+
+// unexpectedAnswer creates the error for an answer t of the partner which is
+// not one of the expected commands. If the partner ended the session, the
+// connection is closed.
+func (s *Session) unexpectedAnswer(t string, answer wire.Protocol, expected string) error {
+	if esid, ok := answer.(*session.ESID); ok {
+		s.logger().Warn("session ended by partner", "reason", esid)
+		_ = s.Close()
+		err := newSessionEndedError(esid)
+		s.sessionFailed(err)
+		return err
+	}
+
+	return errors.New(fmt.Sprintf("unknown answer. Expected %s, got %s", expected, t))
+}
@@ -0,0 +1,90 @@
+package client_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err       error
+		retryable bool
+	}{
+		{&client.StartFileRejectedError{ReasonCode: 12, RetryIndicator: true}, true},
+		{&client.StartFileRejectedError{ReasonCode: 12, RetryIndicator: false}, false},
+		{&client.EndFileRejectedError{ReasonCode: 11}, true},
+		{&client.EndFileRejectedError{ReasonCode: 21}, false},
+		{&client.SessionEndedError{ReasonCode: 5}, true},
+		{&client.SessionEndedError{ReasonCode: 8}, true},
+		{&client.SessionEndedError{ReasonCode: 9}, true},
+		{&client.SessionEndedError{ReasonCode: 4}, false},
+		{&client.NegativeEndResponseError{ReasonCode: 35}, false},
+		{fmt.Errorf("sending: %w", &client.SessionEndedError{ReasonCode: 8}), true},
+		{fmt.Errorf("%w: no answer", client.ErrTimeout), true},
+		{io.EOF, true},
+		{errors.New("file not found"), false},
+		{nil, false},
+	}
+
+	for _, test := range tests {
+		if r := client.IsRetryable(test.err); r != test.retryable {
+			t.Errorf("IsRetryable(%v) = %v, expected %v", test.err, r, test.retryable)
+		}
+	}
+}
+
+func TestFailureReceipt(t *testing.T) {
+	file := client.VirtualFile{DatasetName: "DELFOR01", Destination: "O0013PARTNER", Originator: "O0013LOCAL"}
+
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{&client.StartFileRejectedError{ReasonCode: 3, Text: "unknown"}, 13},
+		{&client.StartFileRejectedError{ReasonCode: 13}, 23},
+		{&client.StartFileRejectedError{ReasonCode: 12, RetryIndicator: true}, 35},
+		{&client.EndFileRejectedError{ReasonCode: 21}, 31},
+		{&client.EndFileRejectedError{ReasonCode: 10}, 20},
+		{&client.EndFileRejectedError{ReasonCode: 11, Text: "byte count"}, 21},
+		{&client.EndFileRejectedError{ReasonCode: 12}, 22},
+		{&client.EndFileRejectedError{ReasonCode: 22}, 33},
+		{&client.EndFileRejectedError{ReasonCode: 23}, 32},
+		{&client.EndFileRejectedError{ReasonCode: 99}, 99},
+		{&client.SessionEndedError{ReasonCode: 4}, 4},
+		{&client.SessionEndedError{ReasonCode: 99}, 9},
+		{io.EOF, 35},
+		{errors.New("file not found"), 99},
+	}
+
+	for _, test := range tests {
+		receipt := client.FailureReceipt(file, "O0013LOCAL", test.err)
+		if !receipt.Negative || receipt.ReasonCode != test.code {
+			t.Errorf("receipt for %v: expected reason %d, got %+v", test.err, test.code, receipt)
+		}
+		if receipt.Destination != file.Originator || receipt.Originator != file.Destination {
+			t.Errorf("receipt not addressed to the originator: %+v", receipt)
+		}
+	}
+}
+
+func TestReceiptErr(t *testing.T) {
+	positive := client.Receipt{DatasetName: "DELFOR01"}
+	if positive.Err() != nil {
+		t.Errorf("error for positive receipt: %v", positive.Err())
+	}
+
+	negative := client.Receipt{DatasetName: "DELFOR01", Negative: true, ReasonCode: 35, ReasonText: "gone"}
+
+	var nerp *client.NegativeEndResponseError
+	if !errors.As(negative.Err(), &nerp) {
+		t.Fatalf("no NegativeEndResponseError: %v", negative.Err())
+	}
+
+	if nerp.ReasonCode != 35 || nerp.Reason != "Not delivered to recipient." || nerp.Text != "gone" || nerp.Retryable() {
+		t.Errorf("wrong error: %+v", nerp)
+	}
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/logging"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/transfer"
+)
+
+// sessionCounter is used to number the sessions of the process
+var sessionCounter uint64
+
+// nextSessionId returns an identifier for a new session, unique within the
+// process.
+func nextSessionId() string {
+	return strconv.FormatUint(atomic.AddUint64(&sessionCounter, 1), 10)
+}
+
+// baseLogger returns the logger configured for the client.
+func (c *OFTP2Client) baseLogger() logging.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+
+	if c.Verbose {
+		return logging.NewTextLogger(os.Stderr, logging.LevelDebug)
+	}
+
+	return logging.Discard
+}
+
+// logger returns the logger for the current session.
+func (s *Session) logger() logging.Logger {
+	if s.log == nil {
+		s.log = s.client.baseLogger()
+	}
+	return s.log
+}
+
+// logBuffer logs an exchange buffer at debug level. Passwords are redacted and
+// the content of DATA buffers is not logged.
+func (s *Session) logBuffer(msg string, buffer []byte) {
+	if len(buffer) == 0 {
+		return
+	}
+
+	name := commandNames[string(buffer[0:1])]
+	if name == "" {
+		name = string(buffer[0:1])
+	}
+
+	if string(buffer[0:1]) == transfer.DATACMD {
+		s.logger().Debug(msg, logging.KeyCommand, name, "bytes", len(buffer))
+	} else {
+		data := strings.Trim(string(session.RedactPassword(buffer)), "\n")
+		s.logger().Debug(msg, logging.KeyCommand, name, "data", data)
+	}
+}
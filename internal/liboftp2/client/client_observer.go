@@ -0,0 +1,199 @@
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventKind identifies the kind of an Event
+type EventKind int
+
+const (
+	// EventSessionStarted is reported when the partner accepted the session
+	EventSessionStarted EventKind = iota
+
+	// EventFileStarted is reported when the partner accepted a file (SFPA)
+	EventFileStarted
+
+	// EventProgress is reported after each data buffer that was sent
+	EventProgress
+
+	// EventCreditExhausted is reported when the credit window is used up and
+	// the client has to wait for the partner
+	EventCreditExhausted
+
+	// EventCreditReceived is reported when the partner granted new credit (CDT)
+	EventCreditReceived
+
+	// EventFileSent is reported when the partner confirmed a file (EFPA)
+	EventFileSent
+
+	// EventSessionEnded is reported when the session was ended
+	EventSessionEnded
+
+	// EventSessionFailed is reported when the connection could not be
+	// established or the session was ended with an error. Err holds the cause.
+	EventSessionFailed
+
+	// EventFileRejected is reported when the partner refused a file with an
+	// SFNA or EFNA. Err holds the rejection.
+	EventFileRejected
+
+	// EventFileRefused is reported when the client refused a file of the
+	// partner with an SFNA or EFNA. Err holds the rejection as it was sent.
+	EventFileRefused
+
+	// EventFileReceived is reported when a file of the partner was stored
+	EventFileReceived
+
+	// EventReceiptReceived is reported for each EERP or NERP of the partner
+	EventReceiptReceived
+)
+
+var eventKindNames = map[EventKind]string{
+	EventSessionStarted:  "session started",
+	EventFileStarted:     "file started",
+	EventProgress:        "progress",
+	EventCreditExhausted: "credit exhausted",
+	EventCreditReceived:  "credit received",
+	EventFileSent:        "file sent",
+	EventSessionEnded:    "session ended",
+	EventSessionFailed:   "session failed",
+	EventFileRejected:    "file rejected",
+	EventFileRefused:     "file refused",
+	EventFileReceived:    "file received",
+	EventReceiptReceived: "receipt received",
+}
+
+func (k EventKind) String() string {
+	if name, ok := eventKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("event %d", int(k))
+}
+
+// Event describes something that happened during the session
+type Event struct {
+	// Kind of the event
+	Kind EventKind
+
+	// Time the event happened
+	Time time.Time
+
+	// Partner is the Odette ID of the partner, empty before the session was
+	// started
+	Partner string
+
+	// DatasetName of the file the event belongs to, empty for session events
+	DatasetName string
+
+	// Size of the file in bytes as given by the application, negative if it
+	// is unknown
+	Size int64
+
+	// Stats of the file transfer so far. It is only set for file events.
+	Stats *TransferStats
+
+	// File is the virtual file as announced in the SFID. It is set for the
+	// events which end a file transfer.
+	File *VirtualFile
+
+	// Receipt is set for EventReceiptReceived
+	Receipt *Receipt
+
+	// Err is set for EventSessionFailed, EventFileRejected and
+	// EventFileRefused
+	Err error
+}
+
+// TransferStats are the statistics of a single file transfer. For received
+// files only Start, End and Bytes are set.
+type TransferStats struct {
+	// Start and End of the transfer. End is zero while the transfer is running.
+	Start time.Time
+	End   time.Time
+
+	// Bytes of user data transferred
+	Bytes uint64
+
+	// WireBytes is the size of all DATA exchange buffers including command
+	// and sub-record headers
+	WireBytes uint64
+
+	// Buffers is the number of DATA exchange buffers sent
+	Buffers int
+
+	// CreditStalls counts how often the client had to wait for new credit
+	CreditStalls int
+
+	// StallTime is the time spent waiting for new credit
+	StallTime time.Duration
+}
+
+// Duration of the transfer, up to now if it is still running
+func (t *TransferStats) Duration() time.Duration {
+	if t.End.IsZero() {
+		return time.Since(t.Start)
+	}
+	return t.End.Sub(t.Start)
+}
+
+// Throughput returns the user data bytes sent per second
+func (t *TransferStats) Throughput() float64 {
+	seconds := t.Duration().Seconds()
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(t.Bytes) / seconds
+}
+
+// CompressionRatio returns the ratio of user data to the data sent on the
+// wire. It is greater than 1 if compression saved more than the protocol
+// overhead.
+func (t *TransferStats) CompressionRatio() float64 {
+	if t.WireBytes == 0 {
+		return 0
+	}
+	return float64(t.Bytes) / float64(t.WireBytes)
+}
+
+// notify reports an event to the observer, if there is one
+func (s *Session) notify(kind EventKind, datasetName string, size int64, stats *TransferStats) {
+	s.notifyEvent(Event{
+		Kind:        kind,
+		DatasetName: datasetName,
+		Size:        size,
+		Stats:       stats,
+	})
+}
+
+// notifyEvent completes the event with the time and the partner and reports
+// it to the observer, if there is one
+func (s *Session) notifyEvent(event Event) {
+	if s.client.Observer == nil {
+		return
+	}
+
+	if event.Stats != nil {
+		// the observer gets a copy, so it can keep it
+		copied := *event.Stats
+		event.Stats = &copied
+	}
+
+	event.Time = time.Now()
+	event.Partner = s.partner.Id
+
+	s.client.Observer(event)
+}
+
+// sessionFailed reports the failure of the session to the observer. Only the
+// first failure of a connection is reported, because one failure usually
+// causes others, e.g. an aborted session a failing read.
+func (s *Session) sessionFailed(err error) {
+	if s.failureReported {
+		return
+	}
+
+	s.failureReported = true
+	s.notifyEvent(Event{Kind: EventSessionFailed, Err: err})
+}
@@ -0,0 +1,148 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/oftp2/oftp2test"
+)
+
+func TestTransferStats(t *testing.T) {
+	start := time.Date(2020, 12, 17, 10, 0, 0, 0, time.UTC)
+
+	stats := client.TransferStats{
+		Start:     start,
+		End:       start.Add(2 * time.Second),
+		Bytes:     4000,
+		WireBytes: 5000,
+	}
+
+	if stats.Duration() != 2*time.Second {
+		t.Errorf("wrong duration %v", stats.Duration())
+	}
+
+	if stats.Throughput() != 2000 {
+		t.Errorf("wrong throughput %v", stats.Throughput())
+	}
+
+	if stats.CompressionRatio() != 0.8 {
+		t.Errorf("wrong compression ratio %v", stats.CompressionRatio())
+	}
+
+	empty := client.TransferStats{Start: start, End: start}
+	if empty.Throughput() != 0 || empty.CompressionRatio() != 0 {
+		t.Errorf("division by zero not handled")
+	}
+}
+
+func TestEventKindString(t *testing.T) {
+	if s := client.EventCreditExhausted.String(); s != "credit exhausted" {
+		t.Errorf("wrong name %q", s)
+	}
+	if s := client.EventKind(42).String(); s != "event 42" {
+		t.Errorf("wrong name for unknown kind %q", s)
+	}
+}
+
+func TestObserverEvents(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+
+	tests := []struct {
+		name string
+		send func(t *testing.T, s *client.Session) error
+	}{
+		{"SendStream", func(t *testing.T, s *client.Session) error {
+			return sendData(s, "OBSERVED", data)
+		}},
+		{"SendFile", func(t *testing.T, s *client.Session) error {
+			path := filepath.Join(t.TempDir(), "observed")
+			if err := ioutil.WriteFile(path, data, 0600); err != nil {
+				return err
+			}
+			return s.SendFile(context.Background(), "OBSERVED", path, client.FileFormatUnstructured,
+				oftp2test.DefaultOdetteId, client.SecurityLevelNone, false, false, false, false)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a credit of 1 makes the client wait for a CDT after every buffer
+			partner := &oftp2test.Partner{BufferSize: 512, Credit: 1}
+			c := partner.Client(clientId)
+
+			events := make([]client.Event, 0)
+			c.Observer = func(event client.Event) {
+				events = append(events, event)
+			}
+
+			s := open(t, c)
+			if err := tt.send(t, s); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.End(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := partner.Wait(); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(events) < 2 {
+				t.Fatalf("expected events, got %d", len(events))
+			}
+			last := events[len(events)-2]
+			if last.Kind != client.EventFileSent || last.Stats == nil {
+				t.Fatalf("expected the file to be sent, got %v", last.Kind)
+			}
+			stats := last.Stats
+
+			// the file does not fit into a single buffer
+			if stats.Buffers < 2 {
+				t.Fatalf("expected several buffers, got %d", stats.Buffers)
+			}
+			if stats.CreditStalls != stats.Buffers {
+				t.Errorf("expected %d credit stalls, got %d", stats.Buffers, stats.CreditStalls)
+			}
+			if stats.Bytes != uint64(len(data)) {
+				t.Errorf("expected %d bytes, got %d", len(data), stats.Bytes)
+			}
+
+			expected := []client.EventKind{client.EventSessionStarted, client.EventFileStarted}
+			for i := 0; i < stats.Buffers; i++ {
+				expected = append(expected, client.EventProgress, client.EventCreditExhausted, client.EventCreditReceived)
+			}
+			expected = append(expected, client.EventFileSent, client.EventSessionEnded)
+
+			kinds := make([]client.EventKind, 0)
+			for _, event := range events {
+				kinds = append(kinds, event.Kind)
+			}
+			if len(kinds) != len(expected) {
+				t.Fatalf("expected events %v, got %v", expected, kinds)
+			}
+			for i := range kinds {
+				if kinds[i] != expected[i] {
+					t.Fatalf("expected events %v, got %v", expected, kinds)
+				}
+			}
+
+			// the statistics grow with every buffer sent
+			buffers := 0
+			for _, event := range events {
+				if event.Kind == client.EventProgress {
+					buffers++
+					if event.Stats.Buffers != buffers || event.Stats.CreditStalls != buffers-1 {
+						t.Errorf("wrong statistics after buffer %d: %+v", buffers, event.Stats)
+					}
+				}
+				if event.Partner != oftp2test.DefaultOdetteId {
+					t.Errorf("wrong partner %q for %v", event.Partner, event.Kind)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+)
+
+// Structure of an Odette ID: the letter O, the international code (ICD) of
+// the organisation code, the organisation code and the computer sub-address
+const (
+	odetteIdPrefix           = "O"
+	odetteIdCodeLength       = 4
+	odetteIdOrgLength        = 14
+	odetteIdSubAddressLength = 6
+	odetteIdMaxLength        = 1 + odetteIdCodeLength + odetteIdOrgLength + odetteIdSubAddressLength
+)
+
+// odetteIdSpecial are the characters allowed in an Odette ID besides the upper
+// case letters and digits
+const odetteIdSpecial = "/-.&() "
+
+// OdetteId is an Odette ID split into its components
+type OdetteId struct {
+	// InternationalCode is the ICD of the organisation code, e.g. 13 for DUNS
+	InternationalCode int
+
+	// Organisation is the organisation code, at most 14 characters
+	Organisation string
+
+	// SubAddress is the optional computer sub-address, at most 6 characters
+	SubAddress string
+}
+
+// OdetteIdError is returned if an Odette ID does not follow the structure
+// defined by Odette
+type OdetteIdError struct {
+	// Id is the invalid Odette ID
+	Id string
+
+	// Reason describes the violated rule
+	Reason string
+}
+
+func (e *OdetteIdError) Error() string {
+	return fmt.Sprintf("invalid Odette ID %q: %s", e.Id, e.Reason)
+}
+
+// GenerateOdetteId generates a RFC-compliant ODETTE id for the given
+// international code, organization code and computer address
+func GenerateOdetteId(intCode int, orgCode, subAddress string) string {
+	return strings.Trim(fmt.Sprintf("O%04d%s%s", intCode, wire.TruncateAndPadString(orgCode, odetteIdOrgLength),
+		wire.TruncateAndPadString(subAddress, odetteIdSubAddressLength)), " ")
+}
+
+// String returns the Odette ID. The organisation code is padded with spaces
+// if a sub-address follows.
+func (o OdetteId) String() string {
+	return GenerateOdetteId(o.InternationalCode, o.Organisation, o.SubAddress)
+}
+
+// ParseOdetteId splits the Odette ID into its components. The ID has to start
+// with the letter O followed by the 4 digits of the international code. The
+// organisation code of up to 14 characters and the sub-address of up to 6
+// characters consist of upper case letters, digits and the characters
+// / - . & ( ). Spaces are only allowed to pad the organisation code in front
+// of a sub-address.
+func ParseOdetteId(id string) (OdetteId, error) {
+	invalid := func(format string, args ...interface{}) (OdetteId, error) {
+		return OdetteId{}, &OdetteIdError{Id: id, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	switch {
+	case id == "":
+		return invalid("empty")
+	case len(id) > odetteIdMaxLength:
+		return invalid("longer than %d characters", odetteIdMaxLength)
+	case !strings.HasPrefix(id, odetteIdPrefix):
+		return invalid("does not start with %s", odetteIdPrefix)
+	case len(id) <= 1+odetteIdCodeLength:
+		return invalid("organisation code missing")
+	}
+
+	code := id[1 : 1+odetteIdCodeLength]
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return invalid("international code %q is not numeric", code)
+		}
+	}
+	intCode, _ := strconv.Atoi(code)
+
+	for _, c := range id {
+		if !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && !strings.ContainsRune(odetteIdSpecial, c) {
+			return invalid("character %q not allowed", c)
+		}
+	}
+
+	rest := id[1+odetteIdCodeLength:]
+	org := rest
+	subAddress := ""
+	if len(rest) > odetteIdOrgLength {
+		org = rest[:odetteIdOrgLength]
+		subAddress = rest[odetteIdOrgLength:]
+	}
+
+	trimmed := strings.TrimRight(org, " ")
+	switch {
+	case trimmed == "":
+		return invalid("organisation code missing")
+	case strings.Contains(trimmed, " "):
+		return invalid("space in organisation code")
+	case subAddress == "" && trimmed != org:
+		return invalid("trailing space")
+	case strings.Contains(subAddress, " "):
+		return invalid("space in sub-address")
+	}
+
+	return OdetteId{InternationalCode: intCode, Organisation: trimmed, SubAddress: subAddress}, nil
+}
+
+// ValidateOdetteId checks that the Odette ID follows the structure described
+// at ParseOdetteId
+func ValidateOdetteId(id string) error {
+	_, err := ParseOdetteId(id)
+	return err
+}
+
+// NormaliseOdetteId converts the Odette ID to upper case, removes surrounding
+// white space and checks it
+func NormaliseOdetteId(id string) (string, error) {
+	parsed, err := ParseOdetteId(strings.ToUpper(strings.TrimSpace(id)))
+	if err != nil {
+		return "", err
+	}
+	return parsed.String(), nil
+}
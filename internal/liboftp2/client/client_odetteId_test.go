@@ -0,0 +1,109 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/oftp2/oftp2test"
+)
+
+func TestParseOdetteId(t *testing.T) {
+	tests := []struct {
+		id       string
+		expected client.OdetteId
+	}{
+		{"O0013000000CLIENT", client.OdetteId{InternationalCode: 13, Organisation: "000000CLIENT"}},
+		{"O0013EXAMPLE       SUB", client.OdetteId{InternationalCode: 13, Organisation: "EXAMPLE", SubAddress: "SUB"}},
+		{"O0177ABCDEFGHIJKLMNOPQRST", client.OdetteId{InternationalCode: 177, Organisation: "ABCDEFGHIJKLMN", SubAddress: "OPQRST"}},
+		{"O0013A&B-C.D/(E)", client.OdetteId{InternationalCode: 13, Organisation: "A&B-C.D/(E)"}},
+	}
+
+	for _, test := range tests {
+		id, err := client.ParseOdetteId(test.id)
+		if err != nil {
+			t.Errorf("%s: %v", test.id, err)
+			continue
+		}
+		if id != test.expected {
+			t.Errorf("%s: got %+v", test.id, id)
+		}
+		if id.String() != test.id {
+			t.Errorf("%s: round trip gave %q", test.id, id.String())
+		}
+	}
+}
+
+func TestInvalidOdetteId(t *testing.T) {
+	tests := []string{
+		"",
+		"LOCAL",
+		"O0013",
+		"O13EXAMPLE",
+		"O0013example",
+		"O0013EXAMPLE_1",
+		"O0013EXA MPLE",
+		"O0013EXAMPLE ",
+		"O0013 EXAMPLE",
+		"O0013EXAMPLE       S B",
+		"O0013ABCDEFGHIJKLMNOPQRSTU",
+	}
+
+	for _, id := range tests {
+		err := client.ValidateOdetteId(id)
+		var idError *client.OdetteIdError
+		if !errors.As(err, &idError) || idError.Id != id {
+			t.Errorf("%q: expected OdetteIdError, got %v", id, err)
+		}
+	}
+}
+
+func TestNormaliseOdetteId(t *testing.T) {
+	id, err := client.NormaliseOdetteId("  o0013example       sub\n")
+	if err != nil || id != "O0013EXAMPLE       SUB" {
+		t.Errorf("wrong normalised id %q: %v", id, err)
+	}
+
+	if _, err = client.NormaliseOdetteId("x0013example"); err == nil {
+		t.Errorf("invalid id normalised")
+	}
+}
+
+func TestOdetteIdCheckedBeforeSending(t *testing.T) {
+	_, err := (&oftp2test.Partner{}).Client("LOCAL").Open(context.Background(), "", false, false, false)
+	var idError *client.OdetteIdError
+	if !errors.As(err, &idError) {
+		t.Errorf("expected OdetteIdError for the SSID, got %v", err)
+	}
+
+	partner := &oftp2test.Partner{}
+	s := open(t, partner.Client(clientId))
+	file := client.VirtualFile{DatasetName: "FILE", Destination: "PARTNER"}
+	err = s.SendStream(context.Background(), file, nil, 0)
+	if !errors.As(err, &idError) || idError.Id != "PARTNER" {
+		t.Errorf("expected OdetteIdError for the destination, got %v", err)
+	}
+
+	file = client.VirtualFile{DatasetName: "FILE", Originator: "O0013 LOCAL"}
+	err = s.SendStream(context.Background(), file, nil, 0)
+	if !errors.As(err, &idError) || idError.Id != "O0013 LOCAL" {
+		t.Errorf("expected OdetteIdError for the originator, got %v", err)
+	}
+
+	// the session is still usable, the destination defaults to the partner
+	file = client.VirtualFile{DatasetName: "FILE"}
+	if err = s.SendStream(context.Background(), file, bytes.NewReader([]byte("data")), 4); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.End(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err = partner.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if received := partner.Received(); len(received) != 1 || received[0].File.Destination != oftp2test.DefaultOdetteId {
+		t.Errorf("wrong files received: %+v", received)
+	}
+}
@@ -0,0 +1,461 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/oftp2"
+	"github.com/thomsmits/oftp2-client/oftp2/oftp2test"
+)
+
+const clientId = "O0013000000CLIENT"
+
+// memoryInbox keeps the received files in memory
+type memoryInbox struct {
+	files map[string]*bytes.Buffer
+}
+
+type memoryFile struct {
+	*bytes.Buffer
+}
+
+func (f memoryFile) Commit() error { return nil }
+func (f memoryFile) Abort() error  { return nil }
+
+func (i *memoryInbox) Create(file client.VirtualFile) (client.InboxFile, error) {
+	if i.files == nil {
+		i.files = make(map[string]*bytes.Buffer)
+	}
+	buffer := &bytes.Buffer{}
+	i.files[file.DatasetName] = buffer
+	return memoryFile{buffer}, nil
+}
+
+// open opens a session with the partner
+func open(t *testing.T, c *client.OFTP2Client) *client.Session {
+	t.Helper()
+
+	s, err := c.Open(context.Background(), "", false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// sendData sends a file with the given content
+func sendData(s *client.Session, name string, data []byte) error {
+	file := client.VirtualFile{DatasetName: name, Destination: oftp2test.DefaultOdetteId}
+	return s.SendStream(context.Background(), file, bytes.NewReader(data), int64(len(data)))
+}
+
+func TestSendFileToPartner(t *testing.T) {
+	partner := &oftp2test.Partner{BufferSize: 512, Credit: 2}
+	s := open(t, partner.Client(clientId))
+
+	// several credit windows are needed for the file
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	if err := sendData(s, "LARGE", data); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.End(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := partner.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	received := partner.Received()
+	if len(received) != 1 || received[0].File.DatasetName != "LARGE" || received[0].File.Originator != clientId {
+		t.Fatalf("wrong files received: %+v", received)
+	}
+	if !bytes.Equal(received[0].Data, data) {
+		t.Errorf("content differs, got %d bytes", len(received[0].Data))
+	}
+}
+
+func TestFileRejectedByPartner(t *testing.T) {
+	partner := &oftp2test.Partner{
+		StartFile: func(file oftp2.VirtualFile) oftp2test.Answer {
+			if file.DatasetName == "START" {
+				answer := oftp2test.Reject(3, "invalid origin")
+				answer.Retry = true
+				return answer
+			}
+			return oftp2test.Accept()
+		},
+		EndFile: func(file oftp2test.File) oftp2test.Answer {
+			if file.File.DatasetName == "END" {
+				return oftp2test.Reject(4, "invalid record count")
+			}
+			return oftp2test.Accept()
+		},
+	}
+	s := open(t, partner.Client(clientId))
+	defer s.Close()
+
+	err := sendData(s, "START", []byte("data"))
+	var sfna *client.StartFileRejectedError
+	if !errors.As(err, &sfna) || sfna.ReasonCode != 3 || !sfna.RetryIndicator || sfna.Text != "invalid origin" {
+		t.Errorf("expected SFNA 03, got %v", err)
+	}
+
+	err = sendData(s, "END", []byte("data"))
+	var efna *client.EndFileRejectedError
+	if !errors.As(err, &efna) || efna.ReasonCode != 4 {
+		t.Errorf("expected EFNA 04, got %v", err)
+	}
+
+	// the session is still usable
+	if err = sendData(s, "OK", []byte("data")); err != nil {
+		t.Errorf("file after rejection failed: %v", err)
+	}
+	if err = s.End(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err = partner.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(partner.Received()); n != 1 {
+		t.Errorf("expected one accepted file, got %d", n)
+	}
+}
+
+func TestSessionRejectedByPartner(t *testing.T) {
+	partner := &oftp2test.Partner{ExpectedPassword: "SECRET"}
+	c := partner.Client(clientId)
+
+	_, err := c.Open(context.Background(), "WRONG", false, false, false)
+	var esid *client.SessionEndedError
+	if !errors.As(err, &esid) || esid.ReasonCode != 4 {
+		t.Fatalf("expected ESID 04, got %v", err)
+	}
+
+	s, err := c.Open(context.Background(), "SECRET", false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.PartnerId() != oftp2test.DefaultOdetteId {
+		t.Errorf("wrong partner id %q", s.PartnerId())
+	}
+	_ = s.End(context.Background())
+}
+
+func TestSessionEndedByPartnerDuringTransfer(t *testing.T) {
+	partner := &oftp2test.Partner{
+		StartFile: func(file oftp2.VirtualFile) oftp2test.Answer {
+			return oftp2test.EndSession(8, "no space left")
+		},
+	}
+	s := open(t, partner.Client(clientId))
+
+	err := sendData(s, "FILE", []byte("data"))
+	var esid *client.SessionEndedError
+	if !errors.As(err, &esid) || esid.ReasonCode != 8 {
+		t.Fatalf("expected ESID 08, got %v", err)
+	}
+	if s.Phase() != client.PhaseClosed {
+		t.Errorf("session not closed: %v", s.Phase())
+	}
+}
+
+func TestPartnerTooSlow(t *testing.T) {
+	partner := &oftp2test.Partner{
+		StartFile: func(file oftp2.VirtualFile) oftp2test.Answer {
+			return oftp2test.Answer{Delay: 500 * time.Millisecond}
+		},
+	}
+	c := partner.Client(clientId)
+	c.ResponseTimeout = 50 * time.Millisecond
+	s := open(t, c)
+
+	err := sendData(s, "FILE", []byte("data"))
+	if !errors.Is(err, client.ErrTimeout) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+}
+
+func TestRestartRequestedByPartner(t *testing.T) {
+	partner := &oftp2test.Partner{
+		StartFile: func(file oftp2.VirtualFile) oftp2test.Answer {
+			return oftp2test.Answer{RestartPosition: 2}
+		},
+	}
+	s := open(t, partner.Client(clientId))
+	defer s.Close()
+
+	// the client does not support restart
+	if err := sendData(s, "FILE", []byte("data")); err == nil {
+		t.Fatal("restart position of the partner accepted")
+	}
+}
+
+func TestReceiveFilesFromPartner(t *testing.T) {
+	partner := &oftp2test.Partner{
+		BufferSize: 256,
+		Credit:     1,
+		Files: []oftp2test.File{
+			{File: oftp2.VirtualFile{DatasetName: "INVOICE"}, Data: bytes.Repeat([]byte("x"), 2000)},
+		},
+		Receipt: func(file oftp2test.File) oftp2test.Answer {
+			if file.File.DatasetName == "UNKNOWN" {
+				return oftp2test.Reject(3, "unknown destination")
+			}
+			return oftp2test.Accept()
+		},
+	}
+	s := open(t, partner.Client(clientId))
+
+	if err := sendData(s, "ORDERS", []byte("order")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sendData(s, "UNKNOWN", []byte("order")); err != nil {
+		t.Fatal(err)
+	}
+
+	inbox := &memoryInbox{}
+	result, err := s.ReceiveFiles(context.Background(), inbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Files) != 1 || result.Files[0].File.DatasetName != "INVOICE" || inbox.files["INVOICE"].Len() != 2000 {
+		t.Errorf("wrong files received: %+v", result.Files)
+	}
+	if len(result.Receipts) != 2 || result.Receipts[0].Negative || !result.Receipts[1].Negative || result.Receipts[1].ReasonCode != 3 {
+		t.Errorf("wrong receipts: %+v", result.Receipts)
+	}
+	if result.SessionEnded || !s.Speaker() {
+		t.Errorf("right to speak not handed back")
+	}
+
+	if err = s.End(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err = partner.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the client confirmed the file of the partner
+	receipts := partner.Receipts()
+	if len(receipts) != 1 || receipts[0].DatasetName != "INVOICE" || receipts[0].Destination != oftp2test.DefaultOdetteId {
+		t.Errorf("wrong receipts sent by the client: %+v", receipts)
+	}
+}
+
+func TestCapability(t *testing.T) {
+	tests := []struct {
+		capability string
+		announced  string
+		files      int
+	}{
+		{"", client.CapabilityBoth, 1},
+		{client.CapabilityReceive, client.CapabilityReceive, 1},
+		{client.CapabilitySend, client.CapabilitySend, 0},
+	}
+
+	for _, test := range tests {
+		var announced string
+		partner := &oftp2test.Partner{
+			Files: []oftp2test.File{{File: oftp2.VirtualFile{DatasetName: "INVOICE"}, Data: []byte("invoice")}},
+			Session: func(ssid *oftp2.SSID) oftp2test.Answer {
+				announced = ssid.Capability
+				return oftp2test.Accept()
+			},
+		}
+		c := partner.Client(clientId)
+		c.Capability = test.capability
+		s := open(t, c)
+
+		// a partner following the RFC sends no files to a send only client
+		result, err := s.ReceiveFiles(context.Background(), &memoryInbox{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = s.End(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err = partner.Wait(); err != nil {
+			t.Fatal(err)
+		}
+
+		if announced != test.announced {
+			t.Errorf("capability %q: announced %q, expected %q", test.capability, announced, test.announced)
+		}
+		if len(result.Files) != test.files {
+			t.Errorf("capability %q: received %d files, expected %d", test.capability, len(result.Files), test.files)
+		}
+	}
+}
+
+func TestPartnerEndsSessionAfterSpeaking(t *testing.T) {
+	partner := &oftp2test.Partner{EndAfterSpeaking: true}
+	s := open(t, partner.Client(clientId))
+
+	result, err := s.ReceiveFiles(context.Background(), &memoryInbox{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.SessionEnded || s.Phase() != client.PhaseClosed {
+		t.Errorf("session not ended by partner")
+	}
+	if err = partner.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// deferringInbox keeps the received files in memory and defers their end to
+// end responses
+type deferringInbox struct {
+	memoryInbox
+}
+
+type deferredFile struct {
+	memoryFile
+}
+
+func (f deferredFile) DeferReceipt() bool { return true }
+
+func (i *deferringInbox) Create(file client.VirtualFile) (client.InboxFile, error) {
+	f, err := i.memoryInbox.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	return deferredFile{f.(memoryFile)}, nil
+}
+
+func TestDeferredReceipt(t *testing.T) {
+	partner := &oftp2test.Partner{
+		Files: []oftp2test.File{
+			{File: oftp2.VirtualFile{DatasetName: "INVOICE", Destination: "O0013000000OTHER"}, Data: []byte("invoice")},
+		},
+	}
+	s := open(t, partner.Client(clientId))
+
+	result, err := s.ReceiveFiles(context.Background(), &deferringInbox{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 1 || !result.Files[0].Deferred {
+		t.Fatalf("file not deferred: %+v", result.Files)
+	}
+
+	file := result.Files[0].File
+	receipts := []client.Receipt{
+		{DatasetName: file.DatasetName, DateTime: file.DateTime, Destination: file.Originator, Originator: file.Destination,
+			FileHash: []byte{1, 2, 3}, Signature: []byte{4, 5, 6}},
+		{DatasetName: file.DatasetName, DateTime: file.DateTime, Destination: file.Originator, Originator: file.Destination,
+			CreatorOfNERP: "O0013000000OTHER", Negative: true, ReasonCode: 3, ReasonText: "unknown"},
+	}
+	for _, r := range receipts {
+		if err = s.SendReceipt(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = s.End(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err = partner.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the receipts sent explicitly reach the partner
+	got := partner.Receipts()
+	if len(got) != 2 || got[0].Negative || got[0].Originator != "O0013000000OTHER" ||
+		!got[1].Negative || got[1].ReasonCode != 3 || got[1].CreatorOfNERP != "O0013000000OTHER" {
+		t.Errorf("wrong receipts sent by the client: %+v", got)
+	}
+
+	// the signature of a relayed EERP is passed on
+	if !bytes.Equal(got[0].FileHash, []byte{1, 2, 3}) || !bytes.Equal(got[0].Signature, []byte{4, 5, 6}) {
+		t.Errorf("signature of the EERP lost: %+v", got[0])
+	}
+}
+
+func TestReceiptsKeptWhenSessionDrops(t *testing.T) {
+	partner := &oftp2test.Partner{
+		Receipt:           func(file oftp2test.File) oftp2test.Answer { return oftp2test.Accept() },
+		DropAfterSpeaking: true,
+	}
+	s := open(t, partner.Client(clientId))
+
+	if err := sendData(s, "ORDERS", []byte("order")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the receipt was confirmed with an RTR before the connection broke, it
+	// is not sent again and must not get lost
+	result, err := s.ReceiveFiles(context.Background(), &memoryInbox{})
+	if err == nil {
+		t.Fatal("broken connection not reported")
+	}
+	if len(result.Receipts) != 1 || result.Receipts[0].DatasetName != "ORDERS" || result.Receipts[0].Negative {
+		t.Errorf("receipt lost: %+v", result.Receipts)
+	}
+
+	if err = partner.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignedReceiptRequested(t *testing.T) {
+	files := []oftp2test.File{
+		{File: oftp2.VirtualFile{DatasetName: "SIGNED", SignedEERP: true}, Data: []byte("signed")},
+		{File: oftp2.VirtualFile{DatasetName: "PLAIN"}, Data: []byte("plain")},
+	}
+
+	// the client cannot sign the end to end response and refuses the file
+	partner := &oftp2test.Partner{Files: files}
+	c := partner.Client(clientId)
+	var refusal error
+	c.Observer = func(event client.Event) {
+		if event.Kind == client.EventFileRefused {
+			refusal = event.Err
+		}
+	}
+	s := open(t, c)
+
+	result, err := s.ReceiveFiles(context.Background(), &memoryInbox{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.End(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err = partner.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Files) != 1 || result.Files[0].File.DatasetName != "PLAIN" {
+		t.Errorf("wrong files received: %+v", result.Files)
+	}
+	var rejected *client.StartFileRejectedError
+	if !errors.As(refusal, &rejected) || rejected.DatasetName != "SIGNED" || rejected.ReasonCode != 99 {
+		t.Errorf("signed file not refused with an SFNA: %v", refusal)
+	}
+	if receipts := partner.Receipts(); len(receipts) != 1 || receipts[0].DatasetName != "PLAIN" {
+		t.Errorf("wrong receipts sent by the client: %+v", receipts)
+	}
+
+	// an inbox passing the end to end response on later accepts the file
+	partner = &oftp2test.Partner{Files: files}
+	s = open(t, partner.Client(clientId))
+
+	result, err = s.ReceiveFiles(context.Background(), &deferringInbox{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.End(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err = partner.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 2 {
+		t.Errorf("expected 2 files, got %+v", result.Files)
+	}
+}
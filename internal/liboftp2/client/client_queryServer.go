@@ -1,24 +1,26 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
 	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
 )
 
-func (s *OFTP2Client) internalQueryServerCapabilities(auth bool) (session.SSID, error) {
+func (c *OFTP2Client) internalQueryServerCapabilities(ctx context.Context, auth bool) (session.SSID, error) {
 
-	err := s.Connect()
+	s, err := c.Dial(ctx)
 	if err != nil {
 		return session.SSID{}, err
 	}
+	defer s.Close()
 
 	ssid := session.SSID{
-		Id:             s.OdetteId,
+		Id:             c.OdetteId,
 		Password:       "",
 		BufferSize:     102400,
-		Capability:     "S",
+		Capability:     c.capability(),
 		Compress:       true,
 		Restart:        true,
 		Special:        true,
@@ -28,13 +30,13 @@ func (s *OFTP2Client) internalQueryServerCapabilities(auth bool) (session.SSID,
 	}
 
 	// Send out SSID to the server
-	err = s.write(ssid.Marshal())
+	err = s.write(ctx, ssid.Marshal())
 	if err != nil {
 		return session.SSID{}, err
 	}
 
 	// Read the server's SSID
-	buffer, err := s.read()
+	buffer, err := s.read(ctx)
 	if err != nil {
 		return session.SSID{}, err
 	}
@@ -61,12 +63,7 @@ func (s *OFTP2Client) internalQueryServerCapabilities(auth bool) (session.SSID,
 	}
 
 	// close session
-	err = s.EndSession()
-	if err != nil {
-		// ignore error here
-	}
-
-	err = s.Close()
+	err = s.End(ctx)
 	if err != nil {
 		// ignore error here
 	}
@@ -80,20 +77,26 @@ func (s *OFTP2Client) internalQueryServerCapabilities(auth bool) (session.SSID,
 // server is presented with a client that seems to support all OFTP2 features.
 // The server then has to answer with its features. After that the session is closed.
 //
-// This methods opens and closes the connection to the server, therefore it is
-// not necessary to call Connect before using this method.
-func (s *OFTP2Client) QueryServerCapabilities() (session.SSID, error) {
+// This methods opens and closes its own connections to the server, a session
+// is not needed.
+func (c *OFTP2Client) QueryServerCapabilities() (session.SSID, error) {
+	return c.QueryServerCapabilitiesContext(context.Background())
+}
+
+// QueryServerCapabilitiesContext works like QueryServerCapabilities, the
+// context limits the time for both connections to the server.
+func (c *OFTP2Client) QueryServerCapabilitiesContext(ctx context.Context) (session.SSID, error) {
 
 	// we cannot test secure authentication in the first shot because the server will
 	// answer with an ESID(r=12) in case of an security mismatch. Therefore, we start
 	// with no authentication and try to check it in the next step
-	ssid, err := s.internalQueryServerCapabilities(false)
+	ssid, err := c.internalQueryServerCapabilities(ctx, false)
 	if err != nil {
 		return session.SSID{}, err
 	}
 
 	// next try with authentication set to true
-	secSsid, err := s.internalQueryServerCapabilities(true)
+	secSsid, err := c.internalQueryServerCapabilities(ctx, true)
 	if err != nil {
 		return session.SSID{}, err
 	}
@@ -0,0 +1,222 @@
+package client
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/logging"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/protocol"
+)
+
+// QueuedFile is a virtual file waiting in a SendQueue for transmission
+type QueuedFile struct {
+	// Id identifies the file for the application, it is not used by the queue
+	Id string
+
+	// File describes the virtual file
+	File VirtualFile
+
+	// Priority of the file. Files with a higher priority are sent first, files
+	// with the same priority in the order they were queued.
+	Priority int
+
+	// Open returns the content of the file and its size in bytes (negative if
+	// unknown). It is called right before the file is sent.
+	Open func() (io.ReadCloser, int64, error)
+
+	// sequence is the position of the file in the order of arrival, it is
+	// assigned when the file is queued for the first time
+	sequence uint64
+}
+
+// NewQueuedFile creates a queued file for the file at the given path. If the
+// date and time of the virtual file are not set, the modification time of the
+// file is used.
+func NewQueuedFile(path string, file VirtualFile, priority int) (QueuedFile, error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return QueuedFile{}, err
+	}
+
+	if file.DateTime.IsZero() {
+		file.DateTime = fileInfo.ModTime()
+	}
+
+	return QueuedFile{
+		Id:       path,
+		File:     file,
+		Priority: priority,
+		Open: func() (io.ReadCloser, int64, error) {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, 0, err
+			}
+			info, err := f.Stat()
+			if err != nil {
+				f.Close()
+				return nil, 0, err
+			}
+			return f, info.Size(), nil
+		},
+	}, nil
+}
+
+// QueueResult is the outcome of sending a single file of the queue
+type QueueResult struct {
+	// File that was sent
+	File QueuedFile
+
+	// Err is nil if the file was accepted by the partner
+	Err error
+
+	// Stats of the transfer, empty if the file could not be opened
+	Stats TransferStats
+}
+
+// SendQueue holds the files waiting for transmission, separately for each
+// partner. It can be used by several goroutines at the same time.
+type SendQueue struct {
+	mutex    sync.Mutex
+	partners map[string]*fileHeap
+	sequence uint64
+}
+
+// NewSendQueue creates an empty send queue
+func NewSendQueue() *SendQueue {
+	return &SendQueue{partners: make(map[string]*fileHeap)}
+}
+
+// Push adds a file for the partner with the given Odette ID to the queue. A
+// file taken from the queue and pushed again, e.g. after a failed session,
+// keeps its place among the files of the same priority.
+func (q *SendQueue) Push(partner string, file QueuedFile) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	h, ok := q.partners[partner]
+	if !ok {
+		h = &fileHeap{}
+		q.partners[partner] = h
+	}
+
+	if file.sequence == 0 {
+		q.sequence++
+		file.sequence = q.sequence
+	}
+	heap.Push(h, file)
+}
+
+// Pop removes the file with the highest priority for the given partner from
+// the queue. The second result is false if there is no file for the partner.
+func (q *SendQueue) Pop(partner string) (QueuedFile, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	h, ok := q.partners[partner]
+	if !ok || h.Len() == 0 {
+		return QueuedFile{}, false
+	}
+
+	file := heap.Pop(h).(QueuedFile)
+	if h.Len() == 0 {
+		delete(q.partners, partner)
+	}
+
+	return file, true
+}
+
+// Len returns the number of files queued for the given partner
+func (q *SendQueue) Len(partner string) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	h, ok := q.partners[partner]
+	if !ok {
+		return 0
+	}
+	return h.Len()
+}
+
+// Partners returns the Odette IDs of all partners with queued files
+func (q *SendQueue) Partners() []string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	result := make([]string, 0, len(q.partners))
+	for partner := range q.partners {
+		result = append(result, partner)
+	}
+	return result
+}
+
+// fileHeap implements heap.Interface ordered by priority and arrival
+type fileHeap []QueuedFile
+
+func (h fileHeap) Len() int { return len(h) }
+
+func (h fileHeap) Less(i, j int) bool {
+	if h[i].Priority != h[j].Priority {
+		return h[i].Priority > h[j].Priority
+	}
+	return h[i].sequence < h[j].sequence
+}
+
+func (h fileHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *fileHeap) Push(x interface{}) { *h = append(*h, x.(QueuedFile)) }
+
+func (h *fileHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	file := old[n-1]
+	*h = old[0 : n-1]
+	return file
+}
+
+// DrainQueue sends all files queued for the partner of the session in the
+// order of their priority. The session has to be started before. A file
+// refused by the partner, with an invalid Odette ID or that cannot be opened
+// is reported in the results and the next file is sent. If the session fails,
+// the file that was being sent is put back into the queue at its place and the
+// error is returned together with the results so far.
+// If the partner requests the right to speak in its answer to a file, the
+// function returns and the remaining files stay in the queue. They can be sent
+// after the partner handed back the right to speak in ReceiveFiles.
+func (s *Session) DrainQueue(ctx context.Context, queue *SendQueue) ([]QueueResult, error) {
+	results := make([]QueueResult, 0)
+
+	for {
+		if s.machine.State() == protocol.StateSendChangeDirection {
+			return results, nil
+		}
+
+		file, ok := queue.Pop(s.partner.Id)
+		if !ok {
+			return results, nil
+		}
+
+		r, size, err := file.Open()
+		if err != nil {
+			// the session is not affected, continue with the next file
+			s.logger().Error("cannot open queued file", logging.KeyDataset, file.File.DatasetName, "error", err)
+			results = append(results, QueueResult{File: file, Err: err})
+			continue
+		}
+
+		stats, err := s.sendStream(ctx, file.File, r, size)
+		r.Close()
+
+		// an invalid Odette ID of the file does not affect the session
+		var idError *OdetteIdError
+		if err != nil && !isRejection(err) && !errors.As(err, &idError) {
+			queue.Push(s.partner.Id, file)
+			return results, err
+		}
+
+		results = append(results, QueueResult{File: file, Err: err, Stats: stats})
+	}
+}
@@ -0,0 +1,236 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/oftp2"
+	"github.com/thomsmits/oftp2-client/oftp2/oftp2test"
+)
+
+// queued returns a queued file for the test partner with the given content
+func queued(name string, priority int, data string) client.QueuedFile {
+	return client.QueuedFile{
+		Id:       name,
+		File:     client.VirtualFile{DatasetName: name, Destination: oftp2test.DefaultOdetteId},
+		Priority: priority,
+		Open: func() (io.ReadCloser, int64, error) {
+			return ioutil.NopCloser(bytes.NewReader([]byte(data))), int64(len(data)), nil
+		},
+	}
+}
+
+// popAll takes all files of the partner from the queue and returns their ids
+func popAll(queue *client.SendQueue, partner string) []string {
+	ids := make([]string, 0)
+	for {
+		file, ok := queue.Pop(partner)
+		if !ok {
+			return ids
+		}
+		ids = append(ids, file.Id)
+	}
+}
+
+func equalIds(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestQueuePriorityOrder(t *testing.T) {
+	queue := client.NewSendQueue()
+	queue.Push("PARTNER", queued("LOW", 1, ""))
+	queue.Push("PARTNER", queued("FIRST", 5, ""))
+	queue.Push("OTHER", queued("OTHER", 9, ""))
+	queue.Push("PARTNER", queued("HIGH", 9, ""))
+	queue.Push("PARTNER", queued("SECOND", 5, ""))
+	queue.Push("PARTNER", queued("THIRD", 5, ""))
+
+	if n := queue.Len("PARTNER"); n != 5 {
+		t.Errorf("expected 5 files for the partner, got %d", n)
+	}
+
+	// files of the same priority in the order they were queued
+	expected := []string{"HIGH", "FIRST", "SECOND", "THIRD", "LOW"}
+	if ids := popAll(queue, "PARTNER"); !equalIds(ids, expected) {
+		t.Errorf("expected order %v, got %v", expected, ids)
+	}
+
+	if partners := queue.Partners(); len(partners) != 1 || partners[0] != "OTHER" {
+		t.Errorf("expected only the other partner left, got %v", partners)
+	}
+}
+
+func TestDrainQueue(t *testing.T) {
+	partner := &oftp2test.Partner{BufferSize: 512, Credit: 2}
+	partner.StartFile = func(file oftp2.VirtualFile) oftp2test.Answer {
+		if file.DatasetName == "REFUSED" {
+			return oftp2test.Reject(3, "Refused")
+		}
+		return oftp2test.Accept()
+	}
+	s := open(t, partner.Client(clientId))
+
+	queue := client.NewSendQueue()
+	queue.Push(oftp2test.DefaultOdetteId, queued("LOW", 1, "low"))
+	queue.Push(oftp2test.DefaultOdetteId, queued("HIGH", 9, "high"))
+	queue.Push(oftp2test.DefaultOdetteId, queued("LARGE", 5, string(bytes.Repeat([]byte("0123456789"), 500))))
+	queue.Push(oftp2test.DefaultOdetteId, queued("REFUSED", 5, "refused"))
+
+	results, err := s.DrainQueue(context.Background(), queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.End(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := partner.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]string, 0)
+	for _, r := range results {
+		ids = append(ids, r.File.Id)
+		if (r.Err != nil) != (r.File.Id == "REFUSED") {
+			t.Errorf("unexpected result for %s: %v", r.File.Id, r.Err)
+		}
+	}
+	expected := []string{"HIGH", "LARGE", "REFUSED", "LOW"}
+	if !equalIds(ids, expected) {
+		t.Errorf("expected results %v, got %v", expected, ids)
+	}
+
+	received := make([]string, 0)
+	for _, file := range partner.Received() {
+		received = append(received, file.File.DatasetName)
+	}
+	if expected := []string{"HIGH", "LARGE", "LOW"}; !equalIds(received, expected) {
+		t.Errorf("expected files %v at the partner, got %v", expected, received)
+	}
+	if queue.Len(oftp2test.DefaultOdetteId) != 0 {
+		t.Errorf("queue not drained")
+	}
+}
+
+func TestRequeuedFileKeepsPlace(t *testing.T) {
+	partner := &oftp2test.Partner{}
+
+	// the first session ends while the first file is sent
+	var mutex sync.Mutex
+	failed := false
+	partner.EndFile = func(file oftp2test.File) oftp2test.Answer {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !failed {
+			failed = true
+			return oftp2test.EndSession(8, "Resources not available")
+		}
+		return oftp2test.Accept()
+	}
+
+	queue := client.NewSendQueue()
+	queue.Push(oftp2test.DefaultOdetteId, queued("FIRST", 5, "first"))
+	queue.Push(oftp2test.DefaultOdetteId, queued("SECOND", 5, "second"))
+	queue.Push(oftp2test.DefaultOdetteId, queued("THIRD", 5, "third"))
+
+	s := open(t, partner.Client(clientId))
+	results, err := s.DrainQueue(context.Background(), queue)
+	if err == nil {
+		t.Fatal("expected the session to fail")
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no results, got %d", len(results))
+	}
+	if n := queue.Len(oftp2test.DefaultOdetteId); n != 3 {
+		t.Fatalf("expected 3 files left in the queue, got %d", n)
+	}
+
+	// the file is sent first in the next session
+	s = open(t, partner.Client(clientId))
+	results, err = s.DrainQueue(context.Background(), queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.End(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := partner.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]string, 0)
+	for _, r := range results {
+		ids = append(ids, r.File.Id)
+	}
+	if expected := []string{"FIRST", "SECOND", "THIRD"}; !equalIds(ids, expected) {
+		t.Errorf("expected order %v, got %v", expected, ids)
+	}
+}
+
+func TestDrainQueueChangeDirectionRequested(t *testing.T) {
+	partner := &oftp2test.Partner{
+		Files: []oftp2test.File{{File: oftp2.VirtualFile{DatasetName: "FROMPARTNER", Destination: clientId}, Data: []byte("data")}},
+	}
+
+	// the partner requests the right to speak after the first file
+	partner.EndFile = func(file oftp2test.File) oftp2test.Answer {
+		return oftp2test.Answer{ChangeDirection: file.File.DatasetName == "FIRST"}
+	}
+
+	queue := client.NewSendQueue()
+	queue.Push(oftp2test.DefaultOdetteId, queued("FIRST", 5, "first"))
+	queue.Push(oftp2test.DefaultOdetteId, queued("SECOND", 5, "second"))
+
+	s := open(t, partner.Client(clientId))
+	results, err := s.DrainQueue(context.Background(), queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].File.Id != "FIRST" || queue.Len(oftp2test.DefaultOdetteId) != 1 {
+		t.Fatalf("expected only the first file to be sent, got %+v", results)
+	}
+
+	// no further file before the right to speak was handed over
+	if err := sendData(s, "REFUSED", []byte("data")); err == nil {
+		t.Fatal("file sent after the partner requested the right to speak")
+	}
+
+	inbox := &memoryInbox{}
+	exchange, err := s.ReceiveFiles(context.Background(), inbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exchange.Files) != 1 || inbox.files["FROMPARTNER"] == nil {
+		t.Fatalf("expected the file of the partner, got %+v", exchange.Files)
+	}
+
+	results, err = s.DrainQueue(context.Background(), queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].File.Id != "SECOND" {
+		t.Fatalf("expected the second file to be sent, got %+v", results)
+	}
+	if err := s.End(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := partner.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if received := partner.Received(); len(received) != 2 {
+		t.Errorf("expected 2 files at the partner, got %d", len(received))
+	}
+}
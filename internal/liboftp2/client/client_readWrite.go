@@ -1,87 +1,111 @@
 package client
 
 import (
-	"encoding/binary"
-	"fmt"
-	"strings"
+	"context"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/trace"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
 )
 
 // TCP_HEADER_LENGTH defines the number of bytes, OFTP2 uses in the TCP data
 // stream to represent header information
-const TCP_HEADER_LENGTH = 4
+const TCP_HEADER_LENGTH = wire.StreamHeaderLength
 
-// oftp2TcpMagicWord is the magic word that marks the beginning of an OFTP2
-// message via TCP
-const oftp2TcpMagicWord = 0b0010000
+// Read the answer of the server to a command from the connection and return
+// the result as an byte array. The read is limited by the response timer.
+func (s *Session) read(ctx context.Context) ([]byte, error) {
+	return s.readTimeout(ctx, s.client.responseTimeout())
+}
 
-// Read bytes from the connection and return the result as an byte array
-func (s *OFTP2Client) read() ([]byte, error) {
+// Read bytes from the connection and return the result as an byte array. The
+// read is aborted if no data arrives within the given timeout or the context
+// is done.
+func (s *Session) readTimeout(ctx context.Context, timeout time.Duration) ([]byte, error) {
 
 	if s.con == nil {
-		panic("Open connection first")
-	}
-
-	// Read Header with length indicator
-	header := make([]byte, 4)
-	_, err := (*s.con).Read(header)
-
-	if err != nil {
-		return nil, err
+		return nil, ErrNotConnected
 	}
 
-	// Zero out fist byte, because it does not contribute to length information and
-	// is just an OFTP2 specific bit sequence
-	header[0] = 0
+	con := s.con
+	_ = con.SetReadDeadline(deadline(ctx, timeout))
 
-	// Get length of the rest of the message
-	var length uint32
-	length = binary.BigEndian.Uint32(header) - TCP_HEADER_LENGTH
+	stop := s.watch(ctx)
+	defer stop()
 
-	// Read remaining data from connection
-	buff := make([]byte, length)
-	_, err = (*s.con).Read(buff)
+	// Read the stream transmission header and the data it announces
+	buff, err := wire.ReadStreamBuffer(con)
 	if err != nil {
-		return nil, err
+		return nil, s.ioError(ctx, err, timeout)
 	}
 
-	if s.Verbose {
-		fmt.Printf("<-- %s\n", strings.Trim(string(buff), "\n"))
+	_ = s.client.Trace.Record(trace.Inbound, buff)
+	s.logBuffer("received", buff)
+
+	// unknown commands are left to the caller, which cannot parse them
+	if command, ok := commandName(buff); ok {
+		err = s.machine.Receive(command)
+		if err != nil {
+			s.abort(esidReasonProtocolViolation, "Protocol violation")
+			return nil, err
+		}
 	}
 
 	return buff, nil
 }
 
 // Sends the given data to the connection, adding OFTP2 specific header
-// information
-func (s *OFTP2Client) write(input []byte) error {
-
-	// The OFTP2 protocol requieres a 4 Byte header if with TCP as the transport protocol. The header consts of a
+// information. The write is limited by the response timer.
+func (s *Session) write(ctx context.Context, input []byte) error {
 
 	if s.con == nil {
-		panic("Open connection first")
+		return ErrNotConnected
 	}
 
-	// Make buffer from input plus 4 bytes for OFTP TCP header
-	buffer := make([]byte, TCP_HEADER_LENGTH+len(input))
-	length := uint32(len(input) + TCP_HEADER_LENGTH)
-	binary.BigEndian.PutUint32(buffer, length)
-	buffer[0] = byte(oftp2TcpMagicWord) // header as given by specification
+	// commands not allowed in the current state are never sent
+	if command, ok := commandName(input); ok {
+		err := s.machine.Send(command)
+		if err != nil {
+			return err
+		}
+	}
 
-	// Build final message
-	copy(buffer[TCP_HEADER_LENGTH:], input)
+	timeout := s.client.responseTimeout()
+	_ = s.con.SetWriteDeadline(deadline(ctx, timeout))
 
-	if s.Fuzzer != nil {
-		buffer = s.Fuzzer(buffer)
+	stop := s.watch(ctx)
+	defer stop()
+
+	err := s.send(input)
+	if err != nil {
+		return s.ioError(ctx, err, timeout)
+	}
+
+	return nil
+}
+
+// send writes the given data to the connection without any timer handling.
+func (s *Session) send(input []byte) error {
+
+	// The OFTP2 protocol requires a 4 byte stream transmission header if TCP is
+	// used as the transport protocol
+	buffer := wire.EncodeStreamBuffer(input)
+
+	if s.client.Fuzzer != nil {
+		buffer = s.client.Fuzzer(buffer)
 	}
 
-	if string(buffer[4]) == "D" && s.Verbose {
-		// Hack for data message logging
-		fmt.Printf("--> D...(%d bytes)\n", len(buffer[4:]))
-	} else if s.Verbose {
-		fmt.Printf("--> %s\n", strings.Trim(string(buffer[4:]), "\n"))
+	// a Fuzzer may return less than the stream transmission header, then the
+	// buffer is traced as it is
+	command := buffer
+	if len(buffer) >= TCP_HEADER_LENGTH {
+		command = buffer[TCP_HEADER_LENGTH:]
 	}
 
-	_, err := (*s.con).Write(buffer)
+	_ = s.client.Trace.Record(trace.Outbound, command)
+	s.logBuffer("sent", command)
+
+	_, err := s.con.Write(buffer)
 	if err != nil {
 		return err
 	}
@@ -94,15 +118,15 @@ const maxSubRecordLength = 63 // Byte, from specification 6 Bit available to ind
 
 // Determine the maximum size of a file read buffer which fits into one
 // data exchange buffer
-func (s *OFTP2Client) maxReadBufferSize() uint32 {
+func (s *Session) maxReadBufferSize() uint32 {
 
-	maxSubRecordCount := s.serverBufferSize / maxSubRecordLength
+	maxSubRecordCount := s.partner.BufferSize / maxSubRecordLength
 
 	// one byte per sub record, therefore we cannot fit maxSubRecordCount sub
 	// records into the buffer but less. Therefore we subtract the maximum overhead
 	// that may occur and calculate the record count again
 	overhead := maxSubRecordCount * 1 // overhead bytes
-	maxSubRecordCount = (s.serverBufferSize - overhead) / maxSubRecordLength
+	maxSubRecordCount = (s.partner.BufferSize - overhead) / maxSubRecordLength
 	resultBufferLength := maxSubRecordCount*maxSubRecordLength - 1 // minus one byte for "D" header
 
 	return resultBufferLength
@@ -110,13 +134,13 @@ func (s *OFTP2Client) maxReadBufferSize() uint32 {
 
 // Split a raw binary buffer into the sub record format needed by OFTP2. We
 // assume that the provided buffer sourceBuffer completely fits into the send
-// buffer of the OFTP2 protocol (see serverBufferSize in OFTP2Client struct). Due
+// buffer of the OFTP2 protocol (see Parameters.BufferSize). Due
 // to the protocol overhead of the subrecords handling, the sourceBuffer cannot
 // be serverBufferSize long but the overhead has to be subtracted first.
 // Therefore, the buffer size bust be smaller or equal to the value returned by
 // the maxReadBufferSize function.
-func (s *OFTP2Client) splitBufferIntoSubRecords(sourceBuffer []byte, lastBuffer bool) []byte {
-	targetBuffer := make([]byte, s.serverBufferSize)
+func (s *Session) splitBufferIntoSubRecords(sourceBuffer []byte, lastBuffer bool) []byte {
+	targetBuffer := make([]byte, s.partner.BufferSize)
 
 	// now loop over the provided buffer in chunks and add the OFTP magic sub record
 	// headers to the result
@@ -188,5 +212,6 @@ func (s *OFTP2Client) splitBufferIntoSubRecords(sourceBuffer []byte, lastBuffer
 		}
 	}
 
-	return targetBuffer
+	// only send the bytes used, not the complete buffer
+	return targetBuffer[0:targetBufferPos]
 }
@@ -0,0 +1,599 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/logging"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/protocol"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/endfile"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/startfile"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/transfer"
+)
+
+// Inbox stores the files received from the partner
+type Inbox interface {
+	// Create is called when the partner starts a file. If an error is
+	// returned, the file is refused with an SFNA. Return a *FileRefusal to
+	// give the partner a specific reason.
+	Create(file VirtualFile) (InboxFile, error)
+}
+
+// InboxFile receives the content of a single file. If it also implements
+// Sync() error, like *os.File, Sync is called before the partner is granted
+// new credit, so the data written so far must be durable when it returns.
+//
+// If it implements DeferReceipt() bool and that returns true after Commit, no
+// end to end response is sent for the file. The application sends it later
+// with Session.SendReceipt, e.g. an intermediate location forwarding the file
+// once the destination has confirmed it (see RFC 5024, 4.3.6).
+//
+// The client cannot sign end to end responses. A file requesting a signed EERP
+// is refused with an SFNA, unless DeferReceipt returns true already when the
+// file is created.
+type InboxFile interface {
+	io.Writer
+
+	// Commit is called after the file was received completely. The file must
+	// be stored durably when Commit returns without error, the partner is
+	// informed about the successful transmission afterwards.
+	Commit() error
+
+	// Abort is called if the transmission failed, the data received so far
+	// should be discarded.
+	Abort() error
+}
+
+// FileRefusal is returned by an Inbox to refuse a file with the given SFNA
+// reason code (see RFC 5024, 5.3.4)
+type FileRefusal struct {
+	ReasonCode int
+	ReasonText string
+}
+
+func (e *FileRefusal) Error() string {
+	return fmt.Sprintf("file refused (%d): %s", e.ReasonCode, e.ReasonText)
+}
+
+// ReceivedFile is a file received from the partner
+type ReceivedFile struct {
+	// File as described by the partner
+	File VirtualFile
+
+	// Bytes is the number of bytes received
+	Bytes uint64
+
+	// Deferred is true if the inbox defers the end to end response, it is
+	// not sent by ReceiveFiles then
+	Deferred bool
+}
+
+// Receipt is an end to end response (EERP) or negative end response (NERP)
+// received from the partner for a file sent before.
+type Receipt struct {
+	DatasetName string
+	DateTime    time.Time
+	UserData    string
+
+	// Destination is the originator of the file the receipt is for
+	Destination string
+
+	// Originator is the destination of the file the receipt is for
+	Originator string
+
+	// CreatorOfNERP is the Odette ID of the node that created a NERP
+	CreatorOfNERP string
+
+	// Negative is true for an NERP
+	Negative bool
+
+	// ReasonCode and ReasonText give the reason of a NERP
+	ReasonCode int
+	ReasonText string
+
+	// FileHash and Signature are set if the destination signed the receipt.
+	// They are sent unchanged when the receipt is passed on, so the
+	// signature stays valid.
+	FileHash  []byte
+	Signature []byte
+}
+
+// Err returns a *NegativeEndResponseError for a negative receipt and nil for
+// a positive one
+func (r *Receipt) Err() error {
+	if !r.Negative {
+		return nil
+	}
+
+	return &NegativeEndResponseError{
+		DatasetName: r.DatasetName,
+		DateTime:    r.DateTime,
+		Destination: r.Destination,
+		Originator:  r.Originator,
+		Creator:     r.CreatorOfNERP,
+		ReasonCode:  r.ReasonCode,
+		Reason:      startfile.NERPReasonText(r.ReasonCode),
+		Text:        r.ReasonText,
+	}
+}
+
+// ExchangeResult summarizes what the partner sent while it was the speaker
+type ExchangeResult struct {
+	// Files received and committed to the inbox
+	Files []ReceivedFile
+
+	// Receipts received for files sent earlier
+	Receipts []Receipt
+
+	// SessionEnded is true if the partner ended the session, it must not be
+	// ended by the client then.
+	SessionEnded bool
+}
+
+// ESID reason codes used on errors of the partner and the storage
+const (
+	esidReasonProtocolViolation     = 2
+	esidReasonResourcesNotAvailable = 8
+)
+
+// SFNA and EFNA reason codes used by the client
+const (
+	answerReasonInvalidByteCount    = 11
+	answerReasonAccessMethodFailure = 12
+	answerReasonUnspecified         = 99
+)
+
+// signedEERPText is the reason given for refusing a file that requests a
+// signed end to end response
+const signedEERPText = "Signed EERP not supported."
+
+// ReceiveFiles sends a change direction (CD) to the partner, so it becomes the
+// speaker, and receives files and end to end responses into the inbox until
+// the partner hands back the right to speak or ends the session.
+// For each received file an end to end response (EERP) is sent to the partner
+// before the function returns, unless the inbox defers it. If the session was not ended by the partner,
+// the client is the speaker again and can send further files or end the
+// session.
+// If an error is returned, the result still holds the files and end to end
+// responses received before. The partner was informed about them already and
+// does not send them again, so they have to be processed nevertheless.
+func (s *Session) ReceiveFiles(ctx context.Context, inbox Inbox) (ExchangeResult, error) {
+	result := ExchangeResult{
+		Files:    make([]ReceivedFile, 0),
+		Receipts: make([]Receipt, 0),
+	}
+
+	if s.con == nil {
+		return result, ErrNotConnected
+	}
+	// the CD is also the answer to a request of the partner to speak
+	if state := s.machine.State(); state != protocol.StateSpeaker && state != protocol.StateSendChangeDirection {
+		return result, errors.New(fmt.Sprintf("cannot hand over the right to speak in state %v", state))
+	}
+
+	err := s.changeDirection(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	pendingEERPs := make([]startfile.EERP, 0)
+	var receiver *fileReceiver
+
+	defer func() {
+		if receiver != nil {
+			_ = receiver.file.Abort()
+		}
+	}()
+
+	for {
+		// the partner is the speaker and sends commands on its own initiative
+		buffer, err := s.readTimeout(ctx, s.client.inactivityTimeout())
+		if err != nil {
+			return result, err
+		}
+
+		answer, t, err := DetermineMessageType(buffer)
+		if err != nil {
+			s.abort(esidReasonProtocolViolation, "Protocol violation")
+			return result, err
+		}
+
+		switch t {
+		case "SFID":
+			receiver, err = s.startReceive(ctx, inbox, answer.(*startfile.SFID))
+			if err != nil {
+				return result, err
+			}
+
+		case "DATA":
+			if err = receiver.window.receive(); err != nil {
+				s.logger().Warn("partner exceeded credit", logging.KeyDataset, receiver.virtualFile.DatasetName, "error", err)
+				return result, s.protocolViolation(t)
+			}
+
+			err = receiver.data(buffer)
+			if err != nil {
+				return result, s.protocolViolation(t)
+			}
+
+			err = s.grantCredit(ctx, receiver)
+			if err != nil {
+				return result, err
+			}
+
+		case "EFID":
+			received, err := s.endReceive(ctx, receiver, answer.(*endfile.EFID))
+			receiver = nil
+			if err != nil {
+				return result, err
+			}
+			if received != nil {
+				result.Files = append(result.Files, *received)
+				if !received.Deferred {
+					pendingEERPs = append(pendingEERPs, eerpFor(received.File))
+				}
+			}
+
+		case "EERP", "NERP":
+			receipt := ReceiptFrom(answer)
+			result.Receipts = append(result.Receipts, receipt)
+			s.notifyEvent(Event{Kind: EventReceiptReceived, DatasetName: receipt.DatasetName, Receipt: &receipt})
+			rtr := startfile.RTR{}
+			err = s.write(ctx, rtr.Marshal())
+			if err != nil {
+				return result, err
+			}
+
+		case "CD":
+			// we are the speaker again
+			if len(pendingEERPs) == 0 {
+				return result, nil
+			}
+
+			err = s.sendEERPs(ctx, pendingEERPs)
+			if err != nil {
+				return result, err
+			}
+			pendingEERPs = pendingEERPs[:0]
+
+			// give the partner the chance to answer the end to end responses
+			err = s.changeDirection(ctx)
+			if err != nil {
+				return result, err
+			}
+
+		case "ESID":
+			result.SessionEnded = true
+			_ = s.Close()
+
+			esid := answer.(*session.ESID)
+			s.logger().Info("session ended by partner", "reason", esid.ReasonCode)
+			s.notify(EventSessionEnded, "", 0, nil)
+			if esid.ReasonCode != 0 {
+				err = newSessionEndedError(esid)
+				s.sessionFailed(err)
+				return result, err
+			}
+			return result, nil
+
+		default:
+			return result, s.protocolViolation(t)
+		}
+	}
+}
+
+// changeDirection sends a CD command, afterwards the partner is the speaker
+func (s *Session) changeDirection(ctx context.Context) error {
+	cd := wire.CD{}
+	err := s.write(ctx, cd.Marshal())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// protocolViolation aborts the session because the partner sent a command
+// which is not allowed at this point.
+func (s *Session) protocolViolation(command string) error {
+	s.abort(esidReasonProtocolViolation, "Protocol violation")
+	return errors.New(fmt.Sprintf("protocol violation: unexpected %s", command))
+}
+
+// fileReceiver holds the state of the file currently received
+type fileReceiver struct {
+	virtualFile VirtualFile
+	file        InboxFile
+	start       time.Time
+	bytes       uint64
+	window      creditWindow
+	err         error
+}
+
+// data writes the user data of a DATA exchange buffer to the inbox file. A
+// malformed buffer is reported as error, a failure to write is remembered and
+// reported to the partner at the end of the file.
+func (r *fileReceiver) data(buffer []byte) error {
+	subRecords, err := transfer.ParseSubRecords(buffer[1:])
+	if err != nil {
+		return err
+	}
+
+	for _, subRecord := range subRecords {
+		data := subRecord.Expand()
+		r.bytes += uint64(len(data))
+
+		if r.err == nil {
+			_, r.err = r.file.Write(data)
+		}
+	}
+
+	return nil
+}
+
+// sync makes the data written so far durable, if the inbox file supports it.
+// The error of Sync is returned; a failure to write, which is reported to the
+// partner at the end of the file, skips it.
+func (r *fileReceiver) sync() error {
+	syncer, ok := r.file.(interface{ Sync() error })
+	if !ok || r.err != nil {
+		return nil
+	}
+
+	r.err = syncer.Sync()
+	return r.err
+}
+
+// deferred checks if the inbox file defers the end to end response
+func (r *fileReceiver) deferred() bool {
+	return defersReceipt(r.file)
+}
+
+// defersReceipt checks if the inbox file defers the end to end response
+func defersReceipt(file InboxFile) bool {
+	deferrer, ok := file.(interface{ DeferReceipt() bool })
+	return ok && deferrer.DeferReceipt()
+}
+
+// startReceive asks the inbox for a file and answers the SFID accordingly. If
+// the file is refused, nil is returned.
+func (s *Session) startReceive(ctx context.Context, inbox Inbox, sfid *startfile.SFID) (*fileReceiver, error) {
+	virtualFile := VirtualFileFromSFID(sfid)
+
+	file, err := inbox.Create(virtualFile)
+	if err == nil && virtualFile.SignedEERP && !defersReceipt(file) {
+		// the client has no key to sign the end to end response
+		_ = file.Abort()
+		err = &FileRefusal{ReasonCode: answerReasonUnspecified, ReasonText: signedEERPText}
+	}
+	if err != nil {
+		s.logger().Warn("refusing file", logging.KeyDataset, sfid.DatasetName, "error", err)
+
+		sfna := startfile.SFNA{
+			ReasonCode: answerReasonUnspecified,
+			ReasonText: err.Error(),
+		}
+
+		var refusal *FileRefusal
+		if errors.As(err, &refusal) {
+			sfna.ReasonCode = refusal.ReasonCode
+			sfna.ReasonText = refusal.ReasonText
+		}
+
+		s.notifyEvent(Event{
+			Kind:        EventFileRefused,
+			DatasetName: sfid.DatasetName,
+			File:        &virtualFile,
+			Err:         newRejectionError(sfid.DatasetName, &sfna),
+		})
+
+		return nil, s.write(ctx, sfna.Marshal())
+	}
+
+	// restart is not supported, the file is always received from the start
+	sfpa := startfile.SFPA{AnswerCount: 0}
+	err = s.write(ctx, sfpa.Marshal())
+	if err != nil {
+		_ = file.Abort()
+		return nil, err
+	}
+
+	return &fileReceiver{
+		virtualFile: virtualFile,
+		file:        file,
+		start:       time.Now(),
+		window:      newCreditWindow(s.partner.Credit),
+	}, nil
+}
+
+// endReceive checks the received file against the EFID, commits it to the
+// inbox and answers the partner. If the file is refused, nil is returned.
+func (s *Session) endReceive(ctx context.Context, r *fileReceiver, efid *endfile.EFID) (*ReceivedFile, error) {
+
+	efna := endfile.EFNA{}
+
+	if r.err != nil {
+		efna.ReasonCode = answerReasonAccessMethodFailure
+		efna.AnswerText = r.err.Error()
+	} else if efid.UnitCount != r.bytes {
+		efna.ReasonCode = answerReasonInvalidByteCount
+		efna.AnswerText = fmt.Sprintf("received %d bytes", r.bytes)
+	} else if err := r.file.Commit(); err != nil {
+		efna.ReasonCode = answerReasonAccessMethodFailure
+		efna.AnswerText = err.Error()
+	}
+
+	if efna.ReasonCode != 0 {
+		s.logger().Warn("file not received", logging.KeyDataset, r.virtualFile.DatasetName, "reason", efna.AnswerText)
+		_ = r.file.Abort()
+		s.notifyEvent(Event{
+			Kind:        EventFileRefused,
+			DatasetName: r.virtualFile.DatasetName,
+			File:        &r.virtualFile,
+			Err:         newRejectionError(r.virtualFile.DatasetName, &efna),
+		})
+		return nil, s.write(ctx, efna.Marshal())
+	}
+
+	efpa := endfile.EFPA{ChangeDirection: false}
+	err := s.write(ctx, efpa.Marshal())
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger().Info("file received", logging.KeyDataset, r.virtualFile.DatasetName, "originator", r.virtualFile.Originator, "bytes", r.bytes)
+	s.notifyEvent(Event{
+		Kind:        EventFileReceived,
+		DatasetName: r.virtualFile.DatasetName,
+		Size:        int64(r.bytes),
+		Stats:       &TransferStats{Start: r.start, End: time.Now(), Bytes: r.bytes},
+		File:        &r.virtualFile,
+	})
+
+	return &ReceivedFile{File: r.virtualFile, Bytes: r.bytes, Deferred: r.deferred()}, nil
+}
+
+// sendEERPs sends the end to end responses for the received files and waits
+// for the partner to confirm each of them.
+func (s *Session) sendEERPs(ctx context.Context, eerps []startfile.EERP) error {
+	for _, eerp := range eerps {
+		err := s.sendEndResponse(ctx, eerp.Marshal())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SendReceipt sends an end to end response (EERP) or, for a negative receipt,
+// a negative end response (NERP) to the partner and waits for its ready to
+// receive command (RTR). The client has to be the speaker. It is used for the
+// end to end responses deferred by the inbox, e.g. to pass on the response of
+// the destination of a forwarded file to its originator.
+func (s *Session) SendReceipt(ctx context.Context, receipt Receipt) error {
+	if s.con == nil {
+		return ErrNotConnected
+	}
+	if state := s.machine.State(); state != protocol.StateSpeaker {
+		return errors.New(fmt.Sprintf("cannot send end to end response in state %v", state))
+	}
+
+	if !receipt.Negative {
+		eerp := startfile.EERP{
+			VirtualDataSetName: receipt.DatasetName,
+			VirtualFileDate:    receipt.DateTime,
+			UserData:           receipt.UserData,
+			Destination:        receipt.Destination,
+			Originator:         receipt.Originator,
+			FileHash:           receipt.FileHash,
+			Signature:          receipt.Signature,
+		}
+		return s.sendEndResponse(ctx, eerp.Marshal())
+	}
+
+	nerp := startfile.NERP{
+		VirtualDataSetName: receipt.DatasetName,
+		VirtualFileDate:    receipt.DateTime,
+		Destination:        receipt.Destination,
+		Originator:         receipt.Originator,
+		CreatorOfNERP:      receipt.CreatorOfNERP,
+		ReasonCode:         receipt.ReasonCode,
+		ReasonText:         receipt.ReasonText,
+		FileHash:           receipt.FileHash,
+		Signature:          receipt.Signature,
+	}
+	return s.sendEndResponse(ctx, nerp.Marshal())
+}
+
+// sendEndResponse sends an EERP or NERP and waits for the partner to confirm
+// it with an RTR
+func (s *Session) sendEndResponse(ctx context.Context, data []byte) error {
+	err := s.write(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	buffer, err := s.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	answer, t, err := DetermineMessageType(buffer)
+	if err != nil {
+		return err
+	}
+
+	if t != "RTR" {
+		return s.unexpectedAnswer(t, answer, "RTR")
+	}
+
+	return nil
+}
+
+// VirtualFileFromSFID converts the SFID received from the partner into the
+// description of the virtual file
+func VirtualFileFromSFID(sfid *startfile.SFID) VirtualFile {
+	return VirtualFile{
+		DatasetName:   sfid.DatasetName,
+		DateTime:      sfid.FileDateTime,
+		UserData:      sfid.UserData,
+		Destination:   sfid.Destination,
+		Originator:    sfid.Originator,
+		Format:        OFTP2FileFormat(sfid.FileFormat),
+		MaxRecordSize: sfid.MaxRecordSize,
+		SecurityLevel: OFTP2SecurityLevel(sfid.SecurityLevel),
+		CipherSuite:   sfid.CipherSuite,
+		Compressed:    sfid.Compression != 0,
+		Enveloped:     sfid.Envelope != 0,
+		SignedEERP:    sfid.SigningRequired,
+		Description:   sfid.VirtualFileDescription,
+	}
+}
+
+// eerpFor creates the end to end response for a received file. It goes back to
+// the originator of the file.
+func eerpFor(file VirtualFile) startfile.EERP {
+	return startfile.EERP{
+		VirtualDataSetName: file.DatasetName,
+		VirtualFileDate:    file.DateTime,
+		UserData:           file.UserData,
+		Destination:        file.Originator,
+		Originator:         file.Destination,
+	}
+}
+
+// ReceiptFrom converts a received EERP or NERP into a receipt
+func ReceiptFrom(p wire.Protocol) Receipt {
+	switch r := p.(type) {
+	case *startfile.EERP:
+		return Receipt{
+			DatasetName: r.VirtualDataSetName,
+			DateTime:    r.VirtualFileDate,
+			UserData:    r.UserData,
+			Destination: r.Destination,
+			Originator:  r.Originator,
+			FileHash:    r.FileHash,
+			Signature:   r.Signature,
+		}
+	case *startfile.NERP:
+		return Receipt{
+			DatasetName:   r.VirtualDataSetName,
+			DateTime:      r.VirtualFileDate,
+			Destination:   r.Destination,
+			Originator:    r.Originator,
+			CreatorOfNERP: r.CreatorOfNERP,
+			Negative:      true,
+			ReasonCode:    r.ReasonCode,
+			ReasonText:    r.ReasonText,
+			FileHash:      r.FileHash,
+			Signature:     r.Signature,
+		}
+	}
+	return Receipt{}
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+)
+
+// ESID reason codes that indicate a temporary problem of the partner
+var retryableESIDReasons = map[int]bool{
+	0: true, // session ended normally while a file was pending
+	5: true, // local site emergency close down
+	8: true, // resources not available
+	9: true, // time out
+}
+
+// EFNA reason codes that indicate a temporary problem of the partner
+var retryableEFNAReasons = map[int]bool{
+	10: true, // invalid record count
+	11: true, // invalid byte count
+	12: true, // access method failure
+}
+
+// IsRetryable classifies an error returned by the client. Errors with a
+// Retryable method, like SessionEndedError, decide on their own. It returns true if
+// the transmission may succeed when it is tried again later, and false if the
+// failure is permanent and a retry would be refused again:
+//
+//   - SFNA: the retry indicator of the partner decides
+//   - EFNA: record count, byte count and access method failures are retryable
+//   - ESID: emergency close down (05), resources not available (08) and time
+//     out (09) are retryable
+//   - time outs and broken connections are retryable
+//
+// All other errors, including cancellation of the context and problems with
+// local files, are permanent.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// protocol errors know if they are retryable
+	var protocolErr interface{ Retryable() bool }
+	if errors.As(err, &protocolErr) {
+		return protocolErr.Retryable()
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrNotConnected) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// NERP reason codes used for locally created final states (see RFC 5024,
+// 5.3.7)
+const (
+	nerpReasonESIDUnspecified  = 9  // ESID received with reason code 99
+	nerpReasonSFNAOffset       = 10 // added to the SFNA reason codes 01-06 and 10-20
+	nerpReasonEFNAOffset       = 10 // added to the EFNA reason codes 10-12
+	nerpReasonSignatureInvalid = 31
+	nerpReasonDecompression    = 32
+	nerpReasonDecryption       = 33
+	nerpReasonNotDelivered     = 35
+	nerpReasonUnspecified      = 99
+)
+
+// EFNA reason codes that have a NERP counterpart
+const (
+	efnaReasonInvalidRecordCount  = 10
+	efnaReasonInvalidByteCount    = 11
+	efnaReasonAccessMethodFailure = 12
+	efnaReasonInvalidSignature    = 21
+	efnaReasonDecryptionFailure   = 22
+	efnaReasonDecompression       = 23
+)
+
+// FailureReceipt creates the final status of a file the client gave up on. It
+// is the local equivalent of a negative end response (NERP): the reason code
+// is the one a NERP would carry for the failure and creator is the Odette ID
+// of the node giving up (usually the client's own ID).
+func FailureReceipt(file VirtualFile, creator string, err error) Receipt {
+	code, text := nerpReason(err)
+
+	return Receipt{
+		DatasetName:   file.DatasetName,
+		DateTime:      file.DateTime,
+		UserData:      file.UserData,
+		Destination:   file.Originator,
+		Originator:    file.Destination,
+		CreatorOfNERP: creator,
+		Negative:      true,
+		ReasonCode:    code,
+		ReasonText:    text,
+	}
+}
+
+// nerpReason maps the failure to a NERP reason code and text
+func nerpReason(err error) (int, string) {
+	if err == nil {
+		return nerpReasonUnspecified, ""
+	}
+
+	var sfna *StartFileRejectedError
+	if errors.As(err, &sfna) {
+		code := sfna.ReasonCode
+		if sfna.RetryIndicator {
+			// the partner would have accepted the file later on, but we gave up
+			return nerpReasonNotDelivered, sfna.Text
+		}
+		if (code >= 1 && code <= 6) || (code >= 10 && code <= 20) {
+			return nerpReasonSFNAOffset + code, sfna.Text
+		}
+		return nerpReasonUnspecified, sfna.Text
+	}
+
+	var efna *EndFileRejectedError
+	if errors.As(err, &efna) {
+		switch efna.ReasonCode {
+		case efnaReasonInvalidSignature:
+			return nerpReasonSignatureInvalid, efna.Text
+		case efnaReasonDecryptionFailure:
+			return nerpReasonDecryption, efna.Text
+		case efnaReasonDecompression:
+			return nerpReasonDecompression, efna.Text
+		case efnaReasonInvalidRecordCount, efnaReasonInvalidByteCount, efnaReasonAccessMethodFailure:
+			return nerpReasonEFNAOffset + efna.ReasonCode, efna.Text
+		}
+		if efna.Retryable() {
+			// the partner would have accepted the file later on, but we gave up
+			return nerpReasonNotDelivered, efna.Text
+		}
+		return nerpReasonUnspecified, efna.Text
+	}
+
+	var nerp *NegativeEndResponseError
+	if errors.As(err, &nerp) {
+		return nerp.ReasonCode, nerp.Text
+	}
+
+	var ended *SessionEndedError
+	if errors.As(err, &ended) {
+		switch ended.ReasonCode {
+		case 3, 4:
+			// the NERP reason codes 03 and 04 are the ESID reason codes
+			return ended.ReasonCode, ended.Text
+		case 99:
+			return nerpReasonESIDUnspecified, ended.Text
+		}
+	}
+
+	if IsRetryable(err) {
+		// retries exhausted
+		return nerpReasonNotDelivered, err.Error()
+	}
+
+	return nerpReasonUnspecified, err.Error()
+}
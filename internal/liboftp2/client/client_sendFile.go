@@ -1,168 +1,194 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"time"
 
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/logging"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/protocol"
 	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/endfile"
 	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/startfile"
 	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/transfer"
 )
 
-// SendFile sends a file to the OFTP2 server
-func (s *OFTP2Client) SendFile(datasetName string, filePath string, format OFTP2FileFormat, destination string, securityLevel OFTP2SecurityLevel, cipher, compression, envelope, signed bool) error {
+// SendFile sends a file to the OFTP2 server. If the context is done before
+// the transfer is finished, the session is aborted.
+func (s *Session) SendFile(ctx context.Context, datasetName string, filePath string, format OFTP2FileFormat, destination string, securityLevel OFTP2SecurityLevel, cipher, compression, envelope, signed bool) error {
 
-	var cipherSuite, compressionIndicator, envelopeIndicator int
-
-	if cipher {
-		cipherSuite = 1
-	} else {
-		cipherSuite = 0
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
 	}
+	defer file.Close()
 
-	if compression {
-		compressionIndicator = 1
-	} else {
-		compressionIndicator = 0
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return err
 	}
 
-	if envelope {
-		envelopeIndicator = 1
-	} else {
-		envelopeIndicator = 0
+	virtualFile := VirtualFile{
+		DatasetName:   datasetName,
+		DateTime:      fileInfo.ModTime(),
+		Destination:   destination,
+		Format:        format,
+		SecurityLevel: securityLevel,
+		CipherSuite:   boolToIndicator(cipher),
+		Compressed:    compression,
+		Enveloped:     envelope,
+		SignedEERP:    signed,
 	}
 
-	fileInfo, err := os.Stat(filePath)
-	if err != nil {
-		return err
+	return s.SendStream(ctx, virtualFile, file, fileInfo.Size())
+}
+
+// SendStream sends the data read from r as virtual file to the OFTP2 server.
+// The size of the data in bytes is announced to the server; if it is not
+// known, a negative size can be given. If the context is done before the
+// transfer is finished, the session is aborted.
+func (s *Session) SendStream(ctx context.Context, virtualFile VirtualFile, r io.Reader, size int64) error {
+	_, err := s.sendStream(ctx, virtualFile, r, size)
+	return err
+}
+
+// sendStream sends the file and returns the statistics of the transfer, which
+// are also reported to the observer.
+func (s *Session) sendStream(ctx context.Context, virtualFile VirtualFile, r io.Reader, size int64) (TransferStats, error) {
+
+	if s.con == nil {
+		return TransferStats{}, ErrNotConnected
+	}
+	if state := s.machine.State(); state != protocol.StateSpeaker {
+		return TransferStats{}, errors.New(fmt.Sprintf("cannot send a file in state %v", state))
 	}
 
-	fileSize := fileInfo.Size()
+	sfid := virtualFile.sfid(s.client.OdetteId, s.partner.Id, size)
+	datasetName := sfid.DatasetName
 
-	var maxRecordSize int
+	// the partner would refuse the file, the session is not affected
+	if err := ValidateOdetteId(sfid.Originator); err != nil {
+		return TransferStats{}, fmt.Errorf("originator of file %s: %w", datasetName, err)
+	}
+	if err := ValidateOdetteId(sfid.Destination); err != nil {
+		return TransferStats{}, fmt.Errorf("destination of file %s: %w", datasetName, err)
+	}
+	announced := VirtualFileFromSFID(&sfid)
 
-	if format == FileFormatText || format == FileFormatUnstructured {
-		maxRecordSize = 0
-	} else {
-		// TODO: Determine correct value
-		maxRecordSize = 0
-	}
-
-	// Report at least 1 kB of file size, even if file is smaller
-	fileSizeInK := uint64(fileSize / 1024)
-	if fileSizeInK == 0 && fileSize != 0 {
-		fileSizeInK = 1
-	}
-
-	sfid := startfile.SFID{
-		DatasetName:            datasetName,
-		FileDateTime:           fileInfo.ModTime(),
-		UserData:               "",
-		Destination:            destination,
-		Originator:             s.OdetteId,
-		FileFormat:             string(format),
-		MaxRecordSize:          maxRecordSize,
-		FileSizeInK:            fileSizeInK,
-		OriginalFileSizeInK:    fileSizeInK,
-		RestartPosition:        0,
-		SecurityLevel:          int(securityLevel),
-		CipherSuite:            cipherSuite,
-		Compression:            compressionIndicator,
-		Envelope:               envelopeIndicator,
-		SigningRequired:        signed,
-		VirtualFileDescription: "",
-	}
-
-	err = s.write(sfid.Marshal())
+	stats := TransferStats{Start: time.Now()}
+
+	err := s.write(ctx, sfid.Marshal())
 	if err != nil {
-		return err
+		return stats, err
 	}
 
-	// Read answer from server
 	// Read answer from communication partner
-	buffer, err := s.read()
+	buffer, err := s.read(ctx)
+	if err != nil {
+		return stats, err
+	}
+
 	answer, t, err := DetermineMessageType(buffer)
 	if err != nil {
-		return err
+		return stats, err
 	}
 
 	if t == "SFNA" {
-		return errors.New(fmt.Sprintf("partner does not accept file: %v", answer))
+		s.logger().Warn("file rejected", logging.KeyDataset, datasetName, "reason", answer)
+		return stats, s.fileRejected(&announced, size, &stats, newRejectionError(datasetName, answer))
 	} else if t != "SFPA" {
-		return errors.New(fmt.Sprintf("unknown answer. Expected SFPA or SFNA, got %s", t))
+		return stats, s.unexpectedAnswer(t, answer, "SFPA or SFNA")
 	}
 
 	// get server answer
 	sfpa := startfile.SFPA{}
 	err = sfpa.Parse(buffer)
 	if err != nil {
-		return err
+		return stats, err
 	}
 
 	if sfpa.AnswerCount > sfid.RestartPosition {
-		return errors.New(fmt.Sprintf("restart positions do not fit we: %d, server: %d", sfid.RestartPosition, sfpa.AnswerCount))
+		return stats, errors.New(fmt.Sprintf("restart positions do not fit we: %d, server: %d", sfid.RestartPosition, sfpa.AnswerCount))
 	}
 
-	// Server is ready to receive our data, so send it to it
-	file, err := os.Open(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	var bytesTransmitted uint64
+	s.notify(EventFileStarted, datasetName, size, &stats)
 
-	var credits = s.serverCredit
+	// Server is ready to receive our data, so send it to it
+	s.credit = s.partner.Credit
 	var maxReadBufferSize = int(s.maxReadBufferSize())
 
+	// the next buffer is read ahead to know if the current one is the last one
+	chunks := newChunkReader(r, maxReadBufferSize)
+
 	for true {
-		fileBuffer := make([]byte, maxReadBufferSize)
-		n, err := file.Read(fileBuffer)
-		if n != 0 && err != nil {
-			return err
+		chunk, last, err := chunks.next()
+		if err != nil {
+			return stats, err
 		}
 
-		if n == 0 {
+		if len(chunk) == 0 {
 			// EOF
 			break
 		}
 
-		sendBuffer := s.splitBufferIntoSubRecords(fileBuffer[0:n], n < maxReadBufferSize)
+		sendBuffer := s.splitBufferIntoSubRecords(chunk, last)
 
 		data := transfer.DATA{
-			Length: uint64(n),
+			Length: uint64(len(chunk)),
 			Buffer: sendBuffer,
 		}
 
-		err = s.write(data.Marshal())
+		dataBuffer := data.Marshal()
+
+		err = s.write(ctx, dataBuffer)
 		if err != nil {
-			return err
+			return stats, err
 		}
 
-		bytesTransmitted += uint64(n)
+		stats.Bytes += uint64(len(chunk))
+		stats.WireBytes += uint64(len(dataBuffer))
+		stats.Buffers++
+
+		s.notify(EventProgress, datasetName, size, &stats)
 
-		credits--
+		s.credit--
 
-		if credits <= 0 {
+		if s.credit <= 0 {
 			// we exceeded our credits, wait for the server to send us a CDT command
-			// get the credit command
-			cdt := transfer.CDT{}
+			stats.CreditStalls++
+			s.notify(EventCreditExhausted, datasetName, size, &stats)
+			stallStart := time.Now()
 
-			buffer, err := s.read()
-			err = cdt.Parse(buffer)
+			buffer, err := s.read(ctx)
 			if err != nil {
-				return err
+				return stats, err
 			}
 
-			credits = s.serverCredit
+			answer, t, err := DetermineMessageType(buffer)
+			if err != nil {
+				return stats, err
+			}
+
+			if t != "CDT" {
+				return stats, s.unexpectedAnswer(t, answer, "CDT")
+			}
+
+			stats.StallTime += time.Since(stallStart)
+			s.notify(EventCreditReceived, datasetName, size, &stats)
+
+			s.credit = s.partner.Credit
 		}
 
+		if last {
+			break
+		}
 	}
 
 	var recordCount uint64 = 0
 
-	if format == FileFormatText || format == FileFormatUnstructured {
+	if sfid.FileFormat == string(FileFormatText) || sfid.FileFormat == string(FileFormatUnstructured) {
 		recordCount = 0
 	} else {
 		// TODO: Determine correct value
@@ -172,13 +198,118 @@ func (s *OFTP2Client) SendFile(datasetName string, filePath string, format OFTP2
 	// End file
 	efid := endfile.EFID{
 		RecordCount: recordCount,
-		UnitCount:   bytesTransmitted,
+		UnitCount:   stats.Bytes,
 	}
 
-	err = s.write(efid.Marshal())
+	err = s.write(ctx, efid.Marshal())
 	if err != nil {
-		return err
+		return stats, err
+	}
+
+	// Read answer to the end of file, only after the answer the next file can
+	// be started
+	buffer, err = s.read(ctx)
+	if err != nil {
+		return stats, err
+	}
+
+	answer, t, err = DetermineMessageType(buffer)
+	if err != nil {
+		return stats, err
+	}
+
+	if t == "EFNA" {
+		s.logger().Warn("file rejected", logging.KeyDataset, datasetName, "reason", answer)
+		return stats, s.fileRejected(&announced, size, &stats, newRejectionError(datasetName, answer))
+	} else if t != "EFPA" {
+		return stats, s.unexpectedAnswer(t, answer, "EFPA or EFNA")
+	}
+
+	if answer.(*endfile.EFPA).ChangeDirection {
+		// the partner wants to become speaker, no further file can be sent
+		// until the application hands over the right to speak with
+		// ReceiveFiles
+		s.logger().Debug("partner requested change direction", logging.KeyDataset, datasetName)
+	}
+
+	stats.End = time.Now()
+	s.notifyEvent(Event{
+		Kind:        EventFileSent,
+		DatasetName: datasetName,
+		Size:        size,
+		Stats:       &stats,
+		File:        &announced,
+	})
+
+	s.logger().Info("file sent", logging.KeyDataset, datasetName, "destination", sfid.Destination, "bytes", stats.Bytes,
+		"duration", stats.Duration(), "credit_stalls", stats.CreditStalls)
+
+	return stats, nil
+}
+
+// fileRejected reports the rejection of a file by the partner to the observer
+// and returns the error
+func (s *Session) fileRejected(file *VirtualFile, size int64, stats *TransferStats, err error) error {
+	stats.End = time.Now()
+	s.notifyEvent(Event{
+		Kind:        EventFileRejected,
+		DatasetName: file.DatasetName,
+		Size:        size,
+		Stats:       stats,
+		File:        file,
+		Err:         err,
+	})
+	return err
+}
+
+// chunkReader reads data in chunks of a fixed size and detects the last chunk
+// by reading one chunk ahead.
+type chunkReader struct {
+	reader    io.Reader
+	chunkSize int
+	ahead     []byte
+	eof       bool
+}
+
+func newChunkReader(r io.Reader, chunkSize int) *chunkReader {
+	return &chunkReader{reader: r, chunkSize: chunkSize}
+}
+
+// fill reads a complete chunk, or less if the end of the data is reached.
+func (c *chunkReader) fill() ([]byte, error) {
+	if c.eof {
+		return nil, nil
+	}
+
+	buffer := make([]byte, c.chunkSize)
+	n, err := io.ReadFull(c.reader, buffer)
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		c.eof = true
+	} else if err != nil {
+		return nil, err
+	}
+
+	return buffer[0:n], nil
+}
+
+// next returns the next chunk and whether it is the last one. An empty chunk
+// signals that there is no more data.
+func (c *chunkReader) next() ([]byte, bool, error) {
+	var err error
+
+	current := c.ahead
+	if current == nil {
+		current, err = c.fill()
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	c.ahead, err = c.fill()
+	if err != nil {
+		return nil, false, err
 	}
 
-	return nil
+	return current, len(c.ahead) == 0, nil
 }
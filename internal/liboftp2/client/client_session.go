@@ -1,20 +1,196 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/logging"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/protocol"
 	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
 )
 
-// StartSession opens a session with the server
-func (s *OFTP2Client) StartSession(password string, compression, restart, authentication bool) error {
+// ErrAuthenticationMismatch is returned by Session.Start if the partner did not
+// answer the request for secure authentication with the same flag. The session
+// is ended in this case.
+var ErrAuthenticationMismatch = errors.New("cannot agree on security features")
+
+// Phase of a session
+type Phase int
+
+const (
+	// PhaseConnected is the phase after the ready message of the server was
+	// received and before the session was started
+	PhaseConnected Phase = iota
+
+	// PhaseSession is the phase after the session was started while no file
+	// is transferred
+	PhaseSession
+
+	// PhaseTransfer is the phase while a file is transferred, from the start
+	// file command to the answer to the end file command
+	PhaseTransfer
+
+	// PhaseClosed is the phase after the session was ended or the connection
+	// was closed
+	PhaseClosed
+)
+
+var phaseNames = map[Phase]string{
+	PhaseConnected: "connected",
+	PhaseSession:   "session",
+	PhaseTransfer:  "transfer",
+	PhaseClosed:    "closed",
+}
+
+func (p Phase) String() string {
+	if name, ok := phaseNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("phase %d", int(p))
+}
+
+// Parameters are the session parameters the partner sent in its SSID
+type Parameters struct {
+	// Id is the Odette ID of the partner
+	Id string
+
+	// Password of the partner
+	Password string
+
+	// BufferSize is the maximum size of an exchange buffer the partner accepts
+	BufferSize uint32
+
+	// Capability is S (send), R (receive) or B (both)
+	Capability string
+
+	// Compression, Restart and Special tell whether the partner supports
+	// buffer compression, restart and special logic
+	Compression bool
+	Restart     bool
+	Special     bool
+
+	// Credit is the number of DATA buffers that may be sent before the
+	// receiver has to grant new credit
+	Credit uint32
+
+	// SecureAuthentication tells whether secure authentication is used
+	SecureAuthentication bool
+
+	// UserData sent by the partner
+	UserData string
+}
+
+// Session is a connection to an OFTP2 server created by OFTP2Client.Dial or
+// OFTP2Client.Open. It owns the connection, the parameters negotiated with the
+// partner and the state of the protocol. A session must only be used by one
+// goroutine at a time.
+//
+// Every command sent or received is checked against the state tables of the
+// protocol. A command of the partner that is not allowed in the current state
+// aborts the session with ESID reason 02 "Protocol violation".
+type Session struct {
+	client          OFTP2Client       // Configuration the session was opened with
+	con             net.Conn          // Network connection
+	log             logging.Logger    // Logger of the session
+	partner         Parameters        // Parameters negotiated with the partner
+	endpoint        Endpoint          // Endpoint the session is connected to
+	machine         *protocol.Machine // State of the protocol
+	credit          uint32            // Buffers left in the credit window while a file is sent
+	failureReported bool              // Failure of the session has been reported to the observer
+}
+
+// newSession creates a session using a copy of the configuration of the
+// client, so later changes of the client do not affect it
+func newSession(c *OFTP2Client) *Session {
+	s := &Session{client: *c, machine: protocol.NewMachine(protocol.Initiator)}
+	s.client.current = nil
+	return s
+}
+
+// PartnerId returns the Odette ID of the partner. It is empty before the
+// session has been started.
+func (s *Session) PartnerId() string {
+	return s.partner.Id
+}
+
+// Endpoint returns the endpoint of the server the session is connected to
+func (s *Session) Endpoint() Endpoint {
+	return s.endpoint
+}
+
+// TLSConnectionState returns the state of the TLS connection to the server.
+// The flag is false if the connection is not secured with TLS.
+func (s *Session) TLSConnectionState() (tls.ConnectionState, bool) {
+	tlsConnection, ok := s.con.(*tls.Conn)
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	return tlsConnection.ConnectionState(), true
+}
+
+// Parameters returns the parameters negotiated with the partner. They are
+// empty before the session has been started.
+func (s *Session) Parameters() Parameters {
+	return s.partner
+}
+
+// Phase returns the current phase of the protocol
+func (s *Session) Phase() Phase {
+	state := s.machine.State()
+
+	switch {
+	case s.con == nil || state == protocol.StateClosed:
+		return PhaseClosed
+	case state.Transfer():
+		return PhaseTransfer
+	case state.Started():
+		return PhaseSession
+	default:
+		return PhaseConnected
+	}
+}
+
+// Speaker tells whether the client is the speaker, i.e. is allowed to send
+// files
+func (s *Session) Speaker() bool {
+	return s.machine.State().Speaker()
+}
+
+// Start starts the session by exchanging the SSID commands. If the context is
+// done before the server answered, the session is aborted.
+func (s *Session) Start(ctx context.Context, password string, compression, restart, authentication bool) error {
+	err := s.startSession(ctx, password, compression, restart, authentication)
+	if err != nil {
+		s.sessionFailed(err)
+	}
+	return err
+}
+
+// startSession exchanges the SSID commands with the server
+func (s *Session) startSession(ctx context.Context, password string, compression, restart, authentication bool) error {
+
+	if phase := s.Phase(); phase != PhaseConnected {
+		return errors.New(fmt.Sprintf("cannot start a session in phase %v", phase))
+	}
+
+	// the partner would refuse the session
+	if err := ValidateOdetteId(s.client.OdetteId); err != nil {
+		return err
+	}
+	switch capability := s.client.capability(); capability {
+	case CapabilitySend, CapabilityReceive, CapabilityBoth:
+	default:
+		return errors.New(fmt.Sprintf("invalid capability %q", capability))
+	}
 
 	ssid := session.SSID{
-		Id:             s.OdetteId,
+		Id:             s.client.OdetteId,
 		Password:       password,
 		BufferSize:     1024,
-		Capability:     "S",
+		Capability:     s.client.capability(),
 		Compress:       compression,
 		Restart:        restart,
 		Special:        authentication,
@@ -24,13 +200,13 @@ func (s *OFTP2Client) StartSession(password string, compression, restart, authen
 	}
 
 	// Send out SSID to the server
-	err := s.write(ssid.Marshal())
+	err := s.write(ctx, ssid.Marshal())
 	if err != nil {
 		return err
 	}
 
 	// Read the server's SSID
-	buffer, err := s.read()
+	buffer, err := s.read(ctx)
 	if err != nil {
 		return err
 	}
@@ -41,7 +217,9 @@ func (s *OFTP2Client) StartSession(password string, compression, restart, authen
 	}
 
 	if t == "ESID" {
-		return errors.New(fmt.Sprintf("server terminated helo: %v", answer))
+		s.logger().Warn("session rejected", "reason", answer)
+		_ = s.Close()
+		return newSessionEndedError(answer.(*session.ESID))
 	} else if t != "SSID" {
 		return errors.New(fmt.Sprintf("server send unexpected answer: %v", answer))
 	}
@@ -54,26 +232,30 @@ func (s *OFTP2Client) StartSession(password string, compression, restart, authen
 
 	// negotiation of security is not allowed
 	if serverSSID.Authentication != authentication {
-		_ = s.EndSession() // ignore error, we are anyhow lost
-		return errors.New("cannot agree on security features")
+		_ = s.End(ctx) // ignore error, we are anyhow lost
+		return ErrAuthenticationMismatch
 	}
 
-	s.serverId = serverSSID.Id
-	s.serverPassword = serverSSID.Password
-	s.serverBufferSize = serverSSID.BufferSize
-	s.serverCapability = serverSSID.Capability
-	s.serverCompress = serverSSID.Compress
-	s.serverRestartSupported = serverSSID.Restart
-	s.serverSpecial = serverSSID.Special
-	s.serverCredit = serverSSID.Credit
-	s.serverAuthenticationSupported = serverSSID.Authentication
-	s.serverUserData = serverSSID.UserData
+	s.partner.Id = serverSSID.Id
+	s.partner.Password = serverSSID.Password
+	s.partner.BufferSize = serverSSID.BufferSize
+	s.partner.Capability = serverSSID.Capability
+	s.partner.Compression = serverSSID.Compress
+	s.partner.Restart = serverSSID.Restart
+	s.partner.Special = serverSSID.Special
+	s.partner.Credit = serverSSID.Credit
+	s.partner.SecureAuthentication = serverSSID.Authentication
+	s.partner.UserData = serverSSID.UserData
+
+	s.log = s.logger().With(logging.KeyPartner, serverSSID.Id)
+	s.log.Info("session started", "buffer_size", s.partner.BufferSize, "credit", s.partner.Credit)
+	s.notify(EventSessionStarted, "", 0, nil)
 
 	return nil
 }
 
-// EndSession closes the session
-func (s *OFTP2Client) EndSession() error {
+// End ends the session with an ESID and closes the connection
+func (s *Session) End(ctx context.Context) error {
 
 	esid := session.ESID{
 		ReasonCode: 0,
@@ -81,10 +263,13 @@ func (s *OFTP2Client) EndSession() error {
 	}
 
 	// Send out ESID to the server
-	err := s.write(esid.Marshal())
+	err := s.write(ctx, esid.Marshal())
 	if err != nil {
 		return err
 	}
 
-	return nil
+	s.logger().Info("session ended")
+	s.notify(EventSessionEnded, "", 0, nil)
+
+	return s.Close()
 }
@@ -0,0 +1,206 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/protocol"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/trace"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/endfile"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+)
+
+// readyServer accepts connections and sends the ready message (SSRM) on each
+func readyServer(t *testing.T) (string, int) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		connections := make([]net.Conn, 0)
+		defer func() {
+			for _, con := range connections {
+				con.Close()
+			}
+		}()
+
+		for {
+			con, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			connections = append(connections, con)
+
+			ssrm := session.SSRM{}
+			_, _ = con.Write(wire.EncodeStreamBuffer(ssrm.Marshal()))
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func TestDial(t *testing.T) {
+	host, port := readyServer(t)
+	c := &client.OFTP2Client{ServerHost: host, ServerPort: port, OdetteId: "O0013LOCAL"}
+
+	s1, err := c.Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s1.Close()
+
+	// the client can open further sessions, they do not share any state
+	s2, err := c.Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s1.Phase() != client.PhaseConnected || s1.Speaker() || s1.PartnerId() != "" {
+		t.Errorf("wrong state after connecting: %v %v %q", s1.Phase(), s1.Speaker(), s1.PartnerId())
+	}
+
+	err = s1.SendStream(context.Background(), client.VirtualFile{DatasetName: "TEST"}, bytes.NewReader(nil), 0)
+	if err == nil {
+		t.Errorf("file sent before the session was started")
+	}
+
+	_ = s2.Close()
+	if s2.Phase() != client.PhaseClosed || s1.Phase() != client.PhaseConnected {
+		t.Errorf("closing a session affected another one")
+	}
+
+	err = s2.Start(context.Background(), "", false, false, false)
+	if err == nil {
+		t.Errorf("closed session started")
+	}
+}
+
+func TestFuzzerShortBuffer(t *testing.T) {
+	host, port := readyServer(t)
+
+	var traced bytes.Buffer
+	c := &client.OFTP2Client{ServerHost: host, ServerPort: port, OdetteId: "O0013LOCAL", ResponseTimeout: 100 * time.Millisecond}
+	c.Trace = trace.NewRecorder(&traced)
+
+	// the fuzzer cuts the stream transmission header of the SSID
+	var short []byte
+	c.Fuzzer = func(data []byte) []byte {
+		short = data[:2]
+		return short
+	}
+
+	s, err := c.Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Start(context.Background(), "", false, false, false); err == nil {
+		t.Fatal("session started with a broken SSID")
+	}
+
+	records, err := trace.ReadTrace(&traced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []byte
+	for _, record := range records {
+		if record.Direction == trace.Outbound {
+			sent = record.Data
+		}
+	}
+	if len(sent) == 0 || !bytes.Equal(sent, short) {
+		t.Errorf("expected the raw buffer in the trace, got %x", sent)
+	}
+}
+
+func TestDeprecatedMethodsWithoutConnection(t *testing.T) {
+	c := &client.OFTP2Client{}
+
+	if err := c.StartSession("", false, false, false); err != client.ErrNotConnected {
+		t.Errorf("expected ErrNotConnected, got %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("close without connection failed: %v", err)
+	}
+}
+
+func TestPhaseString(t *testing.T) {
+	if s := client.PhaseTransfer.String(); s != "transfer" {
+		t.Errorf("wrong name %q", s)
+	}
+}
+
+func TestProtocolViolation(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// the server answers the SFID with an EFPA instead of SFPA or SFNA
+	esid := make(chan *session.ESID, 1)
+	go func() {
+		defer close(esid)
+
+		con, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer con.Close()
+
+		ssrm := session.SSRM{}
+		ssid := session.SSID{Id: "O0013SERVER", BufferSize: 1024, Capability: "B", Credit: 10}
+		efpa := endfile.EFPA{}
+
+		_, _ = con.Write(wire.EncodeStreamBuffer(ssrm.Marshal()))
+		_, _ = wire.ReadStreamBuffer(con)
+		_, _ = con.Write(wire.EncodeStreamBuffer(ssid.Marshal()))
+		_, _ = wire.ReadStreamBuffer(con)
+		_, _ = con.Write(wire.EncodeStreamBuffer(efpa.Marshal()))
+
+		buffer, err := wire.ReadStreamBuffer(con)
+		if err != nil {
+			return
+		}
+		answer := session.ESID{}
+		if answer.Parse(buffer) == nil {
+			esid <- &answer
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	c := &client.OFTP2Client{ServerHost: addr.IP.String(), ServerPort: addr.Port, OdetteId: "O0013LOCAL"}
+
+	s, err := c.Open(context.Background(), "", false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Phase() != client.PhaseSession || !s.Speaker() {
+		t.Fatalf("wrong state after start: %v %v", s.Phase(), s.Speaker())
+	}
+
+	err = s.SendStream(context.Background(), client.VirtualFile{DatasetName: "TEST"}, bytes.NewReader([]byte("data")), 4)
+
+	var violation *protocol.ViolationError
+	if !errors.As(err, &violation) || violation.Command != "EFPA" {
+		t.Fatalf("expected protocol violation, got %v", err)
+	}
+	if s.Phase() != client.PhaseClosed {
+		t.Errorf("session not closed after violation: %v", s.Phase())
+	}
+
+	answer := <-esid
+	if answer == nil || answer.ReasonCode != 2 {
+		t.Errorf("expected ESID reason 02, got %+v", answer)
+	}
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+)
+
+// DefaultResponseTimeout is used if no ResponseTimeout is set on the client
+const DefaultResponseTimeout = 5 * time.Minute
+
+// DefaultInactivityTimeout is used if no InactivityTimeout is set on the client
+const DefaultInactivityTimeout = 5 * time.Minute
+
+// DefaultConnectTimeout is used if no ConnectTimeout is set on the client
+const DefaultConnectTimeout = 30 * time.Second
+
+// abortTimeout limits the time spent sending the ESID when a session is aborted
+const abortTimeout = 5 * time.Second
+
+// ESID reason codes used when aborting a session
+const (
+	esidReasonEmergencyCloseDown = 5
+	esidReasonTimeout            = 9
+)
+
+// ErrTimeout is returned (wrapped) if the server did not answer in time. Use
+// errors.Is to check for it.
+var ErrTimeout = errors.New("timeout")
+
+// ErrNotConnected is returned if a command is sent or read without a connection.
+var ErrNotConnected = errors.New("not connected")
+
+// responseTimeout returns the configured response timer
+func (c *OFTP2Client) responseTimeout() time.Duration {
+	if c.ResponseTimeout > 0 {
+		return c.ResponseTimeout
+	}
+	return DefaultResponseTimeout
+}
+
+// inactivityTimeout returns the configured inactivity timer
+func (c *OFTP2Client) inactivityTimeout() time.Duration {
+	if c.InactivityTimeout > 0 {
+		return c.InactivityTimeout
+	}
+	return DefaultInactivityTimeout
+}
+
+// connectTimeout returns the configured time to connect to an endpoint
+func (c *OFTP2Client) connectTimeout() time.Duration {
+	if c.ConnectTimeout > 0 {
+		return c.ConnectTimeout
+	}
+	return DefaultConnectTimeout
+}
+
+// deadline calculates the deadline for an I/O operation from the timer and
+// the deadline of the context, whichever comes first.
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	d := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(d) {
+		return ctxDeadline
+	}
+	return d
+}
+
+// watch interrupts blocking I/O on the connection when the context is
+// cancelled. The returned function must be called after the I/O operation has
+// finished.
+func (s *Session) watch(ctx context.Context) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	con := s.con
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			// a deadline in the past unblocks all pending reads and writes
+			_ = con.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
+
+// ioError converts an error of an I/O operation that was stopped by a timer or
+// the context. The session is aborted with an ESID in this case, because the
+// protocol state is unknown afterwards. Other errors are returned unchanged.
+func (s *Session) ioError(ctx context.Context, err error, timeout time.Duration) error {
+
+	if ctx.Err() == context.Canceled {
+		s.abort(esidReasonEmergencyCloseDown, "Cancelled")
+		return ctx.Err()
+	}
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		s.sessionFailed(err)
+		return err
+	}
+
+	result := ctx.Err()
+	if result == nil {
+		result = fmt.Errorf("%w: no answer from partner within %v", ErrTimeout, timeout)
+	}
+
+	s.abort(esidReasonTimeout, "Time out")
+
+	return result
+}
+
+// abort ends the session with an ESID of the given reason and closes the
+// connection. Errors are ignored because the session is lost anyway.
+func (s *Session) abort(reasonCode int, reasonText string) {
+	if s.con == nil {
+		return
+	}
+
+	s.logger().Warn("aborting session", "reason", reasonText)
+	s.sessionFailed(&SessionAbortedError{
+		ReasonCode: reasonCode,
+		Reason:     session.ESIDReasonText(reasonCode),
+		Text:       reasonText,
+	})
+
+	con := s.con
+	_ = con.SetDeadline(time.Now().Add(abortTimeout))
+
+	esid := session.ESID{
+		ReasonCode: reasonCode,
+		ReasonText: reasonText,
+	}
+
+	_ = s.send(esid.Marshal())
+	s.machine.Close()
+	_ = s.Close()
+}
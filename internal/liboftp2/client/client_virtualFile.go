@@ -0,0 +1,117 @@
+package client
+
+import (
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/startfile"
+)
+
+// VirtualFile describes a virtual file sent to the partner. The fields map onto
+// the fields of the Start File (SFID) command.
+type VirtualFile struct {
+	// DatasetName is the name of the virtual file as agreed with the partner
+	DatasetName string
+
+	// DateTime is the time the file was made available for transmission. If
+	// it is zero, the current time is used.
+	DateTime time.Time
+
+	// UserData is an optional string of 8 characters with bilateral meaning
+	UserData string
+
+	// Destination is the Odette ID of the final recipient of the file. If it
+	// is empty, the Odette ID of the partner is used.
+	Destination string
+
+	// Originator is the Odette ID of the creator of the file. If it is empty,
+	// the Odette ID of the client is used.
+	Originator string
+
+	// Format of the file. If it is empty, FileFormatUnstructured is used.
+	Format OFTP2FileFormat
+
+	// MaxRecordSize is the length of the longest record for the formats F and
+	// V. It must be 0 for the formats U and T.
+	MaxRecordSize int
+
+	// SecurityLevel indicates whether the file has been signed and/or encrypted
+	SecurityLevel OFTP2SecurityLevel
+
+	// CipherSuite used to sign and/or encrypt the file and the EERP (0 = none)
+	CipherSuite int
+
+	// Compressed indicates that the file has been compressed with ZLIB
+	Compressed bool
+
+	// Enveloped indicates that the file is enveloped using CMS
+	Enveloped bool
+
+	// SignedEERP requests a signed end to end response
+	SignedEERP bool
+
+	// Description is an optional description of the file (max. 999 bytes)
+	Description string
+}
+
+// sfid builds the Start File command for the virtual file. The size of the file
+// in bytes is used to calculate the size in 1K blocks; a negative size stands
+// for an unknown size.
+func (v *VirtualFile) sfid(defaultOriginator, defaultDestination string, size int64) startfile.SFID {
+
+	dateTime := v.DateTime
+	if dateTime.IsZero() {
+		dateTime = time.Now()
+	}
+
+	originator := v.Originator
+	if originator == "" {
+		originator = defaultOriginator
+	}
+
+	destination := v.Destination
+	if destination == "" {
+		destination = defaultDestination
+	}
+
+	format := v.Format
+	if format == "" {
+		format = FileFormatUnstructured
+	}
+
+	// Report at least 1 kB of file size, even if file is smaller. If the size
+	// is not known, zero is reported.
+	var fileSizeInK uint64
+	if size > 0 {
+		fileSizeInK = uint64(size / 1024)
+		if fileSizeInK == 0 {
+			fileSizeInK = 1
+		}
+	}
+
+	return startfile.SFID{
+		DatasetName:            v.DatasetName,
+		FileDateTime:           dateTime,
+		UserData:               v.UserData,
+		Destination:            destination,
+		Originator:             originator,
+		FileFormat:             string(format),
+		MaxRecordSize:          v.MaxRecordSize,
+		FileSizeInK:            fileSizeInK,
+		OriginalFileSizeInK:    fileSizeInK,
+		RestartPosition:        0,
+		SecurityLevel:          int(v.SecurityLevel),
+		CipherSuite:            v.CipherSuite,
+		Compression:            boolToIndicator(v.Compressed),
+		Envelope:               boolToIndicator(v.Enveloped),
+		SigningRequired:        v.SignedEERP,
+		VirtualFileDescription: v.Description,
+	}
+}
+
+// boolToIndicator converts a flag into the numeric indicator used by SFID
+func boolToIndicator(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
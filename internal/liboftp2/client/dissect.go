@@ -0,0 +1,100 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/transfer"
+)
+
+// Dissection is the result of dissecting a single exchange buffer found in a
+// captured data stream.
+type Dissection struct {
+	// Offset of the exchange buffer (including its stream transmission header)
+	// in the captured stream
+	Offset int
+
+	// Length of the exchange buffer without stream transmission header
+	Length int
+
+	// Type of the command, e.g. SFID. Empty if the command is unknown.
+	Type string
+
+	// Message is the parsed command, nil if the command is unknown
+	Message wire.Protocol
+
+	// Fields of the command decoded along its format definition
+	Fields []wire.Field
+
+	// SubRecords contains the sub-records of a DATA command
+	SubRecords []transfer.SubRecord
+
+	// Err contains the first problem found while dissecting the buffer
+	Err error
+}
+
+// DissectStream splits a captured OFTP2 TCP stream into its exchange buffers and
+// decodes each of them field by field. Problems with single buffers are reported
+// in the Dissection, an error is only returned if the stream itself cannot be
+// split any further; in this case the buffers found so far are returned too.
+func DissectStream(stream []byte) ([]Dissection, error) {
+	buffers, splitErr := wire.SplitStream(stream)
+
+	result := make([]Dissection, 0, len(buffers))
+
+	for _, b := range buffers {
+		result = append(result, Dissect(b.Data, b.Offset))
+	}
+
+	return result, splitErr
+}
+
+// Dissect decodes a single exchange buffer (without stream transmission header)
+// field by field. The offset is only used for reporting.
+func Dissect(buffer []byte, offset int) Dissection {
+	d := Dissection{
+		Offset: offset,
+		Length: len(buffer),
+	}
+
+	p, t, err := determineMessageTypeSafe(buffer)
+	if p == nil {
+		d.Err = err
+		return d
+	}
+
+	d.Type = t
+	d.Message = p
+
+	fields, fieldErr := wire.DecodeFields(p.FormatDefinition(), buffer)
+	d.Fields = fields
+
+	if t == "DATA" {
+		subRecords, subErr := transfer.ParseSubRecords(buffer[1:])
+		d.SubRecords = subRecords
+		if fieldErr == nil {
+			fieldErr = subErr
+		}
+	}
+
+	if err != nil {
+		d.Err = err
+	} else {
+		d.Err = fieldErr
+	}
+
+	return d
+}
+
+// determineMessageTypeSafe works like DetermineMessageType but converts a panic
+// of the parser caused by a malformed buffer into an error.
+func determineMessageTypeSafe(buffer []byte) (p wire.Protocol, t string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			p, t, err = nil, "", errors.New(fmt.Sprintf("malformed command %q: %v", string(buffer[0:1]), r))
+		}
+	}()
+
+	return DetermineMessageType(buffer)
+}
@@ -3,4 +3,5 @@
 // with.
 //
 // For usage examples look into the command line tool in the main package.
+// Programs outside of this module use the client via the public package oftp2.
 package client
@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"regexp"
 
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/protocol"
 	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
 	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/authentication"
 	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/endfile"
@@ -14,10 +15,57 @@ import (
 	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/transfer"
 )
 
+// commandNames maps the command indicators to the names of the commands
+var commandNames = map[string]string{
+	session.ESIDCMD:        "ESID",
+	session.SSIDCMD:        "SSID",
+	session.SSRMCMD:        "SSRM",
+	authentication.AUCHCMD: "AUCH",
+	authentication.AURPCMD: "AURP",
+	authentication.SECDCMD: "SECD",
+	startfile.EERPCMD:      "EERP",
+	startfile.SFNACMD:      "SFNA",
+	startfile.NERPCMD:      "NERP",
+	startfile.RTRCMD:       "RTR",
+	startfile.SFIDCMD:      "SFID",
+	startfile.SFPACMD:      "SFPA",
+	transfer.DATACMD:       "DATA",
+	transfer.CDTCMD:        "CDT",
+	endfile.EFIDCMD:        "EFID",
+	endfile.EFNACMD:        "EFNA",
+	endfile.EFPACMD:        "EFPA",
+	wire.CDCMD:             "CD",
+}
+
+// commandName returns the name of the command in the buffer as used by the
+// protocol state machine, ok is false if the command indicator is unknown
+func commandName(input []byte) (name string, ok bool) {
+	if len(input) == 0 {
+		return "", false
+	}
+	name, ok = commandNames[string(input[0:1])]
+
+	// the listener requests the right to speak with the EFPA
+	if name == "EFPA" && len(input) > 1 && input[1] == 'Y' {
+		name = protocol.EFPAChangeDirection
+	}
+
+	// secure authentication is requested with SSIDAUTH at offset 47
+	if name == "SSID" && len(input) > 47 && input[47] == 'Y' {
+		name = protocol.SSIDAuthentication
+	}
+
+	return name, ok
+}
+
 // DetermineMessageType determines the type of message found in the input buffer and returns
 // the corresponding data structure and the message type as a string.
 func DetermineMessageType(input []byte) (wire.Protocol, string, error) {
 
+	if len(input) == 0 {
+		return nil, "", errors.New("empty message")
+	}
+
 	indicator := string(input[0:1])
 
 	var p wire.Protocol
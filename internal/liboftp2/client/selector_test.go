@@ -0,0 +1,44 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/startfile"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/transfer"
+)
+
+func TestDetermineMessageType(t *testing.T) {
+	p, name, err := client.DetermineMessageType((&transfer.CDT{}).Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := p.(*transfer.CDT); !ok || name != "CDT" {
+		t.Errorf("expected CDT, got %s (%T)", name, p)
+	}
+
+	if _, _, err := client.DetermineMessageType([]byte("X5")); err == nil {
+		t.Errorf("expected error for truncated SSID")
+	}
+
+	if _, _, err := client.DetermineMessageType([]byte("Z")); err == nil {
+		t.Errorf("expected error for unknown command")
+	}
+}
+
+func FuzzDetermineMessageType(f *testing.F) {
+	f.Add((&session.SSID{Id: "O0013000000TEST", BufferSize: 4096, Capability: "B", Credit: 8}).Marshal())
+	f.Add((&session.ESID{ReasonCode: 4}).Marshal())
+	f.Add((&startfile.SFID{DatasetName: "TEST", FileFormat: "U"}).Marshal())
+	f.Add((&startfile.EERP{VirtualDataSetName: "TEST"}).Marshal())
+	f.Add((&transfer.DATA{Buffer: []byte{0x03, 'a', 'b', 'c'}}).Marshal())
+	f.Add((&wire.CD{}).Marshal())
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		_, _, _ = client.DetermineMessageType(input)
+	})
+}
@@ -0,0 +1,146 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+)
+
+// DefaultTimeout limits the time of a single scenario
+const DefaultTimeout = time.Minute
+
+// Status is the outcome of a scenario
+type Status int
+
+const (
+	// Pass means the partner behaved as required
+	Pass Status = iota
+
+	// Fail means the partner deviated from the RFC or the scenario could not
+	// be completed
+	Fail
+
+	// Skip means the scenario does not apply to the partner, e.g. because it
+	// does not support an optional feature
+	Skip
+)
+
+var statusNames = map[Status]string{
+	Pass: "PASS",
+	Fail: "FAIL",
+	Skip: "SKIP",
+}
+
+func (s Status) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("status %d", int(s))
+}
+
+// Target is the partner the scenarios run against
+type Target struct {
+	// Client is the configuration used for the sessions. Each scenario uses a
+	// copy of it, the Fuzzer is set by the scenarios.
+	Client client.OFTP2Client
+
+	// Password sent in the SSID
+	Password string
+}
+
+// Scenario is a single check of the behaviour of the partner
+type Scenario struct {
+	// Section of RFC 5024 the scenario checks, e.g. "5.3.2"
+	Section string
+
+	// Title describes the scenario
+	Title string
+
+	// Run performs the scenario. It returns a note about the outcome, which
+	// is reported together with the status. A *SkipError skips the scenario,
+	// any other error fails it.
+	Run func(ctx context.Context, target *Target) (string, error)
+}
+
+// SkipError is returned by a scenario which does not apply to the partner
+type SkipError struct {
+	Reason string
+}
+
+func (e *SkipError) Error() string {
+	return e.Reason
+}
+
+// skip returns a *SkipError with the formatted reason
+func skip(format string, args ...interface{}) error {
+	return &SkipError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Result is the outcome of a scenario
+type Result struct {
+	Scenario Scenario
+	Status   Status
+	Note     string
+	Duration time.Duration
+}
+
+// Report contains the results of all scenarios in the order they were run
+type Report struct {
+	Results []Result
+}
+
+// Count returns the number of results with the status
+func (r *Report) Count(status Status) int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Passed tells whether no scenario failed
+func (r *Report) Passed() bool {
+	return r.Count(Fail) == 0
+}
+
+// Run runs the scenarios one after another against the target. Each scenario
+// is limited by the timeout, the context stops the remaining ones.
+func Run(ctx context.Context, target *Target, scenarios []Scenario, timeout time.Duration) *Report {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	report := &Report{Results: make([]Result, 0, len(scenarios))}
+
+	for _, scenario := range scenarios {
+		if ctx.Err() != nil {
+			report.Results = append(report.Results, Result{Scenario: scenario, Status: Fail, Note: ctx.Err().Error()})
+			continue
+		}
+
+		scenarioCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		note, err := scenario.Run(scenarioCtx, target)
+		cancel()
+
+		result := Result{Scenario: scenario, Status: Pass, Note: note, Duration: time.Since(start)}
+
+		var skipped *SkipError
+		if errors.As(err, &skipped) {
+			result.Status = Skip
+			result.Note = skipped.Reason
+		} else if err != nil {
+			result.Status = Fail
+			result.Note = err.Error()
+		}
+
+		report.Results = append(report.Results, result)
+	}
+
+	return report
+}
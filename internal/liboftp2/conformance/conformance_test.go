@@ -0,0 +1,79 @@
+package conformance_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/conformance"
+	"github.com/thomsmits/oftp2-client/oftp2/oftp2test"
+)
+
+func TestScenariosAgainstTestPartner(t *testing.T) {
+	partner := &oftp2test.Partner{Restart: true, Compression: true}
+	target := &conformance.Target{Client: *partner.Client("O0013000000CLIENT")}
+
+	report := conformance.Run(context.Background(), target, conformance.Scenarios(), 10*time.Second)
+
+	for _, result := range report.Results {
+		if result.Status != conformance.Pass {
+			t.Errorf("%s %s: %v: %s", result.Scenario.Section, result.Scenario.Title, result.Status, result.Note)
+		}
+	}
+	if !report.Passed() {
+		t.Errorf("report not passed")
+	}
+}
+
+func TestSkippedFeatures(t *testing.T) {
+	partner := &oftp2test.Partner{}
+	target := &conformance.Target{Client: *partner.Client("O0013000000CLIENT")}
+
+	report := conformance.Run(context.Background(), target, conformance.Scenarios(), 10*time.Second)
+
+	// restart and buffer compression are not offered by the partner
+	if report.Count(conformance.Skip) != 2 || !report.Passed() {
+		var b strings.Builder
+		_ = report.WriteText(&b)
+		t.Errorf("unexpected results:\n%s", b.String())
+	}
+}
+
+func TestReport(t *testing.T) {
+	scenarios := []conformance.Scenario{
+		{Section: "5.3.2", Title: "passing", Run: func(ctx context.Context, target *conformance.Target) (string, error) {
+			return "fine", nil
+		}},
+		{Section: "5.3.2", Title: "failing", Run: func(ctx context.Context, target *conformance.Target) (string, error) {
+			return "", errors.New("broken")
+		}},
+		{Section: "5.3.3", Title: "skipped", Run: func(ctx context.Context, target *conformance.Target) (string, error) {
+			return "", &conformance.SkipError{Reason: "not supported"}
+		}},
+	}
+
+	report := conformance.Run(context.Background(), &conformance.Target{}, scenarios, 0)
+	if report.Passed() || report.Count(conformance.Pass) != 1 || report.Count(conformance.Fail) != 1 {
+		t.Errorf("wrong counts")
+	}
+
+	var b strings.Builder
+	if err := report.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	text := b.String()
+	for _, expected := range []string{
+		"FAIL 5.3.2 SSID - Start Session\n",
+		"       PASS passing (0s): fine\n",
+		"       FAIL failing (0s): broken\n",
+		"SKIP 5.3.3 SFID - Start File\n",
+		"1 passed, 1 failed, 1 skipped\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("missing %q in\n%s", expected, text)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+// The conformance package runs a battery of scenarios against an OFTP2
+// partner and reports for each section of RFC 5024 whether the partner
+// behaved as required.
+//
+// Each scenario opens its own session with the partner. The scenarios cover
+// the negotiation of the session parameters including their edge values, the
+// transfer of files in all formats, restart, buffer compression, secure
+// authentication, the change of direction with the exchange of end to end
+// responses and the handling of invalid commands. Deviating commands are
+// produced with the Fuzzer hook of the client.
+//
+// The scenarios send real files to the partner. Files the partner offers
+// while it is the speaker are refused, so they are sent again in a later
+// session.
+package conformance
@@ -0,0 +1,77 @@
+package conformance
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// section groups the results of a section of the RFC
+type section struct {
+	name    string
+	results []Result
+}
+
+// status returns the status of the section: failed if a scenario failed,
+// skipped if all were skipped, otherwise passed
+func (s *section) status() Status {
+	skipped := 0
+	for _, result := range s.results {
+		if result.Status == Fail {
+			return Fail
+		}
+		if result.Status == Skip {
+			skipped++
+		}
+	}
+	if skipped == len(s.results) {
+		return Skip
+	}
+	return Pass
+}
+
+// sections groups the results by section in the order of their first
+// appearance
+func (r *Report) sections() []*section {
+	result := make([]*section, 0)
+	index := make(map[string]*section)
+
+	for _, res := range r.Results {
+		s, ok := index[res.Scenario.Section]
+		if !ok {
+			s = &section{name: res.Scenario.Section}
+			index[s.name] = s
+			result = append(result, s)
+		}
+		s.results = append(s.results, res)
+	}
+
+	return result
+}
+
+// WriteText writes the report grouped by the sections of RFC 5024
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	for _, s := range r.sections() {
+		title := s.name
+		if name, ok := sectionTitles[s.name]; ok {
+			title += " " + name
+		}
+		fmt.Fprintf(&b, "%-4s %s\n", s.status(), title)
+
+		for _, result := range s.results {
+			fmt.Fprintf(&b, "       %-4s %s (%v)", result.Status, result.Scenario.Title, result.Duration.Round(time.Millisecond))
+			if result.Note != "" {
+				fmt.Fprintf(&b, ": %s", result.Note)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	fmt.Fprintf(&b, "\n%d passed, %d failed, %d skipped\n", r.Count(Pass), r.Count(Fail), r.Count(Skip))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,384 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/startfile"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/transfer"
+)
+
+// Limits of the session parameters, see RFC 5024 5.3.2
+const (
+	minBufferSize = 128
+	maxBufferSize = 99999
+	maxCredit     = 999
+)
+
+// Buffer size and credit the client offers in its SSID
+const (
+	clientBufferSize = 1024
+	clientCredit     = 999
+)
+
+// ESID reason codes expected from the partner
+const (
+	esidReasonCommandNotRecognised = 1
+	esidReasonProtocolViolation    = 2
+)
+
+// Offsets of the fields of an SSID in the buffer seen by the Fuzzer, which
+// starts with the stream transmission header
+const (
+	ssidBufferSizeOffset = wire.StreamHeaderLength + 1 + 1 + 25 + 8
+	ssidCreditOffset     = ssidBufferSizeOffset + 5 + 4
+)
+
+// Scenarios returns the complete battery of scenarios
+func Scenarios() []Scenario {
+	return []Scenario{
+		{"5.3.2", "capabilities", capabilities},
+		{"5.3.2", "default session parameters", defaultParameters},
+		{"5.3.2", "minimum buffer size and credit", limits(minBufferSize, 1, 2000)},
+		{"5.3.2", "maximum buffer size and credit", limits(maxBufferSize, maxCredit, 300*1024)},
+		{"5.3.2", "buffer size below minimum", bufferSizeBelowMinimum},
+		{"5.3.2", "restart", restart},
+		{"5.3.2", "buffer compression", compression},
+		{"5.3.2", "secure authentication", secureAuthentication},
+		{"5.3.3", "text file (T)", fileFormat(client.FileFormatText, 0)},
+		{"5.3.3", "unstructured file (U)", fileFormat(client.FileFormatUnstructured, 0)},
+		{"5.3.3", "fixed record file (F)", fileFormat(client.FileFormatFixedBinary, 80)},
+		{"5.3.3", "variable record file (V)", fileFormat(client.FileFormatVariable, 80)},
+		{"5.3.7", "credit window of one buffer", limits(minBufferSize, 1, 5000)},
+		{"5.3.11", "unknown command", invalidCommand([]byte("Z"), esidReasonCommandNotRecognised)},
+		{"5.3.11", "command out of sequence", invalidCommand(outOfSequence(), esidReasonProtocolViolation)},
+		{"5.3.12", "change direction and end to end responses", changeDirection},
+	}
+}
+
+// sectionTitles names the sections of RFC 5024 used by the scenarios
+var sectionTitles = map[string]string{
+	"5.3.2":  "SSID - Start Session",
+	"5.3.3":  "SFID - Start File",
+	"5.3.7":  "CDT - Set Credit",
+	"5.3.11": "ESID - End Session",
+	"5.3.12": "CD - Change Direction",
+}
+
+// sessionOptions are the flags of the SSID sent by the client
+type sessionOptions struct {
+	compression    bool
+	restart        bool
+	authentication bool
+}
+
+// open starts a session with the partner, using the fuzzer if it is not nil
+func open(ctx context.Context, target *Target, options sessionOptions, fuzzer func([]byte) []byte) (*client.Session, error) {
+	c := target.Client
+	c.Fuzzer = fuzzer
+	return c.Open(ctx, target.Password, options.compression, options.restart, options.authentication)
+}
+
+// sendTestFile sends a file with the given size to the partner
+func sendTestFile(ctx context.Context, s *client.Session, name string, format client.OFTP2FileFormat, recordSize int, size int) error {
+	data := testData(size, recordSize)
+	file := client.VirtualFile{
+		DatasetName:   name,
+		Destination:   s.PartnerId(),
+		Format:        format,
+		MaxRecordSize: recordSize,
+	}
+	return s.SendStream(ctx, file, bytes.NewReader(data), int64(len(data)))
+}
+
+// testData creates printable test data, with a line break at the end of each
+// record if a record size is given
+func testData(size int, recordSize int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte('A' + i%26)
+		if recordSize > 0 && i%recordSize == recordSize-1 {
+			data[i] = '\n'
+		}
+	}
+	return data
+}
+
+// checkParameters checks the parameters the partner answered against the ones
+// offered by the client
+func checkParameters(p client.Parameters, bufferSize, credit uint32) error {
+	if p.BufferSize < minBufferSize || p.BufferSize > bufferSize {
+		return errors.New(fmt.Sprintf("buffer size %d not between %d and the offered %d", p.BufferSize, minBufferSize, bufferSize))
+	}
+	if p.Credit < 1 || p.Credit > credit {
+		return errors.New(fmt.Sprintf("credit %d not between 1 and the offered %d", p.Credit, credit))
+	}
+	if p.Capability != "R" && p.Capability != "B" {
+		return errors.New(fmt.Sprintf("capability %q does not allow to receive files from a sender", p.Capability))
+	}
+	return nil
+}
+
+// sessionParameters patches the buffer size and credit of the SSID sent by the
+// client
+func sessionParameters(bufferSize, credit int) func([]byte) []byte {
+	return func(data []byte) []byte {
+		if len(data) < ssidCreditOffset+3 || string(data[wire.StreamHeaderLength:wire.StreamHeaderLength+1]) != session.SSIDCMD {
+			return data
+		}
+
+		patched := append([]byte(nil), data...)
+		copy(patched[ssidBufferSizeOffset:], fmt.Sprintf("%05d", bufferSize))
+		copy(patched[ssidCreditOffset:], fmt.Sprintf("%03d", credit))
+		return patched
+	}
+}
+
+// replaceFirst replaces the first buffer with the given command by another
+// one
+func replaceFirst(command string, replacement []byte) func([]byte) []byte {
+	replaced := false
+	return func(data []byte) []byte {
+		if replaced || len(data) <= wire.StreamHeaderLength || string(data[wire.StreamHeaderLength:wire.StreamHeaderLength+1]) != command {
+			return data
+		}
+
+		replaced = true
+		return wire.EncodeStreamBuffer(replacement)
+	}
+}
+
+// outOfSequence returns a DATA buffer, which is not allowed before a file was
+// started
+func outOfSequence() []byte {
+	data := transfer.DATA{Buffer: []byte{0x84, 'T', 'E', 'S', 'T'}}
+	return data.Marshal()
+}
+
+// end ends the session and reports an error of the partner
+func end(ctx context.Context, s *client.Session) error {
+	err := s.End(ctx)
+	if err != nil {
+		return errors.New(fmt.Sprintf("ending the session failed: %v", err))
+	}
+	return nil
+}
+
+// capabilities queries the capabilities of the partner
+func capabilities(ctx context.Context, target *Target) (string, error) {
+	ssid, err := target.Client.QueryServerCapabilitiesContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("partner %s, buffer size %d, credit %d, capability %s, compression %v, restart %v, secure authentication %v",
+		ssid.Id, ssid.BufferSize, ssid.Credit, ssid.Capability, ssid.Compress, ssid.Restart, ssid.Authentication), nil
+}
+
+// defaultParameters starts a session with the parameters used by the client
+func defaultParameters(ctx context.Context, target *Target) (string, error) {
+	s, err := open(ctx, target, sessionOptions{}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+
+	p := s.Parameters()
+	if err = checkParameters(p, clientBufferSize, clientCredit); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("buffer size %d, credit %d", p.BufferSize, p.Credit), end(ctx, s)
+}
+
+// limits offers the buffer size and credit and sends a file of the given size
+// with the negotiated values
+func limits(bufferSize, credit, size int) func(ctx context.Context, target *Target) (string, error) {
+	return func(ctx context.Context, target *Target) (string, error) {
+		s, err := open(ctx, target, sessionOptions{}, sessionParameters(bufferSize, credit))
+		if err != nil {
+			return "", err
+		}
+		defer s.Close()
+
+		p := s.Parameters()
+		if err = checkParameters(p, uint32(bufferSize), uint32(credit)); err != nil {
+			return "", err
+		}
+
+		name := fmt.Sprintf("CONFORMANCE.B%d.C%d", bufferSize, credit)
+		if err = sendTestFile(ctx, s, name, client.FileFormatUnstructured, 0, size); err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("negotiated buffer size %d, credit %d, sent %d bytes", p.BufferSize, p.Credit, size), end(ctx, s)
+	}
+}
+
+// bufferSizeBelowMinimum offers a buffer size the partner has to refuse
+func bufferSizeBelowMinimum(ctx context.Context, target *Target) (string, error) {
+	s, err := open(ctx, target, sessionOptions{}, sessionParameters(minBufferSize-1, clientCredit))
+
+	var ended *client.SessionEndedError
+	if errors.As(err, &ended) {
+		return fmt.Sprintf("refused with ESID reason %02d", ended.ReasonCode), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_ = s.End(ctx)
+	return "", errors.New(fmt.Sprintf("partner accepted buffer size %d", minBufferSize-1))
+}
+
+// restart requests the restart capability and sends a file
+func restart(ctx context.Context, target *Target) (string, error) {
+	s, err := open(ctx, target, sessionOptions{restart: true}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+
+	if !s.Parameters().Restart {
+		_ = s.End(ctx)
+		return "", skip("partner does not support restart")
+	}
+
+	// the partner must not ask for a restart position beyond the offered one
+	if err = sendTestFile(ctx, s, "CONFORMANCE.RESTART", client.FileFormatUnstructured, 0, 4096); err != nil {
+		return "", err
+	}
+
+	return "restart agreed, file sent from position 0", end(ctx, s)
+}
+
+// compression requests buffer compression and sends a file
+func compression(ctx context.Context, target *Target) (string, error) {
+	s, err := open(ctx, target, sessionOptions{compression: true}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+
+	if !s.Parameters().Compression {
+		_ = s.End(ctx)
+		return "", skip("partner does not support buffer compression")
+	}
+
+	if err = sendTestFile(ctx, s, "CONFORMANCE.COMPRESSION", client.FileFormatUnstructured, 0, 4096); err != nil {
+		return "", err
+	}
+
+	return "compression agreed, file sent", end(ctx, s)
+}
+
+// secureAuthentication requests secure authentication. The partner has to
+// answer with the same flag or refuse the session; the authentication itself
+// needs certificates and is not exercised.
+func secureAuthentication(ctx context.Context, target *Target) (string, error) {
+	c := target.Client
+	s, err := c.Dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+
+	err = s.Start(ctx, target.Password, false, false, true)
+
+	var ended *client.SessionEndedError
+	switch {
+	case errors.As(err, &ended):
+		return fmt.Sprintf("not supported, refused with ESID reason %02d", ended.ReasonCode), nil
+	case errors.Is(err, client.ErrAuthenticationMismatch):
+		return "not supported, answered without secure authentication", nil
+	case err != nil:
+		return "", err
+	}
+
+	_ = s.End(ctx)
+	return "supported, authentication not exercised", nil
+}
+
+// fileFormat sends a file of the format
+func fileFormat(format client.OFTP2FileFormat, recordSize int) func(ctx context.Context, target *Target) (string, error) {
+	return func(ctx context.Context, target *Target) (string, error) {
+		s, err := open(ctx, target, sessionOptions{}, nil)
+		if err != nil {
+			return "", err
+		}
+		defer s.Close()
+
+		name := fmt.Sprintf("CONFORMANCE.FORMAT.%s", format)
+		if err = sendTestFile(ctx, s, name, format, recordSize, 10*recordSize+100); err != nil {
+			return "", err
+		}
+
+		return "file accepted", end(ctx, s)
+	}
+}
+
+// refusingInbox refuses all files offered by the partner
+type refusingInbox struct {
+	offered int
+}
+
+func (i *refusingInbox) Create(file client.VirtualFile) (client.InboxFile, error) {
+	i.offered++
+	return nil, &client.FileRefusal{ReasonCode: 99, ReasonText: "conformance test, send again later"}
+}
+
+// changeDirection sends a file and hands over the right to speak, so the
+// partner can send end to end responses
+func changeDirection(ctx context.Context, target *Target) (string, error) {
+	s, err := open(ctx, target, sessionOptions{}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+
+	if err = sendTestFile(ctx, s, "CONFORMANCE.CD", client.FileFormatUnstructured, 0, 100); err != nil {
+		return "", err
+	}
+
+	inbox := &refusingInbox{}
+	result, err := s.ReceiveFiles(ctx, inbox)
+	if err != nil {
+		return "", err
+	}
+
+	note := fmt.Sprintf("%d end to end responses received, %d files offered and refused", len(result.Receipts), inbox.offered)
+	if result.SessionEnded {
+		return note + ", session ended by partner", nil
+	}
+
+	return note + ", right to speak handed back", end(ctx, s)
+}
+
+// invalidCommand sends the buffer instead of a start file command and expects
+// the partner to end the session with the ESID reason
+func invalidCommand(buffer []byte, reasonCode int) func(ctx context.Context, target *Target) (string, error) {
+	return func(ctx context.Context, target *Target) (string, error) {
+		s, err := open(ctx, target, sessionOptions{}, replaceFirst(startfile.SFIDCMD, buffer))
+		if err != nil {
+			return "", err
+		}
+		defer s.Close()
+
+		err = sendTestFile(ctx, s, "CONFORMANCE.INVALID", client.FileFormatUnstructured, 0, 10)
+
+		var ended *client.SessionEndedError
+		if !errors.As(err, &ended) {
+			return "", errors.New(fmt.Sprintf("expected ESID reason %02d, got %v", reasonCode, err))
+		}
+		if ended.ReasonCode != reasonCode {
+			return "", errors.New(fmt.Sprintf("expected ESID reason %02d, got %02d (%s)", reasonCode, ended.ReasonCode, ended.Text))
+		}
+
+		return fmt.Sprintf("ended with ESID reason %02d", ended.ReasonCode), nil
+	}
+}
@@ -0,0 +1,12 @@
+// The fuzz package sends deliberately malformed SSID, SFID and DATA commands
+// to an OFTP2 partner and records how the partner reacts.
+//
+// The mutations are derived from the format definitions of the commands: a
+// field is made one octet longer or shorter than its format allows, filled
+// with characters outside of its character set, or, for DATA, the sub-record
+// headers are broken. Each mutation is sent in its own session with the
+// Fuzzer hook of the client. A conforming partner refuses the command with an
+// ESID, SFNA or EFNA. The inputs after which the partner dropped the
+// connection without an answer are kept in the report, so they can be
+// replayed and analysed.
+package fuzz
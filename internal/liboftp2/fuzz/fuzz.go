@@ -0,0 +1,236 @@
+package fuzz
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+)
+
+// DefaultTimeout limits the time of the session of a single mutation
+const DefaultTimeout = 30 * time.Second
+
+// testFileSize is the size of the file sent to deliver mutated SFID and DATA
+// commands. It fills several buffers even with the maximum buffer size.
+const testFileSize = 128 * 1024
+
+// Outcome is the reaction of the partner to a mutation
+type Outcome int
+
+const (
+	// Accepted means the partner carried on as if the command was valid
+	Accepted Outcome = iota
+
+	// Rejected means the partner refused the command with an ESID, SFNA or
+	// EFNA
+	Rejected
+
+	// Dropped means the partner closed the connection without an answer
+	Dropped
+
+	// Unanswered means the partner did not answer within the timeout
+	Unanswered
+
+	// Failed means the mutation could not be sent or the partner answered
+	// with an unexpected command
+	Failed
+)
+
+var outcomeNames = map[Outcome]string{
+	Accepted:   "ACCEPTED",
+	Rejected:   "REJECTED",
+	Dropped:    "DROPPED",
+	Unanswered: "UNANSWERED",
+	Failed:     "FAILED",
+}
+
+func (o Outcome) String() string {
+	if name, ok := outcomeNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("outcome %d", int(o))
+}
+
+// Target is the partner the mutations are sent to
+type Target struct {
+	// Client is the configuration used for the sessions. Each mutation uses a
+	// copy of it with its own Fuzzer.
+	Client client.OFTP2Client
+
+	// Password sent in the SSID
+	Password string
+}
+
+// Result is the reaction of the partner to a mutation
+type Result struct {
+	Mutation Mutation
+	Outcome  Outcome
+
+	// Note describes the answer of the partner or the error
+	Note string
+
+	// Input is the mutated exchange buffer as it was sent, without the stream
+	// transmission header. It is nil if the mutation was not sent.
+	Input []byte
+
+	Duration time.Duration
+}
+
+// Report contains the results of all mutations in the order they were sent
+type Report struct {
+	Results []Result
+}
+
+// Count returns the number of results with the outcome
+func (r *Report) Count(outcome Outcome) int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Outcome == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+// Dropped returns the results of the mutations after which the partner
+// dropped the connection
+func (r *Report) Dropped() []Result {
+	result := make([]Result, 0)
+	for _, res := range r.Results {
+		if res.Outcome == Dropped {
+			result = append(result, res)
+		}
+	}
+	return result
+}
+
+// Run sends the mutations one after another to the target, each in its own
+// session limited by the timeout. The context stops the remaining ones.
+func Run(ctx context.Context, target *Target, mutations []Mutation, timeout time.Duration) *Report {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	report := &Report{Results: make([]Result, 0, len(mutations))}
+
+	for i, mutation := range mutations {
+		if ctx.Err() != nil {
+			report.Results = append(report.Results, Result{Mutation: mutation, Outcome: Failed, Note: ctx.Err().Error()})
+			continue
+		}
+
+		mutationCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		result := run(mutationCtx, target, mutation, fmt.Sprintf("FUZZ.%d", i+1))
+		cancel()
+
+		result.Duration = time.Since(start)
+		report.Results = append(report.Results, result)
+	}
+
+	return report
+}
+
+// run sends a single mutation. SSID mutations are sent when the session is
+// opened, SFID and DATA mutations with a test file.
+func run(ctx context.Context, target *Target, mutation Mutation, name string) Result {
+	result := Result{Mutation: mutation}
+	var applyErr error
+
+	c := target.Client
+	c.Fuzzer = fuzzer(mutation, &result.Input, &applyErr)
+
+	s, err := c.Open(ctx, target.Password, false, false, false)
+	if err == nil {
+		if mutation.Command != "SSID" {
+			data := bytes.Repeat([]byte("FUZZ"), testFileSize/4)
+			file := client.VirtualFile{
+				DatasetName: name,
+				Destination: s.PartnerId(),
+				Format:      client.FileFormatUnstructured,
+			}
+			err = s.SendStream(ctx, file, bytes.NewReader(data), int64(len(data)))
+		}
+
+		if err == nil {
+			err = s.End(ctx)
+		}
+		s.Close()
+	}
+
+	if applyErr != nil {
+		result.Outcome = Failed
+		result.Note = fmt.Sprintf("mutation not applicable: %v", applyErr)
+		return result
+	}
+	if result.Input == nil {
+		result.Outcome = Failed
+		if err != nil {
+			result.Note = fmt.Sprintf("mutation not sent: %v", err)
+		} else {
+			result.Note = "mutation not sent"
+		}
+		return result
+	}
+
+	result.Outcome = classify(err)
+	if err != nil {
+		result.Note = err.Error()
+	}
+	return result
+}
+
+// fuzzer returns a Fuzzer, which applies the mutation to the first buffer of
+// the mutated command and keeps the mutated buffer in input
+func fuzzer(mutation Mutation, input *[]byte, applyErr *error) func([]byte) []byte {
+	indicator := mutation.indicator()
+	applied := false
+
+	return func(data []byte) []byte {
+		if applied || len(data) <= wire.StreamHeaderLength || string(data[wire.StreamHeaderLength:wire.StreamHeaderLength+1]) != indicator {
+			return data
+		}
+		applied = true
+
+		mutated, err := mutation.Apply(data[wire.StreamHeaderLength:])
+		if err != nil {
+			*applyErr = err
+			return data
+		}
+
+		*input = mutated
+		return wire.EncodeStreamBuffer(mutated)
+	}
+}
+
+// classify determines the outcome from the error of the session
+func classify(err error) Outcome {
+	if err == nil {
+		return Accepted
+	}
+
+	var ended *client.SessionEndedError
+	var startRejected *client.StartFileRejectedError
+	var endRejected *client.EndFileRejectedError
+	if errors.As(err, &ended) || errors.As(err, &startRejected) || errors.As(err, &endRejected) {
+		return Rejected
+	}
+
+	if errors.Is(err, client.ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return Unanswered
+	}
+
+	var netErr net.Error
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, client.ErrNotConnected) || errors.As(err, &netErr) {
+		return Dropped
+	}
+
+	return Failed
+}
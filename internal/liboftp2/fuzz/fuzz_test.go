@@ -0,0 +1,157 @@
+package fuzz_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/fuzz"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+	"github.com/thomsmits/oftp2-client/oftp2/oftp2test"
+)
+
+func TestApply(t *testing.T) {
+	ssid := session.SSID{Id: "O0013000000CLIENT", Password: "SECRET", BufferSize: 4096, Capability: "B", Credit: 99}
+	buffer := ssid.Marshal()
+
+	tests := []struct {
+		mutation fuzz.Mutation
+		expected []byte
+	}{
+		{
+			fuzz.Mutation{Command: "SSID", Field: "SSIDLEV", Kind: fuzz.Overlong},
+			append([]byte("X50"), buffer[2:]...),
+		},
+		{
+			fuzz.Mutation{Command: "SSID", Field: "SSIDSDEB", Kind: fuzz.IllegalCharset},
+			append(append(append([]byte{}, buffer[:35]...), "AAAAA"...), buffer[40:]...),
+		},
+		{
+			fuzz.Mutation{Command: "SSID", Field: "SSIDCODE", Kind: fuzz.WrongLength},
+			append(append([]byte{}, buffer[:26]...), buffer[27:]...),
+		},
+	}
+
+	for _, test := range tests {
+		mutated, err := test.mutation.Apply(buffer)
+		if err != nil {
+			t.Errorf("%v: %v", test.mutation, err)
+			continue
+		}
+		if !bytes.Equal(mutated, test.expected) {
+			t.Errorf("%v: expected %q, got %q", test.mutation, test.expected, mutated)
+		}
+	}
+
+	if _, err := (fuzz.Mutation{Command: "SSID", Field: "SFIDDSN", Kind: fuzz.Overlong}).Apply(buffer); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+}
+
+func TestBrokenSubRecord(t *testing.T) {
+	payload := append([]byte{0x3f}, bytes.Repeat([]byte("A"), 63)...)
+	data := append([]byte("D"), payload...)
+
+	mutated, err := fuzz.Mutation{Command: "DATA", Field: "DATABUF", Kind: fuzz.BrokenSubRecord}.Apply(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mutated) != 1+1+16 || mutated[1] != 0x3f {
+		t.Errorf("unexpected buffer %q", mutated)
+	}
+}
+
+func TestMutations(t *testing.T) {
+	counts := make(map[string]int)
+	for _, m := range fuzz.Mutations() {
+		counts[m.Command]++
+		if m.Field == "SSIDCMD" || m.Field == "SFIDCMD" || m.Field == "DATACMD" {
+			t.Errorf("command indicator mutated: %v", m)
+		}
+	}
+
+	// 13 fields with three mutations each, the binary DATA buffer has no
+	// charset but a broken sub-record
+	if counts["SSID"] != 39 || counts["DATA"] != 3 || counts["SFID"] == 0 {
+		t.Errorf("unexpected mutations %v", counts)
+	}
+}
+
+func TestRunAgainstTestPartner(t *testing.T) {
+	partner := &oftp2test.Partner{}
+	target := &fuzz.Target{Client: *partner.Client("O0013000000CLIENT")}
+
+	mutations := []fuzz.Mutation{
+		{Command: "SSID", Field: "SSIDSDEB", Kind: fuzz.IllegalCharset},
+		{Command: "SSID", Field: "SSIDUSER", Kind: fuzz.IllegalCharset},
+		{Command: "DATA", Field: "DATABUF", Kind: fuzz.BrokenSubRecord},
+	}
+
+	report := fuzz.Run(context.Background(), target, mutations, 10*time.Second)
+
+	expected := []fuzz.Outcome{fuzz.Rejected, fuzz.Accepted, fuzz.Rejected}
+	for i, result := range report.Results {
+		if result.Outcome != expected[i] {
+			t.Errorf("%v: expected %v, got %v: %s", result.Mutation, expected[i], result.Outcome, result.Note)
+		}
+		if result.Input == nil {
+			t.Errorf("%v: input not recorded", result.Mutation)
+		}
+	}
+}
+
+// droppingConn closes the connection when the client sends a buffer
+// containing lower case letters, like a partner that crashes on them
+type droppingConn struct {
+	net.Conn
+}
+
+func (c *droppingConn) Write(b []byte) (int, error) {
+	if len(b) > wire.StreamHeaderLength && bytes.ContainsRune(b[wire.StreamHeaderLength:], 'a') {
+		_ = c.Conn.Close()
+	}
+	return c.Conn.Write(b)
+}
+
+func TestDroppedConnection(t *testing.T) {
+	partner := &oftp2test.Partner{}
+	c := *partner.Client("O0013000000CLIENT")
+
+	dial := c.Dialer
+	c.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &droppingConn{conn}, nil
+	}
+
+	mutation := fuzz.Mutation{Command: "SSID", Field: "SSIDUSER", Kind: fuzz.IllegalCharset}
+	report := fuzz.Run(context.Background(), &fuzz.Target{Client: c}, []fuzz.Mutation{mutation}, 10*time.Second)
+
+	dropped := report.Dropped()
+	if len(dropped) != 1 || !strings.Contains(string(dropped[0].Input), "aaaaaaaa") {
+		t.Fatalf("expected dropped connection, got %v", report.Results)
+	}
+
+	var b strings.Builder
+	if err := report.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	text := b.String()
+	for _, expected := range []string{
+		"DROPPED    SSID SSIDUSER illegal charset",
+		"Inputs that made the partner drop the connection:\n  SSID SSIDUSER illegal charset: \"X5",
+		"0 accepted, 0 rejected, 1 dropped, 0 unanswered, 0 failed\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("missing %q in\n%s", expected, text)
+		}
+	}
+}
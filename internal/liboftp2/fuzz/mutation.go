@@ -0,0 +1,226 @@
+package fuzz
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/startfile"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/transfer"
+)
+
+// Kind is the way a field is mutated
+type Kind int
+
+const (
+	// Overlong appends an octet to the field, so that it is longer than its
+	// format allows. All following fields are shifted.
+	Overlong Kind = iota
+
+	// IllegalCharset fills the field with characters outside of its
+	// character set, e.g. lower case letters in an alphanumeric field
+	IllegalCharset
+
+	// WrongLength removes the last octet of the field, so that it is shorter
+	// than its format requires. All following fields are shifted.
+	WrongLength
+
+	// BrokenSubRecord replaces the sub-records of a DATA buffer by a header
+	// announcing more octets than the buffer contains
+	BrokenSubRecord
+)
+
+var kindNames = map[Kind]string{
+	Overlong:        "overlong",
+	IllegalCharset:  "illegal charset",
+	WrongLength:     "wrong length",
+	BrokenSubRecord: "broken sub-record header",
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("kind %d", int(k))
+}
+
+// command describes a command the mutations are applied to
+type command struct {
+	name        string
+	indicator   string
+	definitions []wire.FormatDefinition
+}
+
+// commands are the commands that are mutated, in the order they are sent
+var commands = []command{
+	{"SSID", session.SSIDCMD, (&session.SSID{}).FormatDefinition()},
+	{"SFID", startfile.SFIDCMD, (&startfile.SFID{}).FormatDefinition()},
+	{"DATA", transfer.DATACMD, (&transfer.DATA{}).FormatDefinition()},
+}
+
+// Mutation is a single modification of a field of a command
+type Mutation struct {
+	// Command is the name of the mutated command, e.g. SSID
+	Command string
+
+	// Field is the name of the mutated field as given in the specification,
+	// e.g. SSIDCODE
+	Field string
+
+	// Kind of the mutation
+	Kind Kind
+}
+
+func (m Mutation) String() string {
+	return fmt.Sprintf("%s %s %v", m.Command, m.Field, m.Kind)
+}
+
+// Mutations returns all mutations of the fields of SSID, SFID and DATA. The
+// command indicator itself is never mutated, the partner would not recognise
+// the command at all.
+func Mutations() []Mutation {
+	result := make([]Mutation, 0)
+
+	for _, c := range commands {
+		for _, definition := range c.definitions[1:] {
+			field := strings.TrimSpace(definition.FieldName)
+			format := definition.ToDataFormat()
+
+			result = append(result, Mutation{c.name, field, Overlong})
+			if format.DataType != wire.DataTypeBinary {
+				result = append(result, Mutation{c.name, field, IllegalCharset})
+			}
+			result = append(result, Mutation{c.name, field, WrongLength})
+
+			if c.indicator == transfer.DATACMD {
+				result = append(result, Mutation{c.name, field, BrokenSubRecord})
+			}
+		}
+	}
+
+	return result
+}
+
+// indicator returns the command indicator of the mutated command
+func (m Mutation) indicator() string {
+	if c, ok := findCommand(m.Command); ok {
+		return c.indicator
+	}
+	return ""
+}
+
+// findCommand looks up the command with the given name
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// Apply applies the mutation to the exchange buffer (without the stream
+// transmission header) and returns the mutated copy
+func (m Mutation) Apply(buffer []byte) ([]byte, error) {
+	c, ok := findCommand(m.Command)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown command %s", m.Command))
+	}
+
+	fields, err := wire.DecodeFields(c.definitions, buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := 0
+	for _, field := range fields {
+		if field.Name == m.Field {
+			return m.apply(buffer, offset, field)
+		}
+		offset += len(field.Raw)
+	}
+
+	return nil, errors.New(fmt.Sprintf("field %s not found in %s", m.Field, m.Command))
+}
+
+// apply mutates the field found at offset in the buffer
+func (m Mutation) apply(buffer []byte, offset int, field wire.Field) ([]byte, error) {
+	end := offset + len(field.Raw)
+	result := make([]byte, 0, len(buffer)+1)
+	result = append(result, buffer[:offset]...)
+
+	switch m.Kind {
+	case Overlong:
+		result = append(result, field.Raw...)
+		result = append(result, filler(field.Format.DataType))
+	case IllegalCharset:
+		if len(field.Raw) == 0 {
+			return nil, errors.New(fmt.Sprintf("field %s is empty", m.Field))
+		}
+		result = append(result, illegal(field.Format.DataType, len(field.Raw))...)
+	case WrongLength:
+		if len(field.Raw) == 0 {
+			return nil, errors.New(fmt.Sprintf("field %s is empty", m.Field))
+		}
+		result = append(result, field.Raw[:len(field.Raw)-1]...)
+	case BrokenSubRecord:
+		result = append(result, brokenSubRecord(field.Raw)...)
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown mutation %v", m.Kind))
+	}
+
+	return append(result, buffer[end:]...), nil
+}
+
+// filler returns an octet that is valid for the data type
+func filler(dataType wire.DataTypes) byte {
+	switch dataType {
+	case wire.DataTypeNumeric:
+		return '0'
+	case wire.DataTypeBinary:
+		return 0x00
+	default:
+		return 'X'
+	}
+}
+
+// illegal returns length octets outside of the character set of the data
+// type: lower case letters for alphanumeric fields, letters for numeric
+// fields and invalid UTF-8 for text
+func illegal(dataType wire.DataTypes, length int) []byte {
+	var b byte
+	switch dataType {
+	case wire.DataTypeNumeric:
+		b = 'A'
+	case wire.DataTypeUTF8:
+		b = 0xff
+	default:
+		b = 'a'
+	}
+
+	result := make([]byte, length)
+	for i := range result {
+		result[i] = b
+	}
+	return result
+}
+
+// brokenSubRecordLength is the number of data octets kept after the broken
+// sub-record header
+const brokenSubRecordLength = 16
+
+// brokenSubRecord returns a payload starting with a sub-record header of the
+// maximum count of 63 octets, followed by fewer data octets
+func brokenSubRecord(payload []byte) []byte {
+	data := payload
+	if len(data) > 1 {
+		data = data[1:]
+	}
+	if len(data) > brokenSubRecordLength {
+		data = data[:brokenSubRecordLength]
+	}
+
+	return append([]byte{0x3f}, data...)
+}
@@ -0,0 +1,36 @@
+package fuzz
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteText writes a line per mutation and the inputs after which the partner
+// dropped the connection
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	for _, result := range r.Results {
+		fmt.Fprintf(&b, "%-10s %v (%v)", result.Outcome, result.Mutation, result.Duration.Round(time.Millisecond))
+		if result.Note != "" {
+			fmt.Fprintf(&b, ": %s", result.Note)
+		}
+		b.WriteString("\n")
+	}
+
+	dropped := r.Dropped()
+	if len(dropped) > 0 {
+		b.WriteString("\nInputs that made the partner drop the connection:\n")
+		for _, result := range dropped {
+			fmt.Fprintf(&b, "  %v: %q\n", result.Mutation, result.Input)
+		}
+	}
+
+	fmt.Fprintf(&b, "\n%d accepted, %d rejected, %d dropped, %d unanswered, %d failed\n",
+		r.Count(Accepted), r.Count(Rejected), r.Count(Dropped), r.Count(Unanswered), r.Count(Failed))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,12 @@
+// The logging package defines the Logger interface used by the library to
+// report what it is doing, together with simple text and JSON implementations.
+//
+// Levels and the key/value style of the interface follow the log/slog package,
+// so that an application using slog can pass a thin adapter to the library.
+// Messages carry their context as alternating keys and values, e.g.
+//
+//	logger.Info("file sent", logging.KeyDataset, "DELFOR01", "bytes", 4711)
+//
+// The keys commonly used by the library are defined as constants (KeyPartner,
+// KeySession, ...).
+package logging
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Level of a log message. The values are identical to the ones of log/slog.
+type Level int
+
+const (
+	// LevelDebug is used for protocol details, e.g. every exchange buffer
+	LevelDebug Level = -4
+
+	// LevelInfo is used for the regular progress of sessions and transfers
+	LevelInfo Level = 0
+
+	// LevelWarn is used for problems reported by the partner
+	LevelWarn Level = 4
+
+	// LevelError is used for failed sessions and transfers
+	LevelError Level = 8
+)
+
+// Keys used by the library for the context of log messages
+const (
+	// KeyPartner is the Odette ID (or address) of the communication partner
+	KeyPartner = "partner"
+
+	// KeySession is an identifier of the session, unique within the process
+	KeySession = "session"
+
+	// KeyDataset is the dataset name of a virtual file
+	KeyDataset = "dataset"
+
+	// KeyCommand is the OFTP2 command, e.g. SFID
+	KeyCommand = "command"
+)
+
+func (l Level) String() string {
+	switch {
+	case l < LevelInfo:
+		return "DEBUG"
+	case l < LevelWarn:
+		return "INFO"
+	case l < LevelError:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// ParseLevel converts the name of a level (debug, info, warn, error) into the
+// level.
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, errors.New(fmt.Sprintf("unknown log level %s", name))
+	}
+}
+
+// Logger receives the log messages of the library. Each method takes a message
+// and alternating keys and values describing the context of the message.
+// Implementations must be safe for concurrent use.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+
+	// With returns a logger that adds the given keys and values to every
+	// message.
+	With(keysAndValues ...interface{}) Logger
+}
+
+// Discard is a logger that drops all messages.
+var Discard Logger = discard{}
+
+type discard struct{}
+
+func (d discard) Debug(msg string, keysAndValues ...interface{}) {}
+func (d discard) Info(msg string, keysAndValues ...interface{})  {}
+func (d discard) Warn(msg string, keysAndValues ...interface{})  {}
+func (d discard) Error(msg string, keysAndValues ...interface{}) {}
+
+func (d discard) With(keysAndValues ...interface{}) Logger {
+	return d
+}
@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	for name, expected := range map[string]Level{"debug": LevelDebug, "INFO": LevelInfo, "warn": LevelWarn, "error": LevelError} {
+		l, err := ParseLevel(name)
+		if err != nil {
+			t.Error(err)
+		}
+		if l != expected {
+			t.Errorf("expected %s, got %s", expected, l)
+		}
+	}
+
+	_, err := ParseLevel("verbose")
+	if err == nil {
+		t.Errorf("expected error for unknown level")
+	}
+}
+
+func TestTextLogger(t *testing.T) {
+	var out bytes.Buffer
+
+	l := NewTextLogger(&out, LevelInfo).With(KeyPartner, "O0013000000SERVER")
+	l.Debug("not shown")
+	l.Info("file sent", KeyDataset, "DELFOR01", "bytes", 4711)
+
+	line := out.String()
+
+	if strings.Contains(line, "not shown") {
+		t.Errorf("debug message written at level info: %s", line)
+	}
+
+	for _, e := range []string{"level=INFO", `msg="file sent"`, "partner=O0013000000SERVER", "dataset=DELFOR01", "bytes=4711"} {
+		if !strings.Contains(line, e) {
+			t.Errorf("expected %s in %s", e, line)
+		}
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	var out bytes.Buffer
+
+	l := NewJSONLogger(&out, LevelDebug).With(KeySession, "1")
+	l.Warn("rejected", "error", errors.New("duplicate file"), "dangling")
+
+	entry := map[string]interface{}{}
+	err := json.Unmarshal(out.Bytes(), &entry)
+	if err != nil {
+		t.Fatalf("invalid JSON %s: %v", out.String(), err)
+	}
+
+	expected := map[string]interface{}{
+		"level":    "WARN",
+		"msg":      "rejected",
+		"session":  "1",
+		"error":    "duplicate file",
+		"dangling": "!MISSING",
+	}
+
+	for k, v := range expected {
+		if entry[k] != v {
+			t.Errorf("expected %s=%v, got %v", k, v, entry[k])
+		}
+	}
+}
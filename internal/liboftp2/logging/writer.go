@@ -0,0 +1,198 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Format of the log output
+type Format int
+
+const (
+	// FormatText writes key=value pairs, one message per line
+	FormatText Format = iota
+
+	// FormatJSON writes one JSON object per line
+	FormatJSON
+)
+
+// writerLogger writes messages of at least the configured level to a writer.
+type writerLogger struct {
+	output *output
+	level  Level
+	fields []interface{}
+}
+
+// output is shared by all loggers derived via With from the same logger
+type output struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	format Format
+}
+
+// NewLogger creates a logger writing all messages of at least the given level
+// in the given format to w.
+func NewLogger(w io.Writer, format Format, level Level) Logger {
+	return &writerLogger{
+		output: &output{writer: w, format: format},
+		level:  level,
+	}
+}
+
+// NewTextLogger creates a logger writing key=value lines to w.
+func NewTextLogger(w io.Writer, level Level) Logger {
+	return NewLogger(w, FormatText, level)
+}
+
+// NewJSONLogger creates a logger writing JSON lines to w.
+func NewJSONLogger(w io.Writer, level Level) Logger {
+	return NewLogger(w, FormatJSON, level)
+}
+
+func (l *writerLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.log(LevelDebug, msg, keysAndValues)
+}
+
+func (l *writerLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.log(LevelInfo, msg, keysAndValues)
+}
+
+func (l *writerLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.log(LevelWarn, msg, keysAndValues)
+}
+
+func (l *writerLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.log(LevelError, msg, keysAndValues)
+}
+
+func (l *writerLogger) With(keysAndValues ...interface{}) Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keysAndValues...)
+
+	return &writerLogger{
+		output: l.output,
+		level:  l.level,
+		fields: fields,
+	}
+}
+
+func (l *writerLogger) log(level Level, msg string, keysAndValues []interface{}) {
+	if level < l.level {
+		return
+	}
+
+	all := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	all = append(all, l.fields...)
+	all = append(all, keysAndValues...)
+
+	var line bytes.Buffer
+	now := time.Now()
+
+	switch l.output.format {
+	case FormatJSON:
+		line.WriteString(`{"time":`)
+		writeJSON(&line, now.Format(time.RFC3339Nano))
+		line.WriteString(`,"level":`)
+		writeJSON(&line, level.String())
+		line.WriteString(`,"msg":`)
+		writeJSON(&line, msg)
+
+		forEachPair(all, func(key string, value interface{}) {
+			line.WriteString(",")
+			writeJSON(&line, key)
+			line.WriteString(":")
+			writeJSON(&line, jsonValue(value))
+		})
+
+		line.WriteString("}\n")
+
+	default:
+		line.WriteString("time=")
+		line.WriteString(now.Format(time.RFC3339Nano))
+		line.WriteString(" level=")
+		line.WriteString(level.String())
+		line.WriteString(" msg=")
+		line.WriteString(textValue(msg))
+
+		forEachPair(all, func(key string, value interface{}) {
+			line.WriteString(" ")
+			line.WriteString(key)
+			line.WriteString("=")
+			line.WriteString(textValue(fmt.Sprint(jsonValue(value))))
+		})
+
+		line.WriteString("\n")
+	}
+
+	l.output.mutex.Lock()
+	defer l.output.mutex.Unlock()
+
+	_, _ = l.output.writer.Write(line.Bytes())
+}
+
+// forEachPair calls f for each key/value pair. A key without value gets the
+// value "!MISSING" and a key that is not a string is used as value of the key
+// "!BADKEY", similar to log/slog.
+func forEachPair(keysAndValues []interface{}, f func(key string, value interface{})) {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, ok := keysAndValues[i].(string)
+
+		if !ok {
+			f("!BADKEY", keysAndValues[i])
+			i--
+			continue
+		}
+
+		if i+1 >= len(keysAndValues) {
+			f(key, "!MISSING")
+			break
+		}
+
+		f(key, keysAndValues[i+1])
+	}
+}
+
+// jsonValue converts values that would not be marshalled in a useful way.
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	case time.Duration:
+		return v.String()
+	case []byte:
+		return string(v)
+	default:
+		return v
+	}
+}
+
+func writeJSON(buffer *bytes.Buffer, value interface{}) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buffer.Write(encoded)
+}
+
+// textValue quotes the value if it contains spaces or special characters.
+func textValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r > '~' {
+			return strconv.Quote(value)
+		}
+	}
+
+	return value
+}
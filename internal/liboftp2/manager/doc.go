@@ -0,0 +1,13 @@
+// The manager package runs sessions with many partners at the same time.
+//
+// Every session runs in its own goroutine. The Manager limits the number of
+// sessions running in total and per partner; many OFTP2 partners refuse a
+// second session while one is active (ESID 09 "Resources not available"). A
+// session for a partner that has reached its limit is not started at all, so
+// the caller can try again on its next scan. A session waiting for a free slot
+// of the global limit already counts for its partner.
+//
+// Each session should use its own client. Objects shared between the sessions,
+// e.g. the logger, the retry tracker or the ledger, must be safe for
+// concurrent use.
+package manager
@@ -0,0 +1,130 @@
+package manager
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultMaxSessions is the default number of sessions running at the same time
+const DefaultMaxSessions = 16
+
+// DefaultMaxPartnerSessions is the default number of sessions with a single
+// partner running at the same time
+const DefaultMaxPartnerSessions = 1
+
+// Limits for the number of sessions running at the same time. A value of zero
+// selects the default.
+type Limits struct {
+	// MaxSessions is the limit for all partners together
+	MaxSessions int
+
+	// MaxPartnerSessions is the limit for each partner. It can be changed for
+	// a single partner with Manager.SetPartnerLimit.
+	MaxPartnerSessions int
+}
+
+// Manager runs sessions concurrently within the limits. It can be used by
+// several goroutines.
+type Manager struct {
+	slots         chan struct{}
+	partnerLimit  int
+	mutex         sync.Mutex
+	partnerLimits map[string]int
+	active        map[string]int
+	running       sync.WaitGroup
+}
+
+// New creates a manager with the given limits
+func New(limits Limits) *Manager {
+	if limits.MaxSessions <= 0 {
+		limits.MaxSessions = DefaultMaxSessions
+	}
+	if limits.MaxPartnerSessions <= 0 {
+		limits.MaxPartnerSessions = DefaultMaxPartnerSessions
+	}
+
+	return &Manager{
+		slots:         make(chan struct{}, limits.MaxSessions),
+		partnerLimit:  limits.MaxPartnerSessions,
+		partnerLimits: make(map[string]int),
+		active:        make(map[string]int),
+	}
+}
+
+// SetPartnerLimit changes the limit for a single partner. A value of zero
+// restores the limit of the manager.
+func (m *Manager) SetPartnerLimit(partner string, limit int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if limit <= 0 {
+		delete(m.partnerLimits, partner)
+	} else {
+		m.partnerLimits[partner] = limit
+	}
+}
+
+// Start runs the session in a new goroutine, once one of the MaxSessions
+// slots is free. If the partner already has as many sessions as it is allowed
+// to, the session is not started and false is returned. If the context is done
+// before a slot is free, the session is not run either.
+func (m *Manager) Start(ctx context.Context, partner string, session func(ctx context.Context)) bool {
+	m.mutex.Lock()
+	if m.active[partner] >= m.limit(partner) {
+		m.mutex.Unlock()
+		return false
+	}
+	m.active[partner]++
+	m.mutex.Unlock()
+
+	m.running.Add(1)
+
+	go func() {
+		defer m.running.Done()
+		defer m.release(partner)
+
+		select {
+		case m.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-m.slots }()
+
+		session(ctx)
+	}()
+
+	return true
+}
+
+// Active returns the number of sessions of the partner that are running or
+// waiting for a slot
+func (m *Manager) Active(partner string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.active[partner]
+}
+
+// Wait blocks until all sessions have finished
+func (m *Manager) Wait() {
+	m.running.Wait()
+}
+
+// limit returns the limit of the partner. The mutex must be held.
+func (m *Manager) limit(partner string) int {
+	if limit, ok := m.partnerLimits[partner]; ok {
+		return limit
+	}
+	return m.partnerLimit
+}
+
+// release frees the slot of the partner
+func (m *Manager) release(partner string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.active[partner]--
+	if m.active[partner] == 0 {
+		delete(m.active, partner)
+	}
+}
@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGlobalLimit(t *testing.T) {
+	m := New(Limits{MaxSessions: 3})
+
+	var mutex sync.Mutex
+	running, maxRunning := 0, 0
+
+	for i := 0; i < 20; i++ {
+		started := m.Start(context.Background(), fmt.Sprintf("P%d", i), func(ctx context.Context) {
+			mutex.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mutex.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mutex.Lock()
+			running--
+			mutex.Unlock()
+		})
+
+		if !started {
+			t.Fatalf("session %d not started", i)
+		}
+	}
+
+	m.Wait()
+
+	if maxRunning != 3 {
+		t.Errorf("expected 3 sessions at the same time, got %d", maxRunning)
+	}
+}
+
+func TestPartnerLimit(t *testing.T) {
+	m := New(Limits{MaxSessions: 10})
+	m.SetPartnerLimit("B", 2)
+
+	block := make(chan struct{})
+	session := func(ctx context.Context) { <-block }
+
+	if !m.Start(context.Background(), "A", session) {
+		t.Fatalf("first session of A not started")
+	}
+	if m.Start(context.Background(), "A", session) {
+		t.Errorf("second session of A started")
+	}
+	if !m.Start(context.Background(), "B", session) || !m.Start(context.Background(), "B", session) {
+		t.Errorf("sessions of B not started")
+	}
+	if m.Start(context.Background(), "B", session) {
+		t.Errorf("third session of B started")
+	}
+
+	if m.Active("A") != 1 || m.Active("B") != 2 {
+		t.Errorf("wrong number of active sessions: %d %d", m.Active("A"), m.Active("B"))
+	}
+
+	close(block)
+	m.Wait()
+
+	if m.Active("A") != 0 || !m.Start(context.Background(), "A", session) {
+		t.Errorf("slot of A not released")
+	}
+	m.Wait()
+}
+
+func TestCancelWhileWaiting(t *testing.T) {
+	m := New(Limits{MaxSessions: 1})
+
+	block := make(chan struct{})
+	running := make(chan struct{})
+	m.Start(context.Background(), "A", func(ctx context.Context) {
+		close(running)
+		<-block
+	})
+	<-running
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan struct{}, 1)
+	m.Start(ctx, "B", func(ctx context.Context) { ran <- struct{}{} })
+
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+
+	if m.Active("B") != 0 {
+		t.Errorf("cancelled session still waiting")
+	}
+
+	close(block)
+	m.Wait()
+
+	if len(ran) > 0 {
+		t.Errorf("cancelled session was run")
+	}
+}
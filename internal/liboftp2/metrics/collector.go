@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/spool"
+)
+
+// DurationBuckets are the upper bounds in seconds of the buckets of the
+// transfer duration histogram
+var DurationBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600}
+
+// Collector converts the events of OFTP2 sessions into metrics
+type Collector struct {
+	sessionsStarted  *Counter
+	sessionsFailed   *Counter
+	filesSent        *Counter
+	filesReceived    *Counter
+	bytesSent        *Counter
+	bytesReceived    *Counter
+	sfna             *Counter
+	efna             *Counter
+	eerpReceived     *Counter
+	nerpReceived     *Counter
+	transferDuration *Histogram
+	creditStalls     *Counter
+	creditStallTime  *Counter
+	pendingReceipts  *Gauge
+	overdueReceipts  *Gauge
+	pendingLedger    *spool.Ledger
+	overdueThreshold time.Duration
+}
+
+// NewCollector creates the metrics in the registry. If a ledger is given, the
+// files waiting for an end to end response are counted when the metrics are
+// collected; files waiting longer than overdue are counted separately.
+func NewCollector(r *Registry, ledger *spool.Ledger, overdue time.Duration) *Collector {
+	c := &Collector{
+		sessionsStarted: r.NewCounter("oftp2_sessions_started_total",
+			"Sessions accepted by the partner.", "partner"),
+		sessionsFailed: r.NewCounter("oftp2_sessions_failed_total",
+			"Sessions that could not be established or ended with an error, by ESID reason (none if no ESID was exchanged, e.g. for connection errors).", "partner", "esid_reason"),
+		filesSent: r.NewCounter("oftp2_files_sent_total",
+			"Files accepted by the partner.", "partner"),
+		filesReceived: r.NewCounter("oftp2_files_received_total",
+			"Files received from the partner.", "partner"),
+		bytesSent: r.NewCounter("oftp2_sent_bytes_total",
+			"User data bytes of the files accepted by the partner.", "partner"),
+		bytesReceived: r.NewCounter("oftp2_received_bytes_total",
+			"User data bytes of the files received from the partner.", "partner"),
+		sfna: r.NewCounter("oftp2_sfna_total",
+			"Start file negative answers, received from or sent to the partner.", "partner", "direction", "reason"),
+		efna: r.NewCounter("oftp2_efna_total",
+			"End file negative answers, received from or sent to the partner.", "partner", "direction", "reason"),
+		eerpReceived: r.NewCounter("oftp2_eerp_received_total",
+			"End to end responses received from the partner.", "partner"),
+		nerpReceived: r.NewCounter("oftp2_nerp_received_total",
+			"Negative end responses received from the partner.", "partner", "reason"),
+		transferDuration: r.NewHistogram("oftp2_transfer_duration_seconds",
+			"Duration of successful file transfers.", DurationBuckets, "partner", "direction"),
+		creditStalls: r.NewCounter("oftp2_credit_stalls_total",
+			"Number of times a transfer waited for credit from the partner.", "partner"),
+		creditStallTime: r.NewCounter("oftp2_credit_stall_seconds_total",
+			"Time spent waiting for credit from the partner.", "partner"),
+		pendingLedger:    ledger,
+		overdueThreshold: overdue,
+	}
+
+	if ledger != nil {
+		c.pendingReceipts = r.NewGauge("oftp2_eerp_pending",
+			"Files sent that wait for an end to end response.", "partner")
+		c.overdueReceipts = r.NewGauge("oftp2_eerp_overdue",
+			fmt.Sprintf("Files sent that wait for an end to end response for more than %v.", overdue), "partner")
+		r.OnCollect(c.collectPending)
+	}
+
+	return c
+}
+
+// Observer returns an observer for a client which labels all events with the
+// configured partner. The Odette ID sent by the partner may differ from it,
+// and it is not known yet when the connection fails.
+func (c *Collector) Observer(partner string) func(event client.Event) {
+	return func(event client.Event) {
+		event.Partner = partner
+		c.Observe(event)
+	}
+}
+
+// Observe updates the metrics for the event
+func (c *Collector) Observe(event client.Event) {
+	partner := event.Partner
+
+	switch event.Kind {
+	case client.EventSessionStarted:
+		c.sessionsStarted.Inc(partner)
+
+	case client.EventSessionFailed:
+		c.sessionsFailed.Inc(partner, esidReason(event.Err))
+
+	case client.EventFileSent:
+		c.filesSent.Inc(partner)
+		if event.Stats != nil {
+			c.bytesSent.Add(float64(event.Stats.Bytes), partner)
+			c.transferDuration.Observe(event.Stats.Duration().Seconds(), partner, "sent")
+		}
+
+	case client.EventFileReceived:
+		c.filesReceived.Inc(partner)
+		if event.Stats != nil {
+			c.bytesReceived.Add(float64(event.Stats.Bytes), partner)
+			c.transferDuration.Observe(event.Stats.Duration().Seconds(), partner, "received")
+		}
+
+	case client.EventFileRejected:
+		c.rejection(partner, "received", event.Err)
+
+	case client.EventFileRefused:
+		c.rejection(partner, "sent", event.Err)
+
+	case client.EventCreditReceived:
+		c.creditStalls.Inc(partner)
+
+	case client.EventReceiptReceived:
+		if event.Receipt == nil {
+			return
+		}
+		if event.Receipt.Negative {
+			c.nerpReceived.Inc(partner, reasonLabel(event.Receipt.ReasonCode))
+		} else {
+			c.eerpReceived.Inc(partner)
+		}
+	}
+
+	// the time spent waiting is known when the transfer is finished
+	if event.Stats != nil && (event.Kind == client.EventFileSent || event.Kind == client.EventFileRejected) {
+		c.creditStallTime.Add(event.Stats.StallTime.Seconds(), partner)
+	}
+}
+
+// rejection counts an SFNA or EFNA
+func (c *Collector) rejection(partner, direction string, err error) {
+	var sfna *client.StartFileRejectedError
+	var efna *client.EndFileRejectedError
+
+	if errors.As(err, &sfna) {
+		c.sfna.Inc(partner, direction, reasonLabel(sfna.ReasonCode))
+	} else if errors.As(err, &efna) {
+		c.efna.Inc(partner, direction, reasonLabel(efna.ReasonCode))
+	}
+}
+
+// collectPending updates the gauges of the files waiting for an end to end
+// response from the ledger
+func (c *Collector) collectPending() {
+	pending := make(map[string]int)
+	overdue := make(map[string]int)
+	now := time.Now()
+
+	for _, e := range c.pendingLedger.Pending() {
+		pending[e.Partner]++
+		if now.Sub(e.Sent) > c.overdueThreshold {
+			overdue[e.Partner]++
+		}
+	}
+
+	c.pendingReceipts.Reset()
+	c.overdueReceipts.Reset()
+
+	for partner, n := range pending {
+		c.pendingReceipts.Set(float64(n), partner)
+		c.overdueReceipts.Set(float64(overdue[partner]), partner)
+	}
+}
+
+// esidReason returns the label for the ESID reason of a session failure
+func esidReason(err error) string {
+	var ended *client.SessionEndedError
+	var aborted *client.SessionAbortedError
+
+	if errors.As(err, &ended) {
+		return reasonLabel(ended.ReasonCode)
+	} else if errors.As(err, &aborted) {
+		return reasonLabel(aborted.ReasonCode)
+	}
+	return "none"
+}
+
+// reasonLabel formats a reason code as it appears in the protocol
+func reasonLabel(code int) string {
+	return fmt.Sprintf("%02d", code)
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/spool"
+)
+
+func TestCollector(t *testing.T) {
+	r := NewRegistry()
+	ledger, _ := spool.OpenLedger("")
+	c := NewCollector(r, ledger, time.Hour)
+
+	observe := c.Observer("O0013PARTNER")
+	start := time.Now().Add(-2 * time.Second)
+	stats := &client.TransferStats{Start: start, End: start.Add(2 * time.Second), Bytes: 1000, StallTime: time.Second}
+
+	observe(client.Event{Kind: client.EventSessionFailed, Err: &client.SessionEndedError{ReasonCode: 3}})
+	observe(client.Event{Kind: client.EventSessionFailed, Err: client.ErrTimeout})
+	observe(client.Event{Kind: client.EventSessionStarted, Partner: "O0013PARTNER"})
+	observe(client.Event{Kind: client.EventCreditReceived, Partner: "O0013PARTNER"})
+	observe(client.Event{Kind: client.EventFileSent, Partner: "O0013PARTNER", Stats: stats})
+	observe(client.Event{Kind: client.EventFileRejected, Partner: "O0013PARTNER", Err: &client.StartFileRejectedError{ReasonCode: 3}})
+	observe(client.Event{Kind: client.EventFileRefused, Partner: "O0013PARTNER", Err: &client.EndFileRejectedError{ReasonCode: 11}})
+	observe(client.Event{Kind: client.EventReceiptReceived, Partner: "O0013PARTNER", Receipt: &client.Receipt{Negative: true, ReasonCode: 31}})
+
+	_ = ledger.Add("O0013PARTNER", client.VirtualFile{DatasetName: "OLD"}, time.Now().Add(-2*time.Hour))
+	_ = ledger.Add("O0013PARTNER", client.VirtualFile{DatasetName: "NEW"}, time.Now())
+
+	var out bytes.Buffer
+	r.WriteText(&out)
+
+	for _, expected := range []string{
+		`oftp2_sessions_failed_total{partner="O0013PARTNER",esid_reason="03"} 1`,
+		`oftp2_sessions_failed_total{partner="O0013PARTNER",esid_reason="none"} 1`,
+		`oftp2_sessions_started_total{partner="O0013PARTNER"} 1`,
+		`oftp2_files_sent_total{partner="O0013PARTNER"} 1`,
+		`oftp2_sent_bytes_total{partner="O0013PARTNER"} 1000`,
+		`oftp2_transfer_duration_seconds_count{partner="O0013PARTNER",direction="sent"} 1`,
+		`oftp2_credit_stalls_total{partner="O0013PARTNER"} 1`,
+		`oftp2_credit_stall_seconds_total{partner="O0013PARTNER"} 1`,
+		`oftp2_sfna_total{partner="O0013PARTNER",direction="received",reason="03"} 1`,
+		`oftp2_efna_total{partner="O0013PARTNER",direction="sent",reason="11"} 1`,
+		`oftp2_nerp_received_total{partner="O0013PARTNER",reason="31"} 1`,
+		`oftp2_eerp_pending{partner="O0013PARTNER"} 2`,
+		`oftp2_eerp_overdue{partner="O0013PARTNER"} 1`,
+	} {
+		if !strings.Contains(out.String(), expected+"\n") {
+			t.Errorf("missing %s in:\n%s", expected, out.String())
+		}
+	}
+}
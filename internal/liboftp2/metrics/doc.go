@@ -0,0 +1,11 @@
+// The metrics package collects metrics of OFTP2 sessions and exposes them in
+// the Prometheus text exposition format, e.g.
+//
+//	# HELP oftp2_files_sent_total Files accepted by the partner.
+//	# TYPE oftp2_files_sent_total counter
+//	oftp2_files_sent_total{partner="O0013PARTNER"} 42
+//
+// The Registry holds counters, gauges and histograms with labels and writes
+// them for an HTTP handler. The Collector feeds the metrics from the events a
+// client reports to its observer.
+package metrics
@@ -0,0 +1,322 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds all metrics and writes them in the text exposition format. It
+// can be used by several goroutines at the same time.
+type Registry struct {
+	mutex      sync.Mutex
+	metrics    []metric
+	collectors []func()
+}
+
+// metric is a single metric family
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// register adds a metric to the registry
+func (r *Registry) register(m metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.metrics {
+		if existing.name() == m.name() {
+			panic(fmt.Sprintf("metric %s registered twice", m.name()))
+		}
+	}
+
+	r.metrics = append(r.metrics, m)
+}
+
+// OnCollect registers a function that is called each time before the metrics
+// are written, e.g. to update gauges calculated from other data
+func (r *Registry) OnCollect(f func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.collectors = append(r.collectors, f)
+}
+
+// WriteText writes all metrics in the text exposition format, ordered by name
+func (r *Registry) WriteText(w io.Writer) {
+	r.mutex.Lock()
+	metrics := make([]metric, len(r.metrics))
+	copy(metrics, r.metrics)
+	collectors := make([]func(), len(r.collectors))
+	copy(collectors, r.collectors)
+	r.mutex.Unlock()
+
+	for _, collect := range collectors {
+		collect()
+	}
+
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// ServeHTTP writes the metrics as answer to an HTTP request, so the registry
+// can be used as handler of the /metrics endpoint
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(w)
+}
+
+// family holds the values of a metric for all label combinations
+type family struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+
+	mutex  sync.Mutex
+	values map[string]*series
+}
+
+// series is the value of a metric for a single label combination
+type series struct {
+	labelValues []string
+	value       float64
+
+	// histogram data
+	buckets []uint64
+	count   uint64
+}
+
+func newFamily(name, help, kind string, labels []string) *family {
+	return &family{
+		metricName: name,
+		help:       help,
+		kind:       kind,
+		labels:     labels,
+		values:     make(map[string]*series),
+	}
+}
+
+func (f *family) name() string {
+	return f.metricName
+}
+
+// get returns the series for the label values, creating it if necessary. The
+// mutex of the family must be held.
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", f.metricName, len(f.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.values[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		f.values[key] = s
+	}
+	return s
+}
+
+// sorted returns all series ordered by their label values. The mutex of the
+// family must be held.
+func (f *family) sorted() []*series {
+	result := make([]*series, 0, len(f.values))
+	for _, s := range f.values {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.Join(result[i].labelValues, "\xff") < strings.Join(result[j].labelValues, "\xff")
+	})
+	return result
+}
+
+// header writes the HELP and TYPE lines of the family
+func (f *family) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.metricName, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.metricName, f.kind)
+}
+
+// labelString formats the labels of a series, with an optional extra label
+func (f *family) labelString(values []string, extraName, extraValue string) string {
+	pairs := make([]string, 0, len(values)+1)
+	for i, v := range values {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", f.labels[i], escapeLabel(v)))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", extraName, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a metric that only increases
+type Counter struct {
+	*family
+}
+
+// NewCounter creates a counter with the given labels and registers it
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newFamily(name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+// Add increases the counter for the label values by v, which must not be
+// negative
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("counter %s decreased", c.metricName))
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.get(labelValues).value += v
+}
+
+// Inc increases the counter for the label values by one
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.header(w)
+	for _, s := range c.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labelString(s.labelValues, "", ""), formatValue(s.value))
+	}
+}
+
+// Gauge is a metric that can go up and down
+type Gauge struct {
+	*family
+}
+
+// NewGauge creates a gauge with the given labels and registers it
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newFamily(name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+// Set sets the gauge for the label values
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.get(labelValues).value = v
+}
+
+// Reset removes the values of all label combinations
+func (g *Gauge) Reset() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.values = make(map[string]*series)
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.header(w)
+	for _, s := range g.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, g.labelString(s.labelValues, "", ""), formatValue(s.value))
+	}
+}
+
+// Histogram counts observations in buckets
+type Histogram struct {
+	*family
+	bounds []float64
+}
+
+// NewHistogram creates a histogram with the given upper bounds of the buckets
+// and registers it
+func (r *Registry) NewHistogram(name, help string, bounds []float64, labels ...string) *Histogram {
+	sorted := append([]float64(nil), bounds...)
+	sort.Float64s(sorted)
+
+	h := &Histogram{newFamily(name, help, "histogram", labels), sorted}
+	r.register(h)
+	return h
+}
+
+// Observe adds an observation for the label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s := h.get(labelValues)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(h.bounds))
+	}
+
+	for i, bound := range h.bounds {
+		if v <= bound {
+			s.buckets[i]++
+		}
+	}
+
+	s.count++
+	s.value += v
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.header(w)
+	for _, s := range h.sorted() {
+		for i, bound := range h.bounds {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelString(s.labelValues, "le", formatValue(bound)), s.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelString(s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labelString(s.labelValues, "", ""), formatValue(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labelString(s.labelValues, "", ""), s.count)
+	}
+}
+
+// formatValue formats a sample value
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeHelp escapes backslashes and line feeds in help texts
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// escapeLabel removes characters from label values that %q would escape in a
+// way not understood by the format
+func escapeLabel(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 32 && r != '\n' {
+			return -1
+		}
+		return r
+	}, s)
+}
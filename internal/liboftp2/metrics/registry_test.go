@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+
+	files := r.NewCounter("test_files_total", "Files sent.", "partner")
+	files.Inc("O0013B")
+	files.Add(2, "O0013A")
+
+	queue := r.NewGauge("test_queue", "Files\nqueued.")
+	queue.Set(3)
+
+	duration := r.NewHistogram("test_duration_seconds", "Durations.", []float64{10, 1}, "partner")
+	duration.Observe(0.5, "O0013A")
+	duration.Observe(5, "O0013A")
+	duration.Observe(50, "O0013A")
+
+	var out bytes.Buffer
+	r.WriteText(&out)
+
+	expected := `# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{partner="O0013A",le="1"} 1
+test_duration_seconds_bucket{partner="O0013A",le="10"} 2
+test_duration_seconds_bucket{partner="O0013A",le="+Inf"} 3
+test_duration_seconds_sum{partner="O0013A"} 55.5
+test_duration_seconds_count{partner="O0013A"} 3
+# HELP test_files_total Files sent.
+# TYPE test_files_total counter
+test_files_total{partner="O0013A"} 2
+test_files_total{partner="O0013B"} 1
+# HELP test_queue Files\nqueued.
+# TYPE test_queue gauge
+test_queue 3
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong output:\n%s", out.String())
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Test.", "reason").Inc("say \"hi\"\\")
+
+	var out bytes.Buffer
+	r.WriteText(&out)
+
+	if !strings.Contains(out.String(), `test_total{reason="say \"hi\"\\"} 1`) {
+		t.Errorf("label not escaped:\n%s", out.String())
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	collected := 0
+	r.OnCollect(func() { collected++ })
+	r.NewCounter("test_total", "Test.").Inc()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("wrong content type %q", w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "test_total 1\n") || collected != 1 {
+		t.Errorf("metrics not collected:\n%s", w.Body.String())
+	}
+}
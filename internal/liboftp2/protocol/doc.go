@@ -0,0 +1,23 @@
+// The protocol package implements the state tables of RFC 5024, section 9, as
+// a state machine that checks the order of the commands of a session.
+//
+// A Machine is created for the Initiator or the Responder of a session. Each
+// command sent or received is passed to the machine before it is sent or after
+// it was received. A command that is not allowed in the current state is
+// reported as *ViolationError; the session then has to be aborted with an ESID
+// reason 02 "Protocol violation".
+//
+// The machine knows the Speaker and Listener roles and follows the change of
+// direction (CD). It checks only the order of the commands, the flow control
+// with credits (CDT) and the contents of the commands are left to the session.
+// The secure authentication (SECD, AUCH, AURP) is only accepted right after
+// the exchange of the SSID commands and only if the SSID of the responder
+// requested it. The machine then checks that the initiator is authenticated
+// first and the responder second before the session continues with the
+// initiator as speaker.
+//
+// Commands are identified by their names, e.g. "SFID", as returned by
+// client.DetermineMessageType. An EFPA requesting the right to speak is passed
+// as EFPAChangeDirection, the speaker has to answer it with a CD. An SSID
+// requesting secure authentication is passed as SSIDAuthentication.
+package protocol
@@ -0,0 +1,388 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Role of the local side in the session
+type Role int
+
+const (
+	// Initiator is the side that opened the connection
+	Initiator Role = iota
+
+	// Responder is the side that accepted the connection
+	Responder
+)
+
+func (r Role) String() string {
+	if r == Initiator {
+		return "initiator"
+	}
+	return "responder"
+}
+
+// Direction of a command seen from the local side
+type Direction int
+
+const (
+	// Send is a command sent to the partner
+	Send Direction = iota
+
+	// Receive is a command received from the partner
+	Receive
+)
+
+func (d Direction) String() string {
+	if d == Send {
+		return "send"
+	}
+	return "receive"
+}
+
+// State of a session
+type State int
+
+const (
+	// StateWaitReady is the state of the initiator before the ready message
+	// (SSRM) of the responder was received
+	StateWaitReady State = iota
+
+	// StateSendReady is the state of the responder before it sent the ready
+	// message (SSRM)
+	StateSendReady
+
+	// StateSendStart is the state of the initiator before it sent its start
+	// session command (SSID)
+	StateSendStart
+
+	// StateWaitStart is the state of the responder waiting for the start
+	// session command (SSID) of the initiator
+	StateWaitStart
+
+	// StateWaitStartAnswer is the state of the initiator waiting for the
+	// start session command (SSID) of the responder
+	StateWaitStartAnswer
+
+	// StateAnswerStart is the state of the responder before it answered the
+	// start session command (SSID) of the initiator
+	StateAnswerStart
+
+	// StateSendSecurityChange is the state before the local side sends its
+	// security change command (SECD) to be authenticated by the partner
+	StateSendSecurityChange
+
+	// StateWaitChallenge is the state of the side being authenticated waiting
+	// for the challenge (AUCH) of the partner
+	StateWaitChallenge
+
+	// StateAnswerChallenge is the state of the side being authenticated
+	// before it answered the challenge with an AURP
+	StateAnswerChallenge
+
+	// StateWaitSecurityChange is the state of the authenticating side waiting
+	// for the security change command (SECD) of the partner
+	StateWaitSecurityChange
+
+	// StateSendChallenge is the state of the authenticating side before it
+	// sent its challenge (AUCH)
+	StateSendChallenge
+
+	// StateWaitChallengeAnswer is the state of the authenticating side
+	// waiting for the answer (AURP) to its challenge
+	StateWaitChallengeAnswer
+
+	// StateSpeaker is the state of the speaker while no file is transferred
+	StateSpeaker
+
+	// StateWaitStartFileAnswer is the state of the speaker waiting for the
+	// answer (SFPA or SFNA) to its start file command
+	StateWaitStartFileAnswer
+
+	// StateSendData is the state of the speaker while it sends the data of a
+	// file
+	StateSendData
+
+	// StateWaitEndFileAnswer is the state of the speaker waiting for the
+	// answer (EFPA or EFNA) to its end file command
+	StateWaitEndFileAnswer
+
+	// StateWaitReadyToReceive is the state of the speaker waiting for the
+	// ready to receive command (RTR) after it sent an EERP or NERP
+	StateWaitReadyToReceive
+
+	// StateSendChangeDirection is the state of the speaker after the listener
+	// requested the right to speak in its EFPA. Only a change direction
+	// command (CD) may be sent.
+	StateSendChangeDirection
+
+	// StateListener is the state of the listener while no file is transferred
+	StateListener
+
+	// StateAnswerStartFile is the state of the listener before it answered a
+	// start file command
+	StateAnswerStartFile
+
+	// StateReceiveData is the state of the listener while it receives the
+	// data of a file
+	StateReceiveData
+
+	// StateAnswerEndFile is the state of the listener before it answered an
+	// end file command
+	StateAnswerEndFile
+
+	// StateAnswerEndResponse is the state of the listener before it answered
+	// an EERP or NERP with a ready to receive command (RTR)
+	StateAnswerEndResponse
+
+	// StateWaitChangeDirection is the state of the listener waiting for the
+	// change direction command (CD) it requested in its EFPA
+	StateWaitChangeDirection
+
+	// StateClosed is the state after the session was ended
+	StateClosed
+)
+
+var stateNames = map[State]string{
+	StateWaitReady:           "wait for ready message",
+	StateSendReady:           "send ready message",
+	StateSendStart:           "send start session",
+	StateWaitStart:           "wait for start session",
+	StateWaitStartAnswer:     "wait for start session answer",
+	StateAnswerStart:         "answer start session",
+	StateSendSecurityChange:  "send security change",
+	StateWaitChallenge:       "wait for challenge",
+	StateAnswerChallenge:     "answer challenge",
+	StateWaitSecurityChange:  "wait for security change",
+	StateSendChallenge:       "send challenge",
+	StateWaitChallengeAnswer: "wait for challenge answer",
+	StateSpeaker:             "speaker",
+	StateWaitStartFileAnswer: "wait for start file answer",
+	StateSendData:            "send data",
+	StateWaitEndFileAnswer:   "wait for end file answer",
+	StateWaitReadyToReceive:  "wait for ready to receive",
+	StateSendChangeDirection: "send change direction",
+	StateListener:            "listener",
+	StateAnswerStartFile:     "answer start file",
+	StateReceiveData:         "receive data",
+	StateAnswerEndFile:       "answer end file",
+	StateAnswerEndResponse:   "answer end response",
+	StateWaitChangeDirection: "wait for change direction",
+	StateClosed:              "closed",
+}
+
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("state %d", int(s))
+}
+
+// Speaker tells whether the local side is the speaker in the state
+func (s State) Speaker() bool {
+	return s >= StateSpeaker && s <= StateSendChangeDirection
+}
+
+// Listener tells whether the local side is the listener in the state
+func (s State) Listener() bool {
+	return s >= StateListener && s <= StateWaitChangeDirection
+}
+
+// Authentication tells whether the partners authenticate each other in the
+// state, after the SSID commands have been exchanged
+func (s State) Authentication() bool {
+	return s >= StateSendSecurityChange && s <= StateWaitChallengeAnswer
+}
+
+// Started tells whether the session has been started in the state, i.e. the
+// SSID commands have been exchanged and the session has not been ended yet
+func (s State) Started() bool {
+	return s.Authentication() || s.Speaker() || s.Listener()
+}
+
+// Transfer tells whether a file is being transferred in the state, from the
+// start file command to the answer to the end file command
+func (s State) Transfer() bool {
+	switch s {
+	case StateWaitStartFileAnswer, StateSendData, StateWaitEndFileAnswer,
+		StateAnswerStartFile, StateReceiveData, StateAnswerEndFile:
+		return true
+	}
+	return false
+}
+
+// SSIDAuthentication is the name of an SSID whose secure authentication
+// indicator is set. If the SSID of the responder is one, the secure
+// authentication follows the exchange of the SSID commands.
+const SSIDAuthentication = "SSID(Y)"
+
+// EFPAChangeDirection is the name of an EFPA whose change direction indicator
+// is set. The listener requests the right to speak with it and the speaker has
+// to answer with a CD.
+const EFPAChangeDirection = "EFPA(Y)"
+
+// transition is a command sent or received in a state
+type transition struct {
+	state     State
+	direction Direction
+	command   string
+}
+
+// transitions maps the allowed commands of each state to the following state.
+// An ESID is allowed in every state except StateClosed and is not listed.
+var transitions = map[transition]State{
+	// start of the session
+	{StateWaitReady, Receive, "SSRM"}:       StateSendStart,
+	{StateSendReady, Send, "SSRM"}:          StateWaitStart,
+	{StateSendStart, Send, "SSID"}:          StateWaitStartAnswer,
+	{StateWaitStart, Receive, "SSID"}:       StateAnswerStart,
+	{StateWaitStartAnswer, Receive, "SSID"}: StateSpeaker,
+	{StateAnswerStart, Send, "SSID"}:        StateListener,
+
+	// start of a session with secure authentication
+	{StateSendStart, Send, SSIDAuthentication}:          StateWaitStartAnswer,
+	{StateWaitStart, Receive, SSIDAuthentication}:       StateAnswerStart,
+	{StateWaitStartAnswer, Receive, SSIDAuthentication}: StateSendSecurityChange,
+	{StateAnswerStart, Send, SSIDAuthentication}:        StateWaitSecurityChange,
+
+	// speaker
+	{StateSpeaker, Send, "SFID"}:                StateWaitStartFileAnswer,
+	{StateSpeaker, Send, "EERP"}:                StateWaitReadyToReceive,
+	{StateSpeaker, Send, "NERP"}:                StateWaitReadyToReceive,
+	{StateSpeaker, Send, "CD"}:                  StateListener,
+	{StateWaitStartFileAnswer, Receive, "SFPA"}: StateSendData,
+	{StateWaitStartFileAnswer, Receive, "SFNA"}: StateSpeaker,
+	{StateSendData, Send, "DATA"}:               StateSendData,
+	{StateSendData, Receive, "CDT"}:             StateSendData,
+	{StateSendData, Send, "EFID"}:               StateWaitEndFileAnswer,
+	{StateWaitEndFileAnswer, Receive, "EFPA"}:   StateSpeaker,
+	{StateWaitEndFileAnswer, Receive, "EFNA"}:   StateSpeaker,
+	{StateWaitReadyToReceive, Receive, "RTR"}:   StateSpeaker,
+
+	// the listener requested the right to speak in the EFPA
+	{StateWaitEndFileAnswer, Receive, EFPAChangeDirection}: StateSendChangeDirection,
+	{StateSendChangeDirection, Send, "CD"}:                 StateListener,
+
+	// listener
+	{StateListener, Receive, "SFID"}:      StateAnswerStartFile,
+	{StateListener, Receive, "EERP"}:      StateAnswerEndResponse,
+	{StateListener, Receive, "NERP"}:      StateAnswerEndResponse,
+	{StateListener, Receive, "CD"}:        StateSpeaker,
+	{StateAnswerStartFile, Send, "SFPA"}:  StateReceiveData,
+	{StateAnswerStartFile, Send, "SFNA"}:  StateListener,
+	{StateReceiveData, Receive, "DATA"}:   StateReceiveData,
+	{StateReceiveData, Send, "CDT"}:       StateReceiveData,
+	{StateReceiveData, Receive, "EFID"}:   StateAnswerEndFile,
+	{StateAnswerEndFile, Send, "EFPA"}:    StateListener,
+	{StateAnswerEndFile, Send, "EFNA"}:    StateListener,
+	{StateAnswerEndResponse, Send, "RTR"}: StateListener,
+
+	// the right to speak was requested in the EFPA
+	{StateAnswerEndFile, Send, EFPAChangeDirection}: StateWaitChangeDirection,
+	{StateWaitChangeDirection, Receive, "CD"}:       StateSpeaker,
+}
+
+// authentication maps the commands of the secure authentication (see RFC
+// 5024, 5.3.1) to the following state. The initiator is authenticated first
+// (SECD, AUCH, AURP from its point of view), then the responder, so both pass
+// the same states in a different order.
+var authentication = map[Role]map[transition]State{
+	Initiator: {
+		{StateSendSecurityChange, Send, "SECD"}:     StateWaitChallenge,
+		{StateWaitChallenge, Receive, "AUCH"}:       StateAnswerChallenge,
+		{StateAnswerChallenge, Send, "AURP"}:        StateWaitSecurityChange,
+		{StateWaitSecurityChange, Receive, "SECD"}:  StateSendChallenge,
+		{StateSendChallenge, Send, "AUCH"}:          StateWaitChallengeAnswer,
+		{StateWaitChallengeAnswer, Receive, "AURP"}: StateSpeaker,
+	},
+	Responder: {
+		{StateWaitSecurityChange, Receive, "SECD"}:  StateSendChallenge,
+		{StateSendChallenge, Send, "AUCH"}:          StateWaitChallengeAnswer,
+		{StateWaitChallengeAnswer, Receive, "AURP"}: StateSendSecurityChange,
+		{StateSendSecurityChange, Send, "SECD"}:     StateWaitChallenge,
+		{StateWaitChallenge, Receive, "AUCH"}:       StateAnswerChallenge,
+		{StateAnswerChallenge, Send, "AURP"}:        StateListener,
+	},
+}
+
+// ViolationError is returned if a command is not allowed in the current state
+type ViolationError struct {
+	State     State
+	Direction Direction
+	Command   string
+}
+
+func (e *ViolationError) Error() string {
+	return fmt.Sprintf("protocol violation: cannot %v %s in state %v", e.Direction, e.Command, e.State)
+}
+
+// ErrClosed is returned for commands after the session was ended
+var ErrClosed = errors.New("session closed")
+
+// Machine follows the state of a session. It is not safe for concurrent use.
+type Machine struct {
+	role  Role
+	state State
+}
+
+// NewMachine creates the state machine for a new connection
+func NewMachine(role Role) *Machine {
+	m := &Machine{role: role, state: StateWaitReady}
+	if role == Responder {
+		m.state = StateSendReady
+	}
+	return m
+}
+
+// Role returns the role the machine was created for
+func (m *Machine) Role() Role {
+	return m.role
+}
+
+// State returns the current state
+func (m *Machine) State() State {
+	return m.state
+}
+
+// Send checks if the command may be sent in the current state and moves on
+// to the next state
+func (m *Machine) Send(command string) error {
+	return m.apply(Send, command)
+}
+
+// Receive checks if the received command is allowed in the current state and
+// moves on to the next state
+func (m *Machine) Receive(command string) error {
+	return m.apply(Receive, command)
+}
+
+// Close moves the machine to StateClosed, e.g. when the connection is lost
+func (m *Machine) Close() {
+	m.state = StateClosed
+}
+
+// apply performs the transition for the command. The state is not changed if
+// the command is not allowed.
+func (m *Machine) apply(direction Direction, command string) error {
+	if m.state == StateClosed {
+		return ErrClosed
+	}
+
+	if command == "ESID" {
+		m.state = StateClosed
+		return nil
+	}
+
+	t := transition{m.state, direction, command}
+	next, ok := transitions[t]
+	if !ok {
+		next, ok = authentication[m.role][t]
+	}
+	if !ok {
+		return &ViolationError{State: m.state, Direction: direction, Command: command}
+	}
+
+	m.state = next
+	return nil
+}
@@ -0,0 +1,271 @@
+package protocol
+
+import (
+	"errors"
+	"testing"
+)
+
+// step is a command sent or received by the local side
+type step struct {
+	direction Direction
+	command   string
+}
+
+func send(command string) step    { return step{Send, command} }
+func receive(command string) step { return step{Receive, command} }
+
+// run applies the steps to the machine and stops at the first error
+func run(m *Machine, steps []step) (int, error) {
+	for i, s := range steps {
+		var err error
+		if s.direction == Send {
+			err = m.Send(s.command)
+		} else {
+			err = m.Receive(s.command)
+		}
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(steps), nil
+}
+
+// initiatorStart is the start of a session of the initiator, RFC 5024 9.3
+var initiatorStart = []step{receive("SSRM"), send("SSID"), receive("SSID")}
+
+// responderStart is the start of a session of the responder
+var responderStart = []step{send("SSRM"), receive("SSID"), send("SSID")}
+
+func join(parts ...[]step) []step {
+	result := make([]step, 0)
+	for _, p := range parts {
+		result = append(result, p...)
+	}
+	return result
+}
+
+func TestValidSequences(t *testing.T) {
+	tests := []struct {
+		name  string
+		role  Role
+		steps []step
+		state State
+	}{
+		{"initiator start", Initiator, initiatorStart, StateSpeaker},
+		{"responder start", Responder, responderStart, StateListener},
+		{"send file", Initiator, join(initiatorStart, []step{
+			send("SFID"), receive("SFPA"),
+			send("DATA"), send("DATA"), receive("CDT"), send("DATA"),
+			send("EFID"), receive("EFPA"),
+		}), StateSpeaker},
+		{"file refused at start", Initiator, join(initiatorStart, []step{
+			send("SFID"), receive("SFNA"), send("SFID"), receive("SFPA"),
+		}), StateSendData},
+		{"file refused at end", Initiator, join(initiatorStart, []step{
+			send("SFID"), receive("SFPA"), send("EFID"), receive("EFNA"),
+		}), StateSpeaker},
+		{"receive file", Initiator, join(initiatorStart, []step{
+			send("CD"),
+			receive("SFID"), send("SFPA"),
+			receive("DATA"), send("CDT"), receive("DATA"),
+			receive("EFID"), send("EFPA"),
+			receive("EERP"), send("RTR"),
+			receive("NERP"), send("RTR"),
+			receive("CD"),
+		}), StateSpeaker},
+		{"end file answer without change direction", Initiator, join(initiatorStart, []step{
+			send("SFID"), receive("SFPA"), send("EFID"), receive("EFPA"), send("SFID"),
+		}), StateWaitStartFileAnswer},
+		{"change direction requested by listener", Initiator, join(initiatorStart, []step{
+			send("SFID"), receive("SFPA"), send("EFID"), receive(EFPAChangeDirection), send("CD"),
+			receive("SFID"), send("SFPA"), receive("EFID"), send("EFPA"), receive("CD"),
+		}), StateSpeaker},
+		{"change direction requested from speaker", Responder, join(responderStart, []step{
+			receive("SFID"), send("SFPA"), receive("EFID"), send(EFPAChangeDirection), receive("CD"),
+			send("EERP"), receive("RTR"),
+		}), StateSpeaker},
+		{"send end to end responses", Responder, join(responderStart, []step{
+			receive("CD"), send("EERP"), receive("RTR"), send("NERP"), receive("RTR"), send("CD"),
+		}), StateListener},
+		{"secure authentication of initiator", Initiator, []step{
+			receive("SSRM"), send(SSIDAuthentication), receive(SSIDAuthentication),
+			send("SECD"), receive("AUCH"), send("AURP"),
+			receive("SECD"), send("AUCH"), receive("AURP"),
+			send("SFID"),
+		}, StateWaitStartFileAnswer},
+		{"secure authentication of responder", Responder, []step{
+			send("SSRM"), receive(SSIDAuthentication), send(SSIDAuthentication),
+			receive("SECD"), send("AUCH"), receive("AURP"),
+			send("SECD"), receive("AUCH"), send("AURP"),
+			receive("SFID"),
+		}, StateAnswerStartFile},
+		{"authentication requested by initiator only", Initiator, join([]step{
+			receive("SSRM"), send(SSIDAuthentication), receive("SSID"),
+		}, []step{send("SFID")}), StateWaitStartFileAnswer},
+		{"end session", Initiator, join(initiatorStart, []step{send("ESID")}), StateClosed},
+		{"session rejected", Initiator, []step{receive("SSRM"), send("SSID"), receive("ESID")}, StateClosed},
+		{"abort during transfer", Responder, join(responderStart, []step{
+			receive("SFID"), send("SFPA"), receive("DATA"), send("ESID"),
+		}), StateClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMachine(tt.role)
+			i, err := run(m, tt.steps)
+			if err != nil {
+				t.Fatalf("step %d (%v %s): %v", i, tt.steps[i].direction, tt.steps[i].command, err)
+			}
+			if m.State() != tt.state {
+				t.Errorf("expected state %v, got %v", tt.state, m.State())
+			}
+		})
+	}
+}
+
+func TestViolations(t *testing.T) {
+	tests := []struct {
+		name  string
+		role  Role
+		steps []step
+		state State // state in which the last step is refused
+	}{
+		{"command before ready message", Initiator, []step{send("SSID")}, StateWaitReady},
+		{"file before session start", Initiator, []step{receive("SSRM"), send("SFID")}, StateSendStart},
+		{"responder sends before start", Responder, []step{send("SSID")}, StateSendReady},
+		{"listener sends file", Initiator, join(initiatorStart, []step{send("CD"), send("SFID")}), StateListener},
+		{"speaker receives file", Initiator, join(initiatorStart, []step{receive("SFID")}), StateSpeaker},
+		{"data without start file", Initiator, join(initiatorStart, []step{send("DATA")}), StateSpeaker},
+		{"data after refusal", Responder, join(responderStart, []step{
+			receive("SFID"), send("SFNA"), receive("DATA"),
+		}), StateListener},
+		{"data before answer", Initiator, join(initiatorStart, []step{send("SFID"), send("DATA")}), StateWaitStartFileAnswer},
+		{"second file while sending", Initiator, join(initiatorStart, []step{
+			send("SFID"), receive("SFPA"), send("SFID"),
+		}), StateSendData},
+		{"change direction during transfer", Responder, join(responderStart, []step{
+			receive("SFID"), send("SFPA"), receive("CD"),
+		}), StateReceiveData},
+		{"credit from the speaker", Responder, join(responderStart, []step{
+			receive("SFID"), send("SFPA"), receive("CDT"),
+		}), StateReceiveData},
+		{"end to end response without ready to receive", Initiator, join(initiatorStart, []step{
+			send("EERP"), send("EERP"),
+		}), StateWaitReadyToReceive},
+		{"ready to receive without end to end response", Initiator, join(initiatorStart, []step{receive("RTR")}), StateSpeaker},
+		{"file after change direction requested", Initiator, join(initiatorStart, []step{
+			send("SFID"), receive("SFPA"), send("EFID"), receive(EFPAChangeDirection), send("SFID"),
+		}), StateSendChangeDirection},
+		{"end to end response after change direction requested", Initiator, join(initiatorStart, []step{
+			send("SFID"), receive("SFPA"), send("EFID"), receive(EFPAChangeDirection), send("EERP"),
+		}), StateSendChangeDirection},
+		{"file instead of requested change direction", Responder, join(responderStart, []step{
+			receive("SFID"), send("SFPA"), receive("EFID"), send(EFPAChangeDirection), receive("SFID"),
+		}), StateWaitChangeDirection},
+		{"ready message twice", Initiator, []step{receive("SSRM"), receive("SSRM")}, StateSendStart},
+		{"authentication during transfer", Initiator, join(initiatorStart, []step{
+			send("SFID"), receive("SFPA"), receive("AUCH"),
+		}), StateSendData},
+		{"authentication not negotiated", Initiator, join(initiatorStart, []step{send("SECD")}), StateSpeaker},
+		{"authentication after file transfer", Responder, join(responderStart, []step{
+			receive("SFID"), send("SFPA"), receive("EFID"), send("EFPA"), receive("SECD"),
+		}), StateListener},
+		{"file before authentication", Initiator, []step{
+			receive("SSRM"), send(SSIDAuthentication), receive(SSIDAuthentication), send("SFID"),
+		}, StateSendSecurityChange},
+		{"responder authenticated first", Initiator, []step{
+			receive("SSRM"), send(SSIDAuthentication), receive(SSIDAuthentication), receive("SECD"),
+		}, StateSendSecurityChange},
+		{"answer before challenge", Initiator, []step{
+			receive("SSRM"), send(SSIDAuthentication), receive(SSIDAuthentication),
+			send("SECD"), send("AURP"),
+		}, StateWaitChallenge},
+		{"challenge without security change", Responder, []step{
+			send("SSRM"), receive(SSIDAuthentication), send(SSIDAuthentication),
+			receive("SECD"), send("AUCH"), receive("AURP"), send("AUCH"),
+		}, StateSendSecurityChange},
+		{"file before authentication of responder", Responder, []step{
+			send("SSRM"), receive(SSIDAuthentication), send(SSIDAuthentication),
+			receive("SECD"), send("AUCH"), receive("AURP"), receive("SFID"),
+		}, StateSendSecurityChange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMachine(tt.role)
+			i, err := run(m, tt.steps)
+
+			var violation *ViolationError
+			if !errors.As(err, &violation) {
+				t.Fatalf("expected violation, got %v", err)
+			}
+			if i != len(tt.steps)-1 {
+				t.Errorf("violation at step %d, expected %d", i, len(tt.steps)-1)
+			}
+			last := tt.steps[len(tt.steps)-1]
+			if violation.State != tt.state || violation.Direction != last.direction || violation.Command != last.command {
+				t.Errorf("wrong violation %+v", violation)
+			}
+
+			// the state is not changed by the refused command
+			if m.State() != tt.state {
+				t.Errorf("state changed to %v", m.State())
+			}
+		})
+	}
+}
+
+func TestClosed(t *testing.T) {
+	m := NewMachine(Initiator)
+	m.Close()
+
+	if err := m.Receive("SSRM"); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if err := m.Send("ESID"); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestStateProperties(t *testing.T) {
+	tests := []struct {
+		state    State
+		speaker  bool
+		listener bool
+		transfer bool
+	}{
+		{StateWaitReady, false, false, false},
+		{StateSendStart, false, false, false},
+		{StateSendSecurityChange, false, false, false},
+		{StateWaitChallengeAnswer, false, false, false},
+		{StateSpeaker, true, false, false},
+		{StateSendData, true, false, true},
+		{StateWaitReadyToReceive, true, false, false},
+		{StateSendChangeDirection, true, false, false},
+		{StateListener, false, true, false},
+		{StateReceiveData, false, true, true},
+		{StateAnswerEndResponse, false, true, false},
+		{StateWaitChangeDirection, false, true, false},
+		{StateClosed, false, false, false},
+	}
+
+	for _, tt := range tests {
+		if tt.state.Speaker() != tt.speaker || tt.state.Listener() != tt.listener || tt.state.Transfer() != tt.transfer {
+			t.Errorf("wrong properties of %v", tt.state)
+		}
+		authentication := tt.state == StateSendSecurityChange || tt.state == StateWaitChallengeAnswer
+		if tt.state.Authentication() != authentication {
+			t.Errorf("wrong authentication property of %v", tt.state)
+		}
+		if tt.state.Started() != (authentication || tt.speaker || tt.listener) {
+			t.Errorf("wrong started property of %v", tt.state)
+		}
+	}
+}
+
+func TestViolationError(t *testing.T) {
+	err := &ViolationError{State: StateSpeaker, Direction: Receive, Command: "SFID"}
+	if err.Error() != "protocol violation: cannot receive SFID in state speaker" {
+		t.Errorf("wrong message %q", err.Error())
+	}
+}
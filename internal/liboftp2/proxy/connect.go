@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// connect asks the HTTP proxy to open a tunnel to address with the CONNECT
+// method. The returned connection also delivers data the proxy sent directly
+// after its answer.
+func (p *Proxy) connect(conn net.Conn, address string) (net.Conn, error) {
+	if _, _, err := splitAddress(address); err != nil {
+		return conn, err
+	}
+
+	request := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", address, address)
+	if p.Username != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(p.Username + ":" + p.Password))
+		request += "Proxy-Authorization: Basic " + credentials + "\r\n"
+	}
+	request += "\r\n"
+
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return conn, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return conn, err
+	}
+	response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusProxyAuthRequired && p.Username == "":
+		return conn, errors.New("proxy requires authentication")
+	case response.StatusCode == http.StatusProxyAuthRequired:
+		return conn, errors.New("authentication failed")
+	case response.StatusCode < 200 || response.StatusCode > 299:
+		return conn, errors.New(fmt.Sprintf("connect failed: %s", response.Status))
+	}
+
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn reads the data buffered while reading the answer of the proxy
+// before it reads from the connection again
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
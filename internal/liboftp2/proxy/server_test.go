@@ -0,0 +1,202 @@
+package proxy_test
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// testProxy is a minimal in-process proxy, which requires the credentials if
+// they are set and remembers the targets it connected to
+type testProxy struct {
+	listener net.Listener
+	username string
+	password string
+
+	mutex   sync.Mutex
+	targets []string
+}
+
+// startProxy starts a proxy on a random port, serve handles a connection
+func startProxy(t *testing.T, username, password string, serve func(p *testProxy, conn net.Conn)) *testProxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	p := &testProxy{listener: listener, username: username, password: password}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(p, conn)
+		}
+	}()
+
+	return p
+}
+
+// address returns the address of the proxy
+func (p *testProxy) address() string {
+	return p.listener.Addr().String()
+}
+
+// connected returns the targets the proxy connected to
+func (p *testProxy) connected() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
+// tunnel connects to the target and relays the data in both directions
+func (p *testProxy) tunnel(client net.Conn, clientReader io.Reader, target string, established func() error) {
+	server, err := net.Dial("tcp", target)
+	if err != nil {
+		return
+	}
+	defer server.Close()
+
+	p.mutex.Lock()
+	p.targets = append(p.targets, target)
+	p.mutex.Unlock()
+
+	if established() != nil {
+		return
+	}
+
+	go func() {
+		_, _ = io.Copy(server, clientReader)
+		server.Close()
+	}()
+	_, _ = io.Copy(client, server)
+}
+
+// serveSOCKS5 speaks the server side of SOCKS5 (RFC 1928, RFC 1929)
+func serveSOCKS5(p *testProxy, conn net.Conn) {
+	defer conn.Close()
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+
+	method := byte(0xff)
+	for _, m := range methods {
+		if (p.username == "" && m == 0x00) || (p.username != "" && m == 0x02) {
+			method = m
+		}
+	}
+	_, _ = conn.Write([]byte{0x05, method})
+
+	switch method {
+	case 0xff:
+		return
+	case 0x02:
+		version := make([]byte, 2)
+		if _, err := io.ReadFull(conn, version); err != nil {
+			return
+		}
+		username := make([]byte, version[1])
+		if _, err := io.ReadFull(conn, username); err != nil {
+			return
+		}
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return
+		}
+		password := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, password); err != nil {
+			return
+		}
+
+		if string(username) != p.username || string(password) != p.password {
+			_, _ = conn.Write([]byte{0x01, 0x01})
+			return
+		}
+		_, _ = conn.Write([]byte{0x01, 0x00})
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil || request[1] != 0x01 {
+		return
+	}
+
+	var host string
+	switch request[3] {
+	case 0x01, 0x04:
+		ip := make([]byte, net.IPv4len)
+		if request[3] == 0x04 {
+			ip = make([]byte, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	case 0x03:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return
+		}
+		host = string(name)
+	default:
+		return
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+
+	p.tunnel(conn, conn, target, func() error {
+		_, err := conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		return err
+	})
+}
+
+// serveHTTP speaks the server side of HTTP CONNECT with basic authentication
+func serveHTTP(p *testProxy, conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	request, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+
+	if request.Method != http.MethodConnect {
+		_, _ = io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+		return
+	}
+
+	if p.username != "" {
+		expected := "Basic " + base64.StdEncoding.EncodeToString([]byte(p.username+":"+p.password))
+		if request.Header.Get("Proxy-Authorization") != expected {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Basic\r\n\r\n")
+			return
+		}
+	}
+
+	p.tunnel(conn, reader, request.Host, func() error {
+		_, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		return err
+	})
+}
@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// SOCKS5 protocol values, see RFC 1928 and RFC 1929
+const (
+	socksVersion          = 0x05
+	socksAuthVersion      = 0x01
+	socksMethodNone       = 0x00
+	socksMethodPassword   = 0x02
+	socksMethodNoneAccept = 0xff
+	socksCommandConnect   = 0x01
+	socksAddressIPv4      = 0x01
+	socksAddressDomain    = 0x03
+	socksAddressIPv6      = 0x04
+	socksReplySucceeded   = 0x00
+)
+
+// socksReplies describes the reply codes of a SOCKS5 server
+var socksReplies = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// socks5 negotiates the authentication with the SOCKS5 server and asks it to
+// connect to address
+func (p *Proxy) socks5(conn net.Conn, address string) error {
+	host, port, err := splitAddress(address)
+	if err != nil {
+		return err
+	}
+
+	methods := []byte{socksMethodNone}
+	if p.Username != "" {
+		methods = []byte{socksMethodNone, socksMethodPassword}
+	}
+
+	greeting := append([]byte{socksVersion, byte(len(methods))}, methods...)
+	if _, err = conn.Write(greeting); err != nil {
+		return err
+	}
+
+	answer := make([]byte, 2)
+	if _, err = io.ReadFull(conn, answer); err != nil {
+		return err
+	}
+	if answer[0] != socksVersion {
+		return errors.New(fmt.Sprintf("not a SOCKS5 server, version %d", answer[0]))
+	}
+
+	switch answer[1] {
+	case socksMethodNone:
+	case socksMethodPassword:
+		if err = p.socks5Authenticate(conn); err != nil {
+			return err
+		}
+	case socksMethodNoneAccept:
+		return errors.New("no acceptable authentication method, credentials required")
+	default:
+		return errors.New(fmt.Sprintf("unsupported authentication method %d", answer[1]))
+	}
+
+	request := []byte{socksVersion, socksCommandConnect, 0x00}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		request = append(request, socksAddressIPv4)
+		request = append(request, ip.To4()...)
+	} else if ip != nil {
+		request = append(request, socksAddressIPv6)
+		request = append(request, ip.To16()...)
+	} else {
+		if len(host) > 255 {
+			return errors.New(fmt.Sprintf("host name %s too long", host))
+		}
+		request = append(request, socksAddressDomain, byte(len(host)))
+		request = append(request, host...)
+	}
+	request = append(request, byte(port>>8), byte(port))
+
+	if _, err = conn.Write(request); err != nil {
+		return err
+	}
+
+	return socks5Reply(conn)
+}
+
+// socks5Authenticate sends username and password (RFC 1929)
+func (p *Proxy) socks5Authenticate(conn net.Conn) error {
+	if p.Username == "" || len(p.Username) > 255 || len(p.Password) > 255 {
+		return errors.New("invalid credentials for SOCKS5 authentication")
+	}
+
+	request := []byte{socksAuthVersion, byte(len(p.Username))}
+	request = append(request, p.Username...)
+	request = append(request, byte(len(p.Password)))
+	request = append(request, p.Password...)
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	answer := make([]byte, 2)
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return err
+	}
+	if answer[1] != 0x00 {
+		return errors.New("authentication failed")
+	}
+
+	return nil
+}
+
+// socks5Reply reads the reply to the connect request
+func socks5Reply(conn net.Conn) error {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+
+	if header[1] != socksReplySucceeded {
+		reason, ok := socksReplies[header[1]]
+		if !ok {
+			reason = fmt.Sprintf("reply %d", header[1])
+		}
+		return errors.New(fmt.Sprintf("connect failed: %s", reason))
+	}
+
+	// the bound address is not needed, but has to be consumed
+	var length int
+	switch header[3] {
+	case socksAddressIPv4:
+		length = net.IPv4len
+	case socksAddressIPv6:
+		length = net.IPv6len
+	case socksAddressDomain:
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return err
+		}
+		length = int(size[0])
+	default:
+		return errors.New(fmt.Sprintf("unknown address type %d in reply", header[3]))
+	}
+
+	bound := make([]byte, length+2)
+	_, err := io.ReadFull(conn, bound)
+	return err
+}
@@ -0,0 +1,7 @@
+// The retry package schedules new attempts for transmissions that failed with
+// a temporary error.
+//
+// Whether a failure is temporary is decided by the caller, usually with
+// client.IsRetryable. The Policy calculates the exponential back-off between
+// the attempts, the Tracker keeps the state of all files waiting for a retry.
+package retry
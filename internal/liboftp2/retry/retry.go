@@ -0,0 +1,130 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy describes how often and when a failed transmission is retried
+type Policy struct {
+	// MaxAttempts is the maximum number of attempts including the first one. A
+	// value of 1 or less disables retries.
+	MaxAttempts int
+
+	// InitialDelay is the time to wait before the first retry
+	InitialDelay time.Duration
+
+	// MaxDelay limits the time between two attempts
+	MaxDelay time.Duration
+
+	// Multiplier is applied to the delay after each failed retry. Values less
+	// than 1 are treated as 2.
+	Multiplier float64
+}
+
+// DefaultPolicy tries a transmission five times, waiting one minute before the
+// first retry and doubling the delay up to one hour.
+var DefaultPolicy = Policy{
+	MaxAttempts:  5,
+	InitialDelay: time.Minute,
+	MaxDelay:     time.Hour,
+	Multiplier:   2,
+}
+
+// Delay returns the time to wait after the given failed attempt (starting with
+// 1) before the next one.
+func (p Policy) Delay(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	delay := float64(p.InitialDelay)
+	for i := 1; i < attempt; i++ {
+		delay *= multiplier
+		if p.MaxDelay > 0 && delay >= float64(p.MaxDelay) {
+			return p.MaxDelay
+		}
+	}
+
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+
+	return time.Duration(delay)
+}
+
+// Tracker keeps the number of failed attempts and the time of the next attempt
+// for each file. Files are identified by a key chosen by the caller, e.g. the
+// path of the file. A Tracker can be used by several goroutines.
+type Tracker struct {
+	policy  Policy
+	mutex   sync.Mutex
+	entries map[string]*entry
+}
+
+// entry is the retry state of a single file
+type entry struct {
+	attempts int
+	next     time.Time
+}
+
+// NewTracker creates a tracker using the given policy
+func NewTracker(policy Policy) *Tracker {
+	return &Tracker{policy: policy, entries: make(map[string]*entry)}
+}
+
+// Ready checks if the file may be tried at the given time. Files without
+// failed attempts are always ready.
+func (t *Tracker) Ready(key string, now time.Time) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	e, ok := t.entries[key]
+	return !ok || !now.Before(e.next)
+}
+
+// Attempts returns the number of failed attempts of the file
+func (t *Tracker) Attempts(key string) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if e, ok := t.entries[key]; ok {
+		return e.attempts
+	}
+	return 0
+}
+
+// Failed records a failed attempt with a temporary error. If the file may be
+// tried again, the time of the next attempt is returned together with true.
+// If all attempts are used up, the file is forgotten and false is returned;
+// the caller has to give up on the file.
+func (t *Tracker) Failed(key string, now time.Time) (time.Time, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok {
+		e = &entry{}
+		t.entries[key] = e
+	}
+
+	e.attempts++
+
+	if e.attempts >= t.policy.MaxAttempts {
+		delete(t.entries, key)
+		return time.Time{}, false
+	}
+
+	e.next = now.Add(t.policy.Delay(e.attempts))
+	return e.next, true
+}
+
+// Forget removes the state of a file, e.g. after it was sent successfully or
+// failed permanently.
+func (t *Tracker) Forget(key string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.entries, key)
+}
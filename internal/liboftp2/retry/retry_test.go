@@ -0,0 +1,66 @@
+package retry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{MaxAttempts: 10, InitialDelay: time.Second, MaxDelay: 10 * time.Second, Multiplier: 2}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+
+	for i, e := range expected {
+		if d := p.Delay(i + 1); d != e {
+			t.Errorf("delay after attempt %d: expected %v, got %v", i+1, e, d)
+		}
+	}
+}
+
+func TestPolicyDefaultMultiplier(t *testing.T) {
+	p := Policy{InitialDelay: time.Second}
+
+	if d := p.Delay(3); d != 4*time.Second {
+		t.Errorf("expected 4s, got %v", d)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(Policy{MaxAttempts: 3, InitialDelay: time.Minute, Multiplier: 2})
+	now := time.Date(2020, 12, 17, 10, 0, 0, 0, time.UTC)
+
+	if !tracker.Ready("a", now) {
+		t.Errorf("unknown file not ready")
+	}
+
+	next, retry := tracker.Failed("a", now)
+	if !retry || !next.Equal(now.Add(time.Minute)) {
+		t.Errorf("first retry: %v %v", next, retry)
+	}
+
+	if tracker.Ready("a", now.Add(30*time.Second)) || !tracker.Ready("a", now.Add(time.Minute)) {
+		t.Errorf("back-off not respected")
+	}
+
+	next, retry = tracker.Failed("a", now)
+	if !retry || !next.Equal(now.Add(2*time.Minute)) || tracker.Attempts("a") != 2 {
+		t.Errorf("second retry: %v %v", next, retry)
+	}
+
+	_, retry = tracker.Failed("a", now)
+	if retry {
+		t.Errorf("retry after last attempt")
+	}
+
+	if tracker.Attempts("a") != 0 || !tracker.Ready("a", now) {
+		t.Errorf("file not forgotten after giving up")
+	}
+}
+
+func TestTrackerNoRetries(t *testing.T) {
+	tracker := NewTracker(Policy{MaxAttempts: 1})
+
+	if _, retry := tracker.Failed("a", time.Now()); retry {
+		t.Errorf("retry although disabled")
+	}
+}
@@ -0,0 +1,25 @@
+// The routing package lets a gateway act as intermediate location (clearing
+// centre) that forwards virtual files whose destination is not the gateway
+// itself (see RFC 5024, 4.3.4 and 4.3.6).
+//
+// The routes of the partner configuration map the destination of a file to
+// the partner it is forwarded to. A file for a routed destination is stored in
+// the outbox of that partner together with its metadata, so it is sent on
+// with the original originator, destination and time stamp. Files for the
+// gateway itself go to the inbox of the partner they came from, files for
+// other destinations are refused.
+//
+// A group destination broadcasts a file to all of its members (see RFC 5024,
+// 4.3.4). Every member gets a copy with itself as destination; the partner
+// the file came from is left out. Files of local applications are broadcast
+// with Inbox.Submit. As an EERP can only be signed by a single destination, a
+// file for a group requesting a signed EERP is answered with a NERP instead.
+//
+// The end to end response for a forwarded file is not sent when the file is
+// received. The file is recorded in spool.Forwards instead, and the response
+// is passed back to the partner the file came from once all onward partners
+// answered with their EERP or NERP. The response is a NERP if any of them
+// answered with a NERP. For a routed file the response of the destination is
+// relayed unchanged, so a signed EERP reaches the originator with its
+// signature.
+package routing
@@ -0,0 +1,285 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/partner"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/spool"
+)
+
+// SFNA reason code for a file with an unknown destination
+const reasonInvalidDestination = 2
+
+// NERP reason codes used for forwarded files
+const (
+	nerpReasonNotDelivered = 35
+	nerpReasonUnspecified  = 99
+)
+
+// signedBroadcastText is the reason given for refusing a signed end to end
+// response for a group
+const signedBroadcastText = "Signed EERP not possible for a group destination."
+
+// Inbox receives the files of a partner and forwards those for routed
+// destinations. It implements client.Inbox.
+type Inbox struct {
+	// From is the Odette ID of the partner the files are received from
+	From string
+
+	// LocalId is the Odette ID of this gateway
+	LocalId string
+
+	// Local stores the files for the gateway itself and refuses those for
+	// unknown destinations
+	Local client.Inbox
+
+	// Config holds the routes and the onward partners
+	Config *partner.Config
+
+	// Forwards records the forwarded files until their end to end response
+	// is passed back
+	Forwards *spool.Forwards
+}
+
+// Create starts a file. A file for a routed destination is written to the
+// outbox of the onward partner, a file for a group destination to the outboxes
+// of all members. All other files are passed to the local inbox.
+func (in *Inbox) Create(file client.VirtualFile) (client.InboxFile, error) {
+	destination := strings.TrimSpace(file.Destination)
+	if strings.EqualFold(destination, in.LocalId) {
+		return in.Local.Create(file)
+	}
+
+	if group, ok := in.Config.Group(destination); ok {
+		return in.broadcast(file, group)
+	}
+
+	onward, ok := in.Config.Route(destination)
+	if !ok {
+		return in.Local.Create(file)
+	}
+
+	// the file must not go back to where it came from
+	if onward.Id == in.From {
+		return nil, &client.FileRefusal{ReasonCode: reasonInvalidDestination, ReasonText: "Invalid destination."}
+	}
+
+	return in.forward(file, []*partner.Profile{onward}, []string{file.Destination})
+}
+
+// Submit passes a file of a local application to the members of a group or
+// the partner of a route, like a file received from a partner. From has to
+// be empty, the end to end response is not passed back to a partner then. A
+// file without originator gets the local Odette ID, a file without time stamp
+// the current time. The request of a signed end to end response cannot be
+// fulfilled for a group and is refused.
+func (in *Inbox) Submit(file client.VirtualFile, r io.Reader) error {
+	if file.Originator == "" {
+		file.Originator = in.LocalId
+	}
+	if file.DateTime.IsZero() {
+		file.DateTime = time.Now()
+	}
+
+	if _, ok := in.Config.Group(file.Destination); ok && file.SignedEERP {
+		return errors.New(fmt.Sprintf("group %s: %s", file.Destination, signedBroadcastText))
+	}
+
+	f, err := in.Create(file)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		_ = f.Abort()
+		return err
+	}
+
+	return f.Commit()
+}
+
+// broadcast starts the copies of a file for all members of the group. The
+// copy for a member has the member as destination. A member reached through
+// the partner the file came from does not get a copy.
+func (in *Inbox) broadcast(file client.VirtualFile, group *partner.Group) (client.InboxFile, error) {
+	if file.SignedEERP {
+		return in.refuseBroadcast(file), nil
+	}
+
+	onward := make([]*partner.Profile, 0, len(group.Members))
+	destinations := make([]string, 0, len(group.Members))
+
+	for _, member := range group.Members {
+		p, ok := in.Config.Destination(member)
+		if !ok || p.Id == in.From {
+			continue
+		}
+		onward = append(onward, p)
+		destinations = append(destinations, member)
+	}
+
+	if len(onward) == 0 {
+		return nil, &client.FileRefusal{ReasonCode: reasonInvalidDestination, ReasonText: "Invalid destination."}
+	}
+
+	return in.forward(file, onward, destinations)
+}
+
+// forward starts the copies of a file in the outboxes of the onward partners,
+// each with its own destination
+func (in *Inbox) forward(file client.VirtualFile, onward []*partner.Profile, destinations []string) (client.InboxFile, error) {
+	f := &forwardFile{
+		forwards: in.Forwards,
+		creator:  in.LocalId,
+		forward: spool.Forward{
+			From:        in.From,
+			DatasetName: file.DatasetName,
+			DateTime:    file.DateTime,
+			UserData:    file.UserData,
+			Destination: file.Destination,
+			Originator:  file.Originator,
+			Legs:        make([]spool.ForwardLeg, 0, len(onward)),
+		},
+	}
+
+	for i, p := range onward {
+		copyOf := file
+		copyOf.Destination = destinations[i]
+
+		outbox := &spool.Inbox{Dir: p.Outbox}
+		c, err := outbox.Create(copyOf)
+		if err != nil {
+			_ = f.Abort()
+			return nil, err
+		}
+
+		f.copies = append(f.copies, c)
+		f.forward.Legs = append(f.forward.Legs, spool.ForwardLeg{Partner: p.Id, Destination: destinations[i]})
+	}
+
+	return f, nil
+}
+
+// refuseBroadcast accepts a file for a group that requests a signed end to
+// end response, but answers it with a NERP instead of broadcasting it (see
+// RFC 5024, 4.3.6). The data is discarded.
+func (in *Inbox) refuseBroadcast(file client.VirtualFile) client.InboxFile {
+	return &forwardFile{
+		forwards: in.Forwards,
+		creator:  in.LocalId,
+		forward: spool.Forward{
+			From:        in.From,
+			DatasetName: file.DatasetName,
+			DateTime:    file.DateTime,
+			Destination: file.Destination,
+			Originator:  file.Originator,
+			Legs: []spool.ForwardLeg{{
+				Destination:   file.Destination,
+				Answered:      true,
+				Negative:      true,
+				CreatorOfNERP: in.LocalId,
+				ReasonCode:    nerpReasonUnspecified,
+				ReasonText:    signedBroadcastText,
+			}},
+		},
+	}
+}
+
+// forwardFile is a file received into the outboxes of the onward partners
+type forwardFile struct {
+	copies   []client.InboxFile
+	forwards *spool.Forwards
+	forward  spool.Forward
+	creator  string
+}
+
+func (f *forwardFile) Write(p []byte) (int, error) {
+	for _, c := range f.copies {
+		if _, err := c.Write(p); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Sync makes the data received so far durable
+func (f *forwardFile) Sync() error {
+	for _, c := range f.copies {
+		syncer, ok := c.(interface{ Sync() error })
+		if !ok {
+			continue
+		}
+		if err := syncer.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit records the forward and releases the copies to the outboxes. The
+// forward is recorded first, so the end to end response of an onward partner
+// cannot arrive before it is known. If no copy can be released, the file is
+// not received. Otherwise the members whose copy failed are answered with a
+// NERP right away.
+func (f *forwardFile) Commit() error {
+	f.forward.Received = time.Now()
+
+	err := f.forwards.Add(f.forward)
+	if err != nil {
+		return err
+	}
+
+	failed := make(map[int]error)
+	for i, c := range f.copies {
+		if err = c.Commit(); err != nil {
+			failed[i] = err
+		}
+	}
+
+	if len(f.copies) > 0 && len(failed) == len(f.copies) {
+		_ = f.forwards.Remove(f.forward)
+		return failed[0]
+	}
+
+	for i, commitErr := range failed {
+		leg := f.forward.Legs[i]
+		_, _, err = f.forwards.Confirm(client.Receipt{
+			DatasetName:   f.forward.DatasetName,
+			DateTime:      f.forward.DateTime,
+			Destination:   f.forward.Originator,
+			Originator:    leg.Destination,
+			CreatorOfNERP: f.creator,
+			Negative:      true,
+			ReasonCode:    nerpReasonNotDelivered,
+			ReasonText:    fmt.Sprintf("cannot store file for %s: %v", leg.Partner, commitErr),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Abort discards all copies
+func (f *forwardFile) Abort() error {
+	var firstErr error
+	for _, c := range f.copies {
+		if err := c.Abort(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// DeferReceipt tells the client not to send the end to end response, it is
+// passed back when the onward partners answered
+func (f *forwardFile) DeferReceipt() bool {
+	return true
+}
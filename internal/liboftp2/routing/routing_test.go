@@ -0,0 +1,237 @@
+package routing
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/partner"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/spool"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "routing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// hub returns the inbox for files from the supplier at a hub that forwards
+// the files for O0013PLANTSUB1 to O0013PLANT and broadcasts the files for
+// O0013ALLPLANTS
+func hub(t *testing.T) *Inbox {
+	dir := tempDir(t)
+
+	config := &partner.Config{
+		Partners: []partner.Profile{
+			{Id: "O0013SUPPLIER", Outbox: filepath.Join(dir, "supplier", "outbox")},
+			{Id: "O0013PLANT", Outbox: filepath.Join(dir, "plant", "outbox")},
+			{Id: "O0013OTHER", Outbox: filepath.Join(dir, "other", "outbox")},
+		},
+		Routes: []partner.Route{
+			{Destination: "O0013PLANTSUB1", Partner: "O0013PLANT"},
+			{Destination: "O0013SUPPLIERSUB", Partner: "O0013SUPPLIER"},
+		},
+		Groups: []partner.Group{
+			{Id: "O0013ALLPLANTS", Members: []string{"O0013PLANTSUB1", "O0013OTHER", "O0013SUPPLIER"}},
+		},
+	}
+
+	forwards, _ := spool.OpenForwards("")
+
+	return &Inbox{
+		From:     "O0013SUPPLIER",
+		LocalId:  "O0013HUB",
+		Local:    &spool.Inbox{Dir: filepath.Join(dir, "supplier", "inbox"), LocalId: "O0013HUB"},
+		Config:   config,
+		Forwards: forwards,
+	}
+}
+
+func TestForwardFile(t *testing.T) {
+	in := hub(t)
+
+	virtualFile := client.VirtualFile{
+		DatasetName: "DELFOR01",
+		DateTime:    time.Date(2020, 12, 17, 10, 22, 34, 0, time.UTC),
+		Destination: "O0013PLANTSUB1",
+		Originator:  "O0013SUPPLIER",
+		Format:      client.FileFormatUnstructured,
+	}
+
+	file, err := in.Create(virtualFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("forecast"))
+
+	if len(in.Forwards.Pending()) != 0 {
+		t.Errorf("forward recorded before the commit")
+	}
+	if err = file.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	deferrer, ok := file.(interface{ DeferReceipt() bool })
+	if !ok || !deferrer.DeferReceipt() {
+		t.Errorf("end to end response of forwarded file not deferred")
+	}
+
+	// the file is waiting in the outbox of the onward partner unchanged
+	plant, _ := in.Config.Partner("O0013PLANT")
+	files, err := (&spool.Outbox{Dir: plant.Outbox, Destination: plant.Id}).Scan()
+	if err != nil || len(files) != 1 {
+		t.Fatalf("file not in outbox: %+v %v", files, err)
+	}
+	forwarded := files[0].Metadata.VirtualFile()
+	if forwarded.DatasetName != "DELFOR01" || !forwarded.DateTime.Equal(virtualFile.DateTime) ||
+		forwarded.Destination != "O0013PLANTSUB1" || forwarded.Originator != "O0013SUPPLIER" {
+		t.Errorf("file changed on the way: %+v", forwarded)
+	}
+
+	pending := in.Forwards.Pending()
+	if len(pending) != 1 || pending[0].From != "O0013SUPPLIER" || len(pending[0].Legs) != 1 || pending[0].Legs[0].Partner != "O0013PLANT" {
+		t.Errorf("wrong forward recorded: %+v", pending)
+	}
+}
+
+func TestLocalAndRefusedFiles(t *testing.T) {
+	in := hub(t)
+
+	file, err := in.Create(client.VirtualFile{DatasetName: "ORDER", Destination: "O0013HUB", Originator: "O0013SUPPLIER"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := file.(interface{ DeferReceipt() bool }); ok {
+		t.Errorf("file for the hub itself treated as forward")
+	}
+	file.Abort()
+
+	tests := []string{"O0013UNKNOWN", "O0013SUPPLIERSUB"}
+	for _, destination := range tests {
+		_, err = in.Create(client.VirtualFile{DatasetName: "ORDER", Destination: destination, Originator: "O0013SUPPLIER"})
+		refusal, ok := err.(*client.FileRefusal)
+		if !ok || refusal.ReasonCode != reasonInvalidDestination {
+			t.Errorf("file for %s not refused: %v", destination, err)
+		}
+	}
+}
+
+// outboxFiles returns the files in the outbox of the partner
+func outboxFiles(t *testing.T, in *Inbox, id string) []spool.OutboxFile {
+	t.Helper()
+
+	p, _ := in.Config.Partner(id)
+	files, err := (&spool.Outbox{Dir: p.Outbox, Destination: p.Id}).Scan()
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestBroadcast(t *testing.T) {
+	in := hub(t)
+	dateTime := time.Date(2020, 12, 17, 10, 22, 34, 0, time.UTC)
+
+	file, err := in.Create(client.VirtualFile{DatasetName: "DELFOR01", DateTime: dateTime, Destination: "O0013ALLPLANTS", Originator: "O0013SUPPLIER"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("forecast"))
+	if err = file.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// every member gets a copy addressed to itself, except the sender
+	for id, destination := range map[string]string{"O0013PLANT": "O0013PLANTSUB1", "O0013OTHER": "O0013OTHER"} {
+		files := outboxFiles(t, in, id)
+		if len(files) != 1 || files[0].Metadata.Destination != destination || files[0].Metadata.Originator != "O0013SUPPLIER" {
+			t.Errorf("wrong copy for %s: %+v", id, files)
+		}
+	}
+	if files := outboxFiles(t, in, "O0013SUPPLIER"); len(files) != 0 {
+		t.Errorf("file sent back to the sender: %+v", files)
+	}
+
+	eerp := client.Receipt{DatasetName: "DELFOR01", DateTime: dateTime, Destination: "O0013SUPPLIER", Originator: "O0013PLANTSUB1"}
+	if _, _, err = in.Forwards.Confirm(eerp); err != nil {
+		t.Fatal(err)
+	}
+	if ready := in.Forwards.Ready("O0013SUPPLIER"); len(ready) != 0 {
+		t.Errorf("receipt ready before all members answered: %+v", ready)
+	}
+
+	nerp := client.Receipt{DatasetName: "DELFOR01", DateTime: dateTime, Destination: "O0013SUPPLIER", Originator: "O0013OTHER",
+		CreatorOfNERP: "O0013OTHER", Negative: true, ReasonCode: 34}
+	if _, _, err = in.Forwards.Confirm(nerp); err != nil {
+		t.Fatal(err)
+	}
+
+	ready := in.Forwards.Ready("O0013SUPPLIER")
+	if len(ready) != 1 {
+		t.Fatalf("no receipt ready: %+v", in.Forwards.Pending())
+	}
+	receipt := ready[0].Receipt()
+	if !receipt.Negative || receipt.ReasonCode != 34 || receipt.CreatorOfNERP != "O0013OTHER" || receipt.Originator != "O0013ALLPLANTS" {
+		t.Errorf("wrong aggregated receipt: %+v", receipt)
+	}
+}
+
+func TestBroadcastSignedEERP(t *testing.T) {
+	in := hub(t)
+
+	file, err := in.Create(client.VirtualFile{DatasetName: "DELFOR01", Destination: "O0013ALLPLANTS", Originator: "O0013SUPPLIER", SignedEERP: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("forecast"))
+	if err = file.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if files := outboxFiles(t, in, "O0013PLANT"); len(files) != 0 {
+		t.Errorf("file broadcast despite signed EERP: %+v", files)
+	}
+
+	ready := in.Forwards.Ready("O0013SUPPLIER")
+	if len(ready) != 1 {
+		t.Fatalf("no NERP ready: %+v", in.Forwards.Pending())
+	}
+	receipt := ready[0].Receipt()
+	if !receipt.Negative || receipt.CreatorOfNERP != "O0013HUB" || receipt.Originator != "O0013ALLPLANTS" {
+		t.Errorf("wrong receipt: %+v", receipt)
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	in := hub(t)
+	in.From = ""
+
+	err := in.Submit(client.VirtualFile{DatasetName: "NOTICE", Destination: "O0013ALLPLANTS"}, strings.NewReader("notice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"O0013PLANT", "O0013OTHER", "O0013SUPPLIER"} {
+		files := outboxFiles(t, in, id)
+		if len(files) != 1 || files[0].Metadata.Originator != "O0013HUB" {
+			t.Errorf("wrong copy for %s: %+v", id, files)
+		}
+	}
+
+	pending := in.Forwards.Pending()
+	if len(pending) != 1 || pending[0].From != "" || len(pending[0].Legs) != 3 {
+		t.Errorf("wrong forward recorded: %+v", pending)
+	}
+
+	err = in.Submit(client.VirtualFile{DatasetName: "NOTICE", Destination: "O0013ALLPLANTS", SignedEERP: true}, strings.NewReader("notice"))
+	if err == nil {
+		t.Errorf("signed EERP accepted for a group")
+	}
+}
@@ -0,0 +1,38 @@
+// The spool package implements the directory based interface between an OFTP2
+// gateway and the applications it serves.
+//
+// Applications drop files to be sent into the outbox of a partner. To avoid
+// sending files that are still being written, files whose names start with a
+// dot or end with .tmp or .part are ignored; write to such a name and rename
+// the file when it is complete. Optionally a sidecar file with the same name
+// plus ".meta.json" describes the virtual file:
+//
+//	{
+//	  "dataset": "DELFOR01",
+//	  "destination": "O0013PARTNER",
+//	  "format": "U",
+//	  "security_level": 0,
+//	  "cipher_suite": 0,
+//	  "compressed": false,
+//	  "enveloped": false,
+//	  "signed_eerp": false,
+//	  "description": "",
+//	  "user_data": "",
+//	  "priority": 0
+//	}
+//
+// Without a sidecar, the upper case file name is used as dataset name and the
+// partner as destination. The sidecar has to be in place before the file itself
+// is dropped. Sent files are moved to the archive directory, files that cannot
+// be sent to the error directory together with a file ending in ".error"
+// containing the reason.
+//
+// Received files are written to the inbox. They appear atomically under their
+// final name after the metadata sidecar has been written, so applications
+// only have to wait for the data file.
+//
+// The Ledger in the spool directory keeps track of the files sent that still
+// wait for their end to end response. Forwards keeps track of the files
+// forwarded as intermediate location until their end to end response was
+// passed back to the partner they came from.
+package spool
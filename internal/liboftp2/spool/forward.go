@@ -0,0 +1,274 @@
+package spool
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+)
+
+// ForwardsFile is the name of the list of forwarded files in the spool
+// directory
+const ForwardsFile = "forwards.json"
+
+// Forward is a file received from a partner and forwarded to other partners
+// as intermediate location. Its end to end response goes back to the partner
+// it came from once all onward partners answered. A file of a local
+// application broadcast to a group is recorded with an empty From.
+type Forward struct {
+	// From is the partner the file was received from, empty for a local file
+	From string `json:"from"`
+
+	DatasetName string    `json:"dataset"`
+	DateTime    time.Time `json:"date_time"`
+	UserData    string    `json:"user_data,omitempty"`
+	Destination string    `json:"destination"`
+	Originator  string    `json:"originator"`
+	Received    time.Time `json:"received"`
+
+	// Legs are the transmissions to the onward partners
+	Legs []ForwardLeg `json:"legs"`
+}
+
+// ForwardLeg is the transmission of a forwarded file to one onward partner
+// together with the end to end response received for it
+type ForwardLeg struct {
+	// Partner the file is forwarded to
+	Partner string `json:"partner"`
+
+	// Destination of the file on this leg
+	Destination string `json:"destination"`
+
+	// Answered is set when the EERP or NERP of the destination arrived
+	Answered bool `json:"answered"`
+
+	// Negative, CreatorOfNERP, ReasonCode and ReasonText describe a NERP
+	Negative      bool   `json:"negative,omitempty"`
+	CreatorOfNERP string `json:"creator_of_nerp,omitempty"`
+	ReasonCode    int    `json:"reason_code,omitempty"`
+	ReasonText    string `json:"reason_text,omitempty"`
+
+	// UserData, FileHash and Signature are those of the end to end response,
+	// they are relayed to the originator unchanged
+	UserData  string `json:"user_data,omitempty"`
+	FileHash  []byte `json:"file_hash,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// answeredBy checks if the receipt is the answer for the leg of the forward.
+// Like in the ledger, the time stamp is compared as it appears on the wire.
+func (f *Forward) answeredBy(leg ForwardLeg, receipt client.Receipt) bool {
+	const layout = "20060102150405"
+
+	return !leg.Answered &&
+		f.DatasetName == receipt.DatasetName &&
+		f.Originator == receipt.Destination &&
+		leg.Destination == receipt.Originator &&
+		f.DateTime.Format(layout) == receipt.DateTime.Format(layout)
+}
+
+// same checks if both forwards are for the same file from the same partner
+func (f *Forward) same(other Forward) bool {
+	return f.From == other.From &&
+		f.DatasetName == other.DatasetName &&
+		f.DateTime.Equal(other.DateTime) &&
+		f.Destination == other.Destination &&
+		f.Originator == other.Originator
+}
+
+// Complete checks if all onward partners answered
+func (f *Forward) Complete() bool {
+	for _, leg := range f.Legs {
+		if !leg.Answered {
+			return false
+		}
+	}
+	return true
+}
+
+// Receipt returns the end to end response for the originator of the file. It
+// comes from the destination of the file, e.g. the group of a broadcast file,
+// and is negative if one of the onward partners answered with a NERP. The
+// response of a routed file with a single destination is relayed as it was
+// received, so a signed EERP reaches the originator with its signature.
+func (f *Forward) Receipt() client.Receipt {
+	if len(f.Legs) == 1 && f.Legs[0].Destination == f.Destination {
+		leg := f.Legs[0]
+		return client.Receipt{
+			DatasetName:   f.DatasetName,
+			DateTime:      f.DateTime,
+			UserData:      leg.UserData,
+			Destination:   f.Originator,
+			Originator:    f.Destination,
+			CreatorOfNERP: leg.CreatorOfNERP,
+			Negative:      leg.Negative,
+			ReasonCode:    leg.ReasonCode,
+			ReasonText:    leg.ReasonText,
+			FileHash:      leg.FileHash,
+			Signature:     leg.Signature,
+		}
+	}
+
+	receipt := client.Receipt{
+		DatasetName: f.DatasetName,
+		DateTime:    f.DateTime,
+		UserData:    f.UserData,
+		Destination: f.Originator,
+		Originator:  f.Destination,
+	}
+
+	for _, leg := range f.Legs {
+		if leg.Negative {
+			receipt.UserData = ""
+			receipt.Negative = true
+			receipt.CreatorOfNERP = leg.CreatorOfNERP
+			receipt.ReasonCode = leg.ReasonCode
+			receipt.ReasonText = leg.ReasonText
+			break
+		}
+	}
+
+	return receipt
+}
+
+// Forwards keeps track of the forwarded files until their end to end response
+// was passed on to the partner they came from. Like the Ledger, it is saved
+// after each change and can be used by several goroutines at the same time.
+type Forwards struct {
+	path    string
+	mutex   sync.Mutex
+	entries []Forward
+}
+
+// OpenForwards reads the forwarded files from the file. If the file does not
+// exist, the list is empty. With an empty path, it is only kept in memory.
+func OpenForwards(path string) (*Forwards, error) {
+	forwards := &Forwards{path: path, entries: make([]Forward, 0)}
+
+	if path == "" {
+		return forwards, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return forwards, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &forwards.entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return forwards, nil
+}
+
+// Add records a forwarded file
+func (f *Forwards) Add(forward Forward) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.entries = append(f.entries, forward)
+	return f.save()
+}
+
+// Remove deletes the forwarded file, e.g. after its end to end response was
+// passed on
+func (f *Forwards) Remove(forward Forward) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for i, e := range f.entries {
+		if e.same(forward) {
+			f.entries = append(f.entries[:i], f.entries[i+1:]...)
+			return f.save()
+		}
+	}
+
+	return nil
+}
+
+// Confirm records the receipt of an onward partner for a forwarded file. The
+// forward is returned, if there was one.
+func (f *Forwards) Confirm(receipt client.Receipt) (Forward, bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for i := range f.entries {
+		e := &f.entries[i]
+		for j, leg := range e.Legs {
+			if !e.answeredBy(leg, receipt) {
+				continue
+			}
+
+			e.Legs[j] = ForwardLeg{
+				Partner:       leg.Partner,
+				Destination:   leg.Destination,
+				Answered:      true,
+				Negative:      receipt.Negative,
+				CreatorOfNERP: receipt.CreatorOfNERP,
+				ReasonCode:    receipt.ReasonCode,
+				ReasonText:    receipt.ReasonText,
+				UserData:      receipt.UserData,
+				FileHash:      receipt.FileHash,
+				Signature:     receipt.Signature,
+			}
+			return e.copy(), true, f.save()
+		}
+	}
+
+	return Forward{}, false, nil
+}
+
+// Ready returns the forwarded files received from the partner whose onward
+// partners all answered, i.e. whose end to end response can be passed on
+func (f *Forwards) Ready(partner string) []Forward {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	result := make([]Forward, 0)
+	for _, e := range f.entries {
+		if e.From == partner && e.Complete() {
+			result = append(result, e.copy())
+		}
+	}
+	return result
+}
+
+// Pending returns a copy of all forwarded files
+func (f *Forwards) Pending() []Forward {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	result := make([]Forward, 0, len(f.entries))
+	for _, e := range f.entries {
+		result = append(result, e.copy())
+	}
+	return result
+}
+
+// copy returns a copy of the forward that does not share the legs
+func (f *Forward) copy() Forward {
+	result := *f
+	result.Legs = make([]ForwardLeg, len(f.Legs))
+	copy(result.Legs, f.Legs)
+	return result
+}
+
+// save writes the forwarded files to their file. The mutex must be held.
+func (f *Forwards) save() error {
+	if f.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(f.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeAtomic(f.path, data)
+}
@@ -0,0 +1,143 @@
+package spool
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+)
+
+// Inbox is the directory received files are stored in. It implements
+// client.Inbox.
+type Inbox struct {
+	// Dir is the inbox directory
+	Dir string
+
+	// LocalId is the Odette ID of this gateway. If it is set, files for other
+	// destinations are refused.
+	LocalId string
+}
+
+// SFNA reason code for a file with an unknown destination
+const reasonInvalidDestination = 2
+
+// Create starts a new file in the inbox. The data is written to a hidden
+// temporary file which is renamed when the file is committed.
+func (in *Inbox) Create(file client.VirtualFile) (client.InboxFile, error) {
+	if in.LocalId != "" && !strings.EqualFold(strings.TrimSpace(file.Destination), in.LocalId) {
+		return nil, &client.FileRefusal{ReasonCode: reasonInvalidDestination, ReasonText: "Invalid destination."}
+	}
+
+	err := os.MkdirAll(in.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := ioutil.TempFile(in.Dir, ".receive.*.part")
+	if err != nil {
+		return nil, err
+	}
+
+	return &inboxFile{inbox: in, file: tmp, virtualFile: file}, nil
+}
+
+// inboxFile is a file currently received into the inbox
+type inboxFile struct {
+	inbox       *Inbox
+	file        *os.File
+	virtualFile client.VirtualFile
+	bytes       uint64
+}
+
+func (f *inboxFile) Write(p []byte) (int, error) {
+	n, err := f.file.Write(p)
+	f.bytes += uint64(n)
+	return n, err
+}
+
+// Sync makes the data received so far durable, it is called before the
+// partner is granted new credit
+func (f *inboxFile) Sync() error {
+	return f.file.Sync()
+}
+
+// Commit makes the data durable, writes the sidecar and moves the file to its
+// final name.
+func (f *inboxFile) Commit() error {
+	err := f.file.Sync()
+	if err == nil {
+		err = f.file.Close()
+	} else {
+		f.file.Close()
+	}
+	if err != nil {
+		os.Remove(f.file.Name())
+		return err
+	}
+
+	target := f.inbox.targetName(f.virtualFile)
+
+	metadata := metadataFor(f.virtualFile)
+	metadata.Bytes = f.bytes
+	metadata.Received = time.Now()
+
+	err = WriteMetadata(target+MetadataSuffix, metadata)
+	if err == nil {
+		err = os.Rename(f.file.Name(), target)
+	}
+
+	if err != nil {
+		os.Remove(f.file.Name())
+		os.Remove(target + MetadataSuffix)
+	}
+
+	return err
+}
+
+// Abort removes the partially received file
+func (f *inboxFile) Abort() error {
+	f.file.Close()
+	return os.Remove(f.file.Name())
+}
+
+// targetName returns an unused name for the received file consisting of the
+// dataset name, the file's time stamp and the originator.
+func (in *Inbox) targetName(file client.VirtualFile) string {
+	base := fmt.Sprintf("%s_%s_%s",
+		safeName(file.DatasetName),
+		file.DateTime.Format("20060102150405"),
+		safeName(file.Originator))
+
+	name := filepath.Join(in.Dir, base)
+	for i := 1; exists(name) || exists(name+MetadataSuffix); i++ {
+		name = filepath.Join(in.Dir, fmt.Sprintf("%s.%d", base, i))
+	}
+
+	return name
+}
+
+// safeName replaces all characters that are not allowed in file names. A
+// leading dot is replaced too, to keep the file visible.
+func safeName(s string) string {
+	result := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ' ' || r == ':' || r < 32 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(s))
+
+	if strings.HasPrefix(result, ".") {
+		result = "_" + result[1:]
+	}
+	return result
+}
+
+// exists checks if a file exists
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,129 @@
+package spool
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+)
+
+// LedgerFile is the name of the ledger in the spool directory
+const LedgerFile = "ledger.json"
+
+// LedgerEntry is a file sent to a partner for which no end to end response has
+// been received yet
+type LedgerEntry struct {
+	Partner     string    `json:"partner"`
+	DatasetName string    `json:"dataset"`
+	DateTime    time.Time `json:"date_time"`
+	Destination string    `json:"destination"`
+	Originator  string    `json:"originator"`
+	Sent        time.Time `json:"sent"`
+}
+
+// matches checks if the receipt is the answer for the file of the entry. The
+// receipt goes back from the destination to the originator of the file. The
+// time stamp is compared as it appears on the wire, i.e. the wall clock up to
+// the second without time zone.
+func (e *LedgerEntry) matches(receipt client.Receipt) bool {
+	const layout = "20060102150405"
+
+	return e.DatasetName == receipt.DatasetName &&
+		e.Destination == receipt.Originator &&
+		e.Originator == receipt.Destination &&
+		e.DateTime.Format(layout) == receipt.DateTime.Format(layout)
+}
+
+// Ledger keeps track of the files waiting for an end to end response (EERP or
+// NERP). It is saved after each change, so no file is forgotten when the
+// gateway is restarted. The ledger can be used by several goroutines at the
+// same time.
+type Ledger struct {
+	path    string
+	mutex   sync.Mutex
+	entries []LedgerEntry
+}
+
+// OpenLedger reads the ledger from the file. If the file does not exist, the
+// ledger is empty. With an empty path, the ledger is only kept in memory.
+func OpenLedger(path string) (*Ledger, error) {
+	ledger := &Ledger{path: path, entries: make([]LedgerEntry, 0)}
+
+	if path == "" {
+		return ledger, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ledger, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &ledger.entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return ledger, nil
+}
+
+// Add records a file sent to the partner
+func (l *Ledger) Add(partner string, file client.VirtualFile, sent time.Time) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.entries = append(l.entries, LedgerEntry{
+		Partner:     partner,
+		DatasetName: file.DatasetName,
+		DateTime:    file.DateTime,
+		Destination: file.Destination,
+		Originator:  file.Originator,
+		Sent:        sent,
+	})
+
+	return l.save()
+}
+
+// Confirm removes the file the receipt answers from the ledger. The entry is
+// returned, if there was one.
+func (l *Ledger) Confirm(receipt client.Receipt) (LedgerEntry, bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for i, e := range l.entries {
+		if e.matches(receipt) {
+			l.entries = append(l.entries[:i], l.entries[i+1:]...)
+			return e, true, l.save()
+		}
+	}
+
+	return LedgerEntry{}, false, nil
+}
+
+// Pending returns a copy of all entries
+func (l *Ledger) Pending() []LedgerEntry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	result := make([]LedgerEntry, len(l.entries))
+	copy(result, l.entries)
+	return result
+}
+
+// save writes the ledger to its file. The mutex must be held.
+func (l *Ledger) save() error {
+	if l.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(l.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeAtomic(l.path, data)
+}
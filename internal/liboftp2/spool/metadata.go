@@ -0,0 +1,131 @@
+package spool
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+)
+
+// MetadataSuffix is appended to the name of a file to get the name of its
+// metadata sidecar
+const MetadataSuffix = ".meta.json"
+
+// Metadata describes a virtual file in the outbox or inbox
+type Metadata struct {
+	DatasetName   string    `json:"dataset"`
+	DateTime      time.Time `json:"date_time,omitempty"`
+	Destination   string    `json:"destination,omitempty"`
+	Originator    string    `json:"originator,omitempty"`
+	Format        string    `json:"format,omitempty"`
+	MaxRecordSize int       `json:"max_record_size,omitempty"`
+	SecurityLevel int       `json:"security_level"`
+	CipherSuite   int       `json:"cipher_suite"`
+	Compressed    bool      `json:"compressed"`
+	Enveloped     bool      `json:"enveloped"`
+	SignedEERP    bool      `json:"signed_eerp"`
+	Description   string    `json:"description,omitempty"`
+	UserData      string    `json:"user_data,omitempty"`
+
+	// Priority of a file in the outbox, files with higher priority are sent
+	// first
+	Priority int `json:"priority,omitempty"`
+
+	// Bytes and Received are set for received files only
+	Bytes    uint64    `json:"bytes,omitempty"`
+	Received time.Time `json:"received,omitempty"`
+}
+
+// VirtualFile converts the metadata into the description of a virtual file
+func (m *Metadata) VirtualFile() client.VirtualFile {
+	return client.VirtualFile{
+		DatasetName:   m.DatasetName,
+		DateTime:      m.DateTime,
+		UserData:      m.UserData,
+		Destination:   m.Destination,
+		Originator:    m.Originator,
+		Format:        client.OFTP2FileFormat(m.Format),
+		MaxRecordSize: m.MaxRecordSize,
+		SecurityLevel: client.OFTP2SecurityLevel(m.SecurityLevel),
+		CipherSuite:   m.CipherSuite,
+		Compressed:    m.Compressed,
+		Enveloped:     m.Enveloped,
+		SignedEERP:    m.SignedEERP,
+		Description:   m.Description,
+	}
+}
+
+// metadataFor creates the metadata of a virtual file
+func metadataFor(file client.VirtualFile) Metadata {
+	return Metadata{
+		DatasetName:   file.DatasetName,
+		DateTime:      file.DateTime,
+		Destination:   file.Destination,
+		Originator:    file.Originator,
+		Format:        string(file.Format),
+		MaxRecordSize: file.MaxRecordSize,
+		SecurityLevel: int(file.SecurityLevel),
+		CipherSuite:   file.CipherSuite,
+		Compressed:    file.Compressed,
+		Enveloped:     file.Enveloped,
+		SignedEERP:    file.SignedEERP,
+		Description:   file.Description,
+		UserData:      file.UserData,
+	}
+}
+
+// ReadMetadata reads a metadata sidecar file
+func ReadMetadata(path string) (Metadata, error) {
+	metadata := Metadata{}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return metadata, err
+	}
+
+	err = json.Unmarshal(data, &metadata)
+	return metadata, err
+}
+
+// WriteMetadata writes a metadata sidecar file. The file is written under a
+// temporary name and renamed afterwards, so it appears atomically.
+func WriteMetadata(path string, metadata Metadata) error {
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeAtomic(path, data)
+}
+
+// writeAtomic writes data to a temporary file in the directory of path, syncs
+// it and renames it to path
+func writeAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package spool
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+)
+
+// maxDatasetNameLength is the length of the SFIDDSN field
+const maxDatasetNameLength = 26
+
+// Outbox is the directory holding files waiting to be sent to a partner
+type Outbox struct {
+	// Dir is the outbox directory
+	Dir string
+
+	// ArchiveDir is the directory sent files are moved to
+	ArchiveDir string
+
+	// ErrorDir is the directory files are moved to that could not be sent
+	ErrorDir string
+
+	// Destination is used for files without destination in their metadata
+	Destination string
+}
+
+// OutboxFile is a file found in the outbox
+type OutboxFile struct {
+	// Path of the file
+	Path string
+
+	// Metadata of the file, taken from the sidecar or derived from the file
+	Metadata Metadata
+
+	// sidecar is the path of the metadata sidecar, empty if there is none
+	sidecar string
+}
+
+// Scan returns the files in the outbox that are ready to be sent, in the order
+// of their names. Files with an unreadable sidecar are moved to the error
+// directory.
+func (o *Outbox) Scan() ([]OutboxFile, error) {
+	entries, err := ioutil.ReadDir(o.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	result := make([]OutboxFile, 0)
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		if !entry.Mode().IsRegular() || ignored(name) || strings.HasSuffix(name, MetadataSuffix) {
+			continue
+		}
+
+		file := OutboxFile{
+			Path: filepath.Join(o.Dir, name),
+			Metadata: Metadata{
+				DatasetName: datasetName(name),
+				DateTime:    entry.ModTime(),
+			},
+		}
+
+		sidecar := file.Path + MetadataSuffix
+		if _, err := os.Stat(sidecar); err == nil {
+			file.sidecar = sidecar
+			file.Metadata, err = ReadMetadata(sidecar)
+			if err == nil && file.Metadata.DatasetName == "" {
+				err = errors.New("no dataset name given")
+			}
+			if err != nil {
+				_ = o.Fail(file, errors.New(fmt.Sprintf("invalid metadata: %v", err)))
+				continue
+			}
+			if file.Metadata.DateTime.IsZero() {
+				file.Metadata.DateTime = entry.ModTime()
+			}
+		}
+
+		if file.Metadata.Destination == "" {
+			file.Metadata.Destination = o.Destination
+		}
+
+		result = append(result, file)
+	}
+
+	return result, nil
+}
+
+// QueuedFile converts the outbox file into a file for the send queue. The path
+// of the file is used as its id.
+func (f *OutboxFile) QueuedFile() (client.QueuedFile, error) {
+	return client.NewQueuedFile(f.Path, f.Metadata.VirtualFile(), f.Metadata.Priority)
+}
+
+// Archive moves a sent file and its sidecar to the archive directory
+func (o *Outbox) Archive(file OutboxFile) error {
+	_, err := moveWithSidecar(file, o.ArchiveDir)
+	return err
+}
+
+// Fail moves a file that could not be sent and its sidecar to the error
+// directory and stores the reason next to it
+func (o *Outbox) Fail(file OutboxFile, reason error) error {
+	target, err := moveWithSidecar(file, o.ErrorDir)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(target+".error", []byte(reason.Error()+"\n"), 0644)
+}
+
+// moveWithSidecar moves the file and its sidecar into dir. The name gets a
+// timestamp prefix to keep files with the same name apart. The new path of the
+// file is returned.
+func moveWithSidecar(file OutboxFile, dir string) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(dir, time.Now().UTC().Format("20060102T150405.000000000")+"_"+filepath.Base(file.Path))
+
+	if file.sidecar != "" {
+		err = os.Rename(file.sidecar, target+MetadataSuffix)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return target, os.Rename(file.Path, target)
+}
+
+// ignored checks if a file name marks a file that is still being written
+func ignored(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".tmp") || strings.HasSuffix(name, ".part")
+}
+
+// datasetName derives a dataset name from a file name. Only upper case
+// letters, digits and the characters allowed by the RFC are kept.
+func datasetName(name string) string {
+	var b strings.Builder
+
+	for _, r := range strings.ToUpper(name) {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', strings.ContainsRune("/-.&()", r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+		if b.Len() == maxDatasetNameLength {
+			break
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,302 @@
+package spool
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	err := ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOutboxScan(t *testing.T) {
+	dir := tempDir(t)
+	outbox := Outbox{
+		Dir:         filepath.Join(dir, "outbox"),
+		ArchiveDir:  filepath.Join(dir, "archive"),
+		ErrorDir:    filepath.Join(dir, "error"),
+		Destination: "O0013PARTNER",
+	}
+	os.MkdirAll(outbox.Dir, 0755)
+
+	writeFile(t, filepath.Join(outbox.Dir, "order 1.txt"), "data")
+	writeFile(t, filepath.Join(outbox.Dir, "invoice"), "data")
+	writeFile(t, filepath.Join(outbox.Dir, "invoice"+MetadataSuffix), `{"dataset": "INVOICE42", "destination": "O0013OTHER", "priority": 5}`)
+	writeFile(t, filepath.Join(outbox.Dir, "broken"), "data")
+	writeFile(t, filepath.Join(outbox.Dir, "broken"+MetadataSuffix), `{"dataset": `)
+	writeFile(t, filepath.Join(outbox.Dir, ".hidden"), "data")
+	writeFile(t, filepath.Join(outbox.Dir, "incomplete.tmp"), "data")
+
+	files, err := outbox.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %d: %+v", len(files), files)
+	}
+
+	if m := files[0].Metadata; m.DatasetName != "INVOICE42" || m.Destination != "O0013OTHER" || m.Priority != 5 {
+		t.Errorf("sidecar not used: %+v", m)
+	}
+
+	if m := files[1].Metadata; m.DatasetName != "ORDER-1.TXT" || m.Destination != "O0013PARTNER" {
+		t.Errorf("wrong default metadata: %+v", m)
+	}
+
+	errorFiles, _ := filepath.Glob(filepath.Join(outbox.ErrorDir, "*_broken*"))
+	if len(errorFiles) != 3 {
+		t.Errorf("broken file not moved to error directory: %v", errorFiles)
+	}
+
+	err = outbox.Archive(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archived, _ := filepath.Glob(filepath.Join(outbox.ArchiveDir, "*_invoice*"))
+	if len(archived) != 2 {
+		t.Errorf("file and sidecar not archived: %v", archived)
+	}
+}
+
+func TestDatasetName(t *testing.T) {
+	tests := map[string]string{
+		"delfor.edi":                   "DELFOR.EDI",
+		"a b_c":                        "A-B-C",
+		"abcdefghijklmnopqrstuvwxyz01": "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	}
+
+	for input, expected := range tests {
+		if got := datasetName(input); got != expected {
+			t.Errorf("datasetName(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestInboxCommit(t *testing.T) {
+	inbox := Inbox{Dir: tempDir(t), LocalId: "O0013LOCAL"}
+
+	virtualFile := client.VirtualFile{
+		DatasetName: "DELFOR01",
+		DateTime:    time.Date(2020, 12, 17, 10, 22, 34, 0, time.UTC),
+		Destination: "O0013LOCAL",
+		Originator:  "O0013PARTNER",
+		Format:      client.FileFormatUnstructured,
+	}
+
+	for i := 0; i < 2; i++ {
+		file, err := inbox.Create(virtualFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file.Write([]byte("hello "))
+		file.Write([]byte("world"))
+
+		// nothing visible before the commit
+		visible, _ := filepath.Glob(filepath.Join(inbox.Dir, "[^.]*"))
+		if len(visible) != 2*i {
+			t.Errorf("files visible before commit: %v", visible)
+		}
+
+		err = file.Commit()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	name := filepath.Join(inbox.Dir, "DELFOR01_20201217102234_O0013PARTNER")
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil || !bytes.Equal(data, []byte("hello world")) {
+		t.Errorf("wrong content %q: %v", data, err)
+	}
+
+	metadata, err := ReadMetadata(name + MetadataSuffix)
+	if err != nil || metadata.Bytes != 11 || metadata.Originator != "O0013PARTNER" {
+		t.Errorf("wrong metadata %+v: %v", metadata, err)
+	}
+
+	if !exists(name + ".1") {
+		t.Errorf("second file not stored under a new name")
+	}
+}
+
+func TestInboxAbortAndRefuse(t *testing.T) {
+	inbox := Inbox{Dir: tempDir(t), LocalId: "O0013LOCAL"}
+
+	file, err := inbox.Create(client.VirtualFile{DatasetName: "X", Destination: "O0013LOCAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("partial"))
+	file.Abort()
+
+	entries, _ := ioutil.ReadDir(inbox.Dir)
+	if len(entries) != 0 {
+		t.Errorf("aborted file not removed")
+	}
+
+	_, err = inbox.Create(client.VirtualFile{DatasetName: "X", Destination: "O0013OTHER"})
+	refusal, ok := err.(*client.FileRefusal)
+	if !ok || refusal.ReasonCode != reasonInvalidDestination {
+		t.Errorf("file for other destination not refused: %v", err)
+	}
+}
+
+func TestLedger(t *testing.T) {
+	path := filepath.Join(tempDir(t), LedgerFile)
+	dateTime := time.Date(2020, 12, 17, 10, 0, 0, 123456789, time.FixedZone("CET", 3600))
+
+	ledger, err := OpenLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := client.VirtualFile{DatasetName: "ORDER", DateTime: dateTime, Destination: "O0013PARTNER", Originator: "O0013LOCAL"}
+	if err = ledger.Add("O0013PARTNER", file, dateTime); err != nil {
+		t.Fatal(err)
+	}
+
+	// the ledger survives a restart
+	ledger, err = OpenLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending := ledger.Pending(); len(pending) != 1 || pending[0].DatasetName != "ORDER" {
+		t.Fatalf("entry not saved: %+v", pending)
+	}
+
+	other := client.Receipt{DatasetName: "ORDER", DateTime: dateTime, Destination: "O0013LOCAL", Originator: "O0013OTHER"}
+	if _, ok, _ := ledger.Confirm(other); ok {
+		t.Errorf("receipt of another partner confirmed the file")
+	}
+
+	// the time stamp of the receipt is parsed from the wire without zone
+	receipt := client.Receipt{DatasetName: "ORDER", DateTime: time.Date(2020, 12, 17, 10, 0, 0, 0, time.UTC), Destination: "O0013LOCAL", Originator: "O0013PARTNER"}
+	entry, ok, err := ledger.Confirm(receipt)
+	if err != nil || !ok || entry.Partner != "O0013PARTNER" {
+		t.Fatalf("receipt not matched: %+v %v %v", entry, ok, err)
+	}
+
+	ledger, _ = OpenLedger(path)
+	if pending := ledger.Pending(); len(pending) != 0 {
+		t.Errorf("confirmed entry still pending: %+v", pending)
+	}
+}
+
+func TestForwards(t *testing.T) {
+	path := filepath.Join(tempDir(t), ForwardsFile)
+	dateTime := time.Date(2020, 12, 17, 10, 0, 0, 0, time.UTC)
+
+	forwards, err := OpenForwards(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forward := Forward{
+		From:        "O0013SUPPLIER",
+		DatasetName: "DELFOR01",
+		DateTime:    dateTime,
+		Destination: "O0013PLANTSUB1",
+		Originator:  "O0013SUPPLIER",
+		Legs:        []ForwardLeg{{Partner: "O0013PLANT", Destination: "O0013PLANTSUB1"}},
+	}
+	if err = forwards.Add(forward); err != nil {
+		t.Fatal(err)
+	}
+
+	// the forwards survive a restart
+	forwards, err = OpenForwards(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ready := forwards.Ready("O0013SUPPLIER"); len(ready) != 0 {
+		t.Errorf("forward ready before the onward partner answered: %+v", ready)
+	}
+
+	other := client.Receipt{DatasetName: "DELFOR01", DateTime: dateTime, Destination: "O0013SUPPLIER", Originator: "O0013OTHER"}
+	if _, ok, _ := forwards.Confirm(other); ok {
+		t.Errorf("receipt of another destination confirmed the forward")
+	}
+
+	nerp := client.Receipt{DatasetName: "DELFOR01", DateTime: dateTime, Destination: "O0013SUPPLIER", Originator: "O0013PLANTSUB1",
+		CreatorOfNERP: "O0013PLANT", Negative: true, ReasonCode: 3, ReasonText: "unknown"}
+	if _, ok, err := forwards.Confirm(nerp); err != nil || !ok {
+		t.Fatalf("receipt not matched: %v %v", ok, err)
+	}
+
+	ready := forwards.Ready("O0013SUPPLIER")
+	if len(ready) != 1 {
+		t.Fatalf("forward not ready: %+v", forwards.Pending())
+	}
+
+	receipt := ready[0].Receipt()
+	if !receipt.Negative || receipt.CreatorOfNERP != "O0013PLANT" || receipt.ReasonCode != 3 ||
+		receipt.Destination != "O0013SUPPLIER" || receipt.Originator != "O0013PLANTSUB1" {
+		t.Errorf("wrong receipt for the originator: %+v", receipt)
+	}
+
+	if err = forwards.Remove(ready[0]); err != nil {
+		t.Fatal(err)
+	}
+	forwards, _ = OpenForwards(path)
+	if pending := forwards.Pending(); len(pending) != 0 {
+		t.Errorf("forward not removed: %+v", pending)
+	}
+}
+
+func TestForwardRelaysSignedReceipt(t *testing.T) {
+	path := filepath.Join(tempDir(t), ForwardsFile)
+	dateTime := time.Date(2020, 12, 17, 10, 0, 0, 0, time.UTC)
+
+	forwards, _ := OpenForwards(path)
+	err := forwards.Add(Forward{
+		From:        "O0013SUPPLIER",
+		DatasetName: "DELFOR01",
+		DateTime:    dateTime,
+		UserData:    "FILE",
+		Destination: "O0013PLANTSUB1",
+		Originator:  "O0013SUPPLIER",
+		Legs:        []ForwardLeg{{Partner: "O0013PLANT", Destination: "O0013PLANTSUB1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eerp := client.Receipt{DatasetName: "DELFOR01", DateTime: dateTime, UserData: "EERP", Destination: "O0013SUPPLIER",
+		Originator: "O0013PLANTSUB1", FileHash: []byte{1, 2, 3}, Signature: []byte{4, 5, 6}}
+	if _, ok, err := forwards.Confirm(eerp); err != nil || !ok {
+		t.Fatalf("receipt not matched: %v %v", ok, err)
+	}
+
+	// the signed EERP of the destination survives a restart and is relayed
+	forwards, _ = OpenForwards(path)
+	ready := forwards.Ready("O0013SUPPLIER")
+	if len(ready) != 1 {
+		t.Fatalf("forward not ready: %+v", forwards.Pending())
+	}
+	if receipt := ready[0].Receipt(); !reflect.DeepEqual(receipt, eerp) {
+		t.Errorf("EERP not relayed unchanged:\n%+v\n%+v", receipt, eerp)
+	}
+}
@@ -0,0 +1,22 @@
+// The trace package records the exchange buffers of an OFTP2 session and
+// replays recorded sessions against a client.
+//
+// A trace file uses the JSON lines format: every line contains one JSON object
+// describing a single exchange buffer as it went over the wire (without the
+// Stream Transmission Header):
+//
+//	{"time":"2020-12-17T10:22:34.345678912Z","dir":"out","cmd":"X","data":"WDVPMDAxMz..."}
+//
+//	time   time the buffer was sent or received (RFC 3339 with nanoseconds)
+//	dir    "out" for buffers sent by the recording side, "in" for buffers
+//	       received by the recording side
+//	cmd    the command code, i.e. the first octet of the buffer
+//	data   the complete exchange buffer, base64 encoded
+//
+// Unknown attributes are ignored when reading a trace, so the format can be
+// extended later on.
+//
+// A recorded trace can be replayed with a Replayer, which takes the role of the
+// recorded partner: it sends all buffers the recording side received and
+// expects the buffers the recording side sent.
+package trace
@@ -0,0 +1,80 @@
+package trace
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+)
+
+// Replayer takes the role of the partner of a recorded session. Buffers the
+// recording side received are sent to the connection, buffers the recording
+// side sent are expected from the connection.
+type Replayer struct {
+	// Records of the trace to replay
+	Records []Record
+
+	// Strict compares the complete buffers received with the recorded ones.
+	// Otherwise, only the command codes are compared because most buffers
+	// contain time stamps or other volatile data.
+	Strict bool
+}
+
+// NewReplayer creates a replayer for the given trace.
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	records, err := ReadTrace(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Replayer{Records: records}, nil
+}
+
+// ServeConn replays the trace on the given connection. It returns nil if the
+// whole trace could be replayed and the connection behaved as recorded,
+// otherwise an error describing the first deviation.
+func (r *Replayer) ServeConn(conn io.ReadWriter) error {
+	for i, record := range r.Records {
+		switch record.Direction {
+		case Inbound:
+			_, err := conn.Write(wire.EncodeStreamBuffer(record.Data))
+			if err != nil {
+				return errors.New(fmt.Sprintf("record %d: cannot send %s: %v", i+1, record.Command, err))
+			}
+
+		case Outbound:
+			buffer, err := wire.ReadStreamBuffer(conn)
+			if err != nil {
+				return errors.New(fmt.Sprintf("record %d: expected %s, got error: %v", i+1, record.Command, err))
+			}
+
+			if err = r.compare(record, buffer); err != nil {
+				return errors.New(fmt.Sprintf("record %d: %v", i+1, err))
+			}
+		}
+	}
+
+	return nil
+}
+
+// compare checks if the received buffer matches the recorded one.
+func (r *Replayer) compare(record Record, received []byte) error {
+	if len(received) == 0 || len(record.Data) == 0 {
+		if len(received) != len(record.Data) {
+			return errors.New(fmt.Sprintf("expected %d bytes, got %d", len(record.Data), len(received)))
+		}
+		return nil
+	}
+
+	if received[0] != record.Data[0] {
+		return errors.New(fmt.Sprintf("expected command %s, got %s", record.Command, string(received[0:1])))
+	}
+
+	if r.Strict && !bytes.Equal(received, record.Data) {
+		return errors.New(fmt.Sprintf("buffer for command %s differs from recording", record.Command))
+	}
+
+	return nil
+}
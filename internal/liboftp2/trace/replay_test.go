@@ -0,0 +1,66 @@
+package trace
+
+import (
+	"net"
+	"testing"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+)
+
+func testRecords() []Record {
+	return []Record{
+		{Direction: Inbound, Command: "I", Data: []byte("IODETTE FTP READY \n")},
+		{Direction: Outbound, Command: "F", Data: []byte("F00002OK\n")},
+	}
+}
+
+func TestReplayer_ServeConn(t *testing.T) {
+	client, partner := net.Pipe()
+	defer client.Close()
+
+	r := Replayer{Records: testRecords(), Strict: true}
+
+	result := make(chan error)
+	go func() {
+		result <- r.ServeConn(partner)
+		partner.Close()
+	}()
+
+	buffer, err := wire.ReadStreamBuffer(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buffer) != "IODETTE FTP READY \n" {
+		t.Errorf("expected SSRM, got %s", string(buffer))
+	}
+
+	_, err = client.Write(wire.EncodeStreamBuffer([]byte("F00002OK\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = <-result; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplayer_ServeConn_Deviation(t *testing.T) {
+	client, partner := net.Pipe()
+	defer client.Close()
+
+	r := Replayer{Records: testRecords()}
+
+	result := make(chan error)
+	go func() {
+		result <- r.ServeConn(partner)
+		partner.Close()
+	}()
+
+	_, _ = wire.ReadStreamBuffer(client)
+	_, _ = client.Write(wire.EncodeStreamBuffer([]byte("R")))
+
+	if err := <-result; err == nil {
+		t.Errorf("expected deviation to be reported")
+	}
+}
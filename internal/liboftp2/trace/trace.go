@@ -0,0 +1,109 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Direction of an exchange buffer, seen from the recording side
+type Direction string
+
+const (
+	// Inbound marks a buffer received from the partner
+	Inbound Direction = "in"
+
+	// Outbound marks a buffer sent to the partner
+	Outbound Direction = "out"
+)
+
+// Record is a single exchange buffer in a trace
+type Record struct {
+	Time      time.Time `json:"time"`
+	Direction Direction `json:"dir"`
+	Command   string    `json:"cmd"`
+	Data      []byte    `json:"data"`
+}
+
+// Recorder writes exchange buffers to a trace. It is safe for concurrent use.
+type Recorder struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+
+	// Redact is applied to every buffer before it is written to the trace and
+	// can be used to remove confidential data, e.g. passwords. The function
+	// must not modify the buffer passed to it.
+	Redact func(data []byte) []byte
+}
+
+// NewRecorder creates a new recorder writing the trace to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{encoder: json.NewEncoder(w)}
+}
+
+// Record writes a single exchange buffer with the current time to the trace.
+// Calling Record on a nil Recorder does nothing, so the recorder can be used
+// without checking if tracing is enabled.
+func (r *Recorder) Record(direction Direction, data []byte) error {
+	if r == nil {
+		return nil
+	}
+
+	if r.Redact != nil {
+		data = r.Redact(data)
+	}
+
+	record := Record{
+		Time:      time.Now(),
+		Direction: direction,
+		Data:      data,
+	}
+
+	if len(data) > 0 {
+		record.Command = string(data[0:1])
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.encoder.Encode(&record)
+}
+
+// ReadTrace reads all records of a trace.
+func ReadTrace(r io.Reader) ([]Record, error) {
+	result := make([]Record, 0)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		record := Record{}
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("line %d: %v", line, err))
+		}
+
+		if record.Direction != Inbound && record.Direction != Outbound {
+			return nil, errors.New(fmt.Sprintf("line %d: unknown direction %q", line, record.Direction))
+		}
+
+		result = append(result, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
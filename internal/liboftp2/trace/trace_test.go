@@ -0,0 +1,63 @@
+package trace
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecorder_RoundTrip(t *testing.T) {
+	var out bytes.Buffer
+
+	r := NewRecorder(&out)
+	_ = r.Record(Inbound, []byte("IODETTE FTP READY \n"))
+	_ = r.Record(Outbound, []byte("R"))
+
+	records, err := ReadTrace(&out)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	if records[0].Direction != Inbound || records[0].Command != "I" || string(records[0].Data) != "IODETTE FTP READY \n" {
+		t.Errorf("wrong first record: %v", records[0])
+	}
+
+	if records[1].Direction != Outbound || records[1].Command != "R" || !reflect.DeepEqual(records[1].Data, []byte("R")) {
+		t.Errorf("wrong second record: %v", records[1])
+	}
+}
+
+func TestRecorder_Redact(t *testing.T) {
+	var out bytes.Buffer
+
+	r := NewRecorder(&out)
+	r.Redact = func(data []byte) []byte {
+		return []byte("Xsecret removed")
+	}
+	_ = r.Record(Outbound, []byte("Xsecret"))
+
+	if strings.Contains(out.String(), "WHNlY3JldA") {
+		t.Errorf("trace contains unredacted data: %s", out.String())
+	}
+}
+
+func TestRecorder_Nil(t *testing.T) {
+	var r *Recorder
+
+	err := r.Record(Outbound, []byte("R"))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadTrace_InvalidDirection(t *testing.T) {
+	_, err := ReadTrace(strings.NewReader(`{"dir":"sideways","data":"Ug=="}`))
+	if err == nil {
+		t.Errorf("expected error for unknown direction")
+	}
+}
@@ -50,8 +50,8 @@ type AUCH struct {
 // AUCHCMD is the command indicator for the AUCH command.
 const AUCHCMD = "A"
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *AUCH) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *AUCH) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `AUCHCMD`, &[]wire.Value{{AUCHCMD, "AUCH Command"}}},
 		{`V U(2)`, `AUCHCHLL`, nil},
@@ -62,7 +62,7 @@ func (s *AUCH) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *AUCH) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *AUCH) Command() wire.Command {
@@ -86,5 +86,5 @@ func (s *AUCH) Parse(input []byte) error {
 	lenChallenge := buffer.GetBinWord(2)
 	s.Challenge = buffer.GetBytes(int(lenChallenge))
 
-	return nil
+	return buffer.Err()
 }
@@ -45,8 +45,8 @@ type AURP struct {
 // AURPCMD is the command indicator for the AURP command.
 const AURPCMD = "S"
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *AURP) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *AURP) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `AURPCMD`, &[]wire.Value{{AURPCMD, "AURP Command"}}},
 		{`V U(20)`, `AURPRSP`, nil},
@@ -56,7 +56,7 @@ func (s *AURP) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *AURP) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *AURP) Command() wire.Command {
@@ -79,7 +79,7 @@ func (s *AURP) Parse(input []byte) error {
 
 	s.Response = buffer.GetBytes(20)
 
-	return nil
+	return buffer.Err()
 }
 
 func ToAURP(data *interface{}) *AURP {
@@ -29,8 +29,8 @@ type SECD struct {
 // SECDCMD is the command indicator for the SECD command.
 const SECDCMD = "J"
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *SECD) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *SECD) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `SECDCMD`, &[]wire.Value{{SECDCMD, "SECD Command"}}},
 	}
@@ -39,7 +39,7 @@ func (s *SECD) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *SECD) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *SECD) Command() wire.Command {
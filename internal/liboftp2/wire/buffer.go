@@ -11,9 +11,13 @@ import (
 // Buffer represents data received over the network. It offers method to parse
 // data from the buffer into other formats. Especially the exotic OFTP2 encodings
 // are supported.
+//
+// Reading beyond the end of the data does not panic. The missing data is
+// returned as empty value and the first such error is kept, see Err.
 type Buffer struct {
 	data *[]byte
 	pos  int
+	err  error
 }
 
 // NewBuffer creates a new buffer for the given data and checks if the buffer
@@ -33,7 +37,10 @@ func NewBuffer(data *[]byte, marker string) (*Buffer, error) {
 // marker. If not, an error is returned, otherwise nil.
 func (b *Buffer) checkMarker(marker string) error {
 	m := b.GetString(1)
-	if marker != marker {
+	if b.err != nil {
+		return b.err
+	}
+	if m != marker {
 		return errors.New(fmt.Sprintf("wrong marker; found %s, expected %s", m, marker))
 	} else {
 		return nil
@@ -58,12 +65,15 @@ func (b *Buffer) GetNumInt(len int) int {
 	return intValue
 }
 
-// GetBinWord gets an uint16 from the buffer at the current position with the length len.
+// GetBinWord gets an uint16 from the buffer at the current position with the length length.
 // The position is afterwards incremented, so that it points to the next data
 // portion in the input array. The integer value is expected to be encoded in
 // network byte order, e.g. 0xba 0xbe for 0xbabe.
-func (b *Buffer) GetBinWord(len int) uint16 {
-	bytes := b.GetBytes(len)
+func (b *Buffer) GetBinWord(length int) uint16 {
+	bytes := b.GetBytes(length)
+	if len(bytes) < 2 {
+		return 0
+	}
 	return binary.BigEndian.Uint16(bytes)
 }
 
@@ -98,10 +108,29 @@ func (b *Buffer) GetBool(len int) bool {
 }
 
 // GetBytes gets the raw bytes from the input byte array at position pos of length
-// len. The position is afterwards incremented, so that it points to the next
+// length. The position is afterwards incremented, so that it points to the next
 // data portion in the input array.
-func (b *Buffer) GetBytes(len int) []byte {
-	result := (*b.data)[b.pos : b.pos+len]
-	b.pos += len
+//
+// If the data is too short or the length is negative, an empty slice is
+// returned and the error is kept (see Err). The position is moved to the end of
+// the data in this case.
+func (b *Buffer) GetBytes(length int) []byte {
+	available := len(*b.data) - b.pos
+	if length < 0 || length > available {
+		if b.err == nil {
+			b.err = errors.New(fmt.Sprintf("cannot read %d bytes at offset %d, %d bytes available", length, b.pos, available))
+		}
+		b.pos += available
+		return []byte{}
+	}
+
+	result := (*b.data)[b.pos : b.pos+length]
+	b.pos += length
 	return result
 }
+
+// Err returns the first error that occurred while reading from the buffer,
+// e.g. because the data was too short
+func (b *Buffer) Err() error {
+	return b.err
+}
@@ -27,6 +27,7 @@ type CD struct {
 // CDCMD is the command indicator for the CD command.
 const CDCMD = "R"
 
+// FormatDefinition returns the format definition as given in the RFC5024
 func (s *CD) FormatDefinition() []FormatDefinition {
 	return []FormatDefinition{
 		{`F X(1)`, `CDCMD`, &[]Value{{CDCMD, "CD Command"}}},
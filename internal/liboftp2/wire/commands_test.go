@@ -0,0 +1,140 @@
+package wire_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/authentication"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/endfile"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/startfile"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/transfer"
+)
+
+// command is implemented by all commands of the wire packages
+type command interface {
+	Parse([]byte) error
+	Marshal() []byte
+}
+
+var fileDate = time.Date(2020, 12, 17, 10, 11, 12, 0, time.UTC)
+
+// commands lists for each command how to create an empty one and valid
+// commands used as seeds for the fuzz targets
+var commands = map[string]struct {
+	empty func() command
+	seeds []command
+}{
+	"CD":   {func() command { return &wire.CD{} }, []command{&wire.CD{}}},
+	"SSRM": {func() command { return &session.SSRM{} }, []command{&session.SSRM{}}},
+	"SSID": {func() command { return &session.SSID{} }, []command{
+		&session.SSID{Id: "O0013000000TEST", Password: "SECRET", BufferSize: 4096, Capability: "B",
+			Compress: true, Restart: true, Special: false, Credit: 8, Authentication: true, UserData: "USER"},
+	}},
+	"ESID": {func() command { return &session.ESID{} }, []command{
+		&session.ESID{ReasonCode: 4, ReasonText: "Incompatible mode"},
+		&session.ESID{ReasonCode: 0},
+	}},
+	"SFID": {func() command { return &startfile.SFID{} }, []command{
+		&startfile.SFID{DatasetName: "TEST.FILE", FileDateTime: fileDate, UserData: "USER",
+			Destination: "O0013000000DEST", Originator: "O0013000000ORIG", FileFormat: "V", MaxRecordSize: 80,
+			FileSizeInK: 12, OriginalFileSizeInK: 20, RestartPosition: 3, SecurityLevel: 3, CipherSuite: 1,
+			Compression: 1, Envelope: 1, SigningRequired: true, VirtualFileDescription: "Description"},
+	}},
+	"SFPA": {func() command { return &startfile.SFPA{} }, []command{&startfile.SFPA{AnswerCount: 42}}},
+	"SFNA": {func() command { return &startfile.SFNA{} }, []command{
+		&startfile.SFNA{ReasonCode: 3, RetryIndicator: true, ReasonText: "Refused"},
+	}},
+	"EERP": {func() command { return &startfile.EERP{} }, []command{
+		&startfile.EERP{VirtualDataSetName: "TEST.FILE", VirtualFileDate: fileDate, UserData: "USER",
+			Destination: "O0013000000DEST", Originator: "O0013000000ORIG",
+			FileHash: []byte{0x01, 0x02}, Signature: []byte{0x03, 0x04, 0x05}},
+	}},
+	"NERP": {func() command { return &startfile.NERP{} }, []command{
+		&startfile.NERP{VirtualDataSetName: "TEST.FILE", VirtualFileDate: fileDate,
+			Destination: "O0013000000DEST", Originator: "O0013000000ORIG", CreatorOfNERP: "O0013000000HUB",
+			ReasonCode: 5, ReasonText: "Invalid destination", FileHash: []byte{0x01}, Signature: []byte{0x02}},
+	}},
+	"RTR": {func() command { return &startfile.RTR{} }, []command{&startfile.RTR{}}},
+	"DATA": {func() command { return &transfer.DATA{} }, []command{
+		&transfer.DATA{Length: 4, Buffer: []byte{0x03, 'a', 'b', 'c'}},
+	}},
+	"CDT":  {func() command { return &transfer.CDT{} }, []command{&transfer.CDT{}}},
+	"EFID": {func() command { return &endfile.EFID{} }, []command{&endfile.EFID{RecordCount: 7, UnitCount: 1024}}},
+	"EFPA": {func() command { return &endfile.EFPA{} }, []command{
+		&endfile.EFPA{ChangeDirection: true},
+		&endfile.EFPA{ChangeDirection: false},
+	}},
+	"EFNA": {func() command { return &endfile.EFNA{} }, []command{
+		&endfile.EFNA{ReasonCode: 4, AnswerText: "Invalid record count"},
+	}},
+	"SECD": {func() command { return &authentication.SECD{} }, []command{&authentication.SECD{}}},
+	"AUCH": {func() command { return &authentication.AUCH{} }, []command{
+		&authentication.AUCH{Challenge: []byte("A very secret challenge")},
+	}},
+	"AURP": {func() command { return &authentication.AURP{} }, []command{
+		&authentication.AURP{Response: []byte("An answer of 20 byte")},
+	}},
+}
+
+func TestParseMarshal(t *testing.T) {
+	for name, c := range commands {
+		for _, seed := range c.seeds {
+			parsed := c.empty()
+			if err := parsed.Parse(seed.Marshal()); err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			if !reflect.DeepEqual(parsed, seed) {
+				t.Errorf("%s: round trip failed: %+v != %+v", name, parsed, seed)
+			}
+		}
+	}
+}
+
+// fuzzParse checks that the parser of the command does not panic and that a
+// parsed command is parsed again unchanged after it was marshalled
+func fuzzParse(f *testing.F, name string) {
+	c := commands[name]
+	for _, seed := range c.seeds {
+		f.Add(seed.Marshal())
+	}
+	f.Add(c.empty().Marshal())
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		parsed := c.empty()
+		if err := parsed.Parse(input); err != nil {
+			return
+		}
+
+		again := c.empty()
+		if err := again.Parse(parsed.Marshal()); err != nil {
+			t.Fatalf("marshalled %s not parsed: %v", name, err)
+		}
+		if !reflect.DeepEqual(again, parsed) {
+			t.Errorf("round trip failed: %+v != %+v", again, parsed)
+		}
+	})
+}
+
+func FuzzCD(f *testing.F)   { fuzzParse(f, "CD") }
+func FuzzSSRM(f *testing.F) { fuzzParse(f, "SSRM") }
+func FuzzSSID(f *testing.F) { fuzzParse(f, "SSID") }
+func FuzzESID(f *testing.F) { fuzzParse(f, "ESID") }
+func FuzzSFID(f *testing.F) { fuzzParse(f, "SFID") }
+func FuzzSFPA(f *testing.F) { fuzzParse(f, "SFPA") }
+func FuzzSFNA(f *testing.F) { fuzzParse(f, "SFNA") }
+func FuzzEERP(f *testing.F) { fuzzParse(f, "EERP") }
+func FuzzNERP(f *testing.F) { fuzzParse(f, "NERP") }
+func FuzzRTR(f *testing.F)  { fuzzParse(f, "RTR") }
+func FuzzDATA(f *testing.F) { fuzzParse(f, "DATA") }
+func FuzzCDT(f *testing.F)  { fuzzParse(f, "CDT") }
+func FuzzEFID(f *testing.F) { fuzzParse(f, "EFID") }
+func FuzzEFPA(f *testing.F) { fuzzParse(f, "EFPA") }
+func FuzzEFNA(f *testing.F) { fuzzParse(f, "EFNA") }
+func FuzzSECD(f *testing.F) { fuzzParse(f, "SECD") }
+func FuzzAUCH(f *testing.F) { fuzzParse(f, "AUCH") }
+func FuzzAURP(f *testing.F) { fuzzParse(f, "AURP") }
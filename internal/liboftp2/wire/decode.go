@@ -0,0 +1,118 @@
+package wire
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Field is a single field of an exchange buffer, decoded along the format
+// definition of the command.
+type Field struct {
+	// Name of the field as given in the specification, e.g. SFIDDSN
+	Name string
+
+	// Format of the field
+	Format DataFormat
+
+	// Raw bytes of the field as found in the buffer
+	Raw []byte
+
+	// Value is a printable representation of the field content
+	Value string
+
+	// Meaning is the description of the value taken from the value tables of
+	// the specification. It is empty if the field has no value table or the
+	// value is not contained in it.
+	Meaning string
+}
+
+// DecodeFields splits the raw exchange buffer input into the fields described by
+// definitions. Fields of variable length (e.g. T(n)) take their length from the
+// numeric or binary field preceding them, otherwise they extend to the end of
+// the buffer. If the buffer is shorter than required, the fields decoded so far
+// are returned together with an error.
+func DecodeFields(definitions []FormatDefinition, input []byte) ([]Field, error) {
+	result := make([]Field, 0)
+	pos := 0
+
+	var previous *Field
+
+	for _, definition := range definitions {
+		format := definition.ToDataFormat()
+		length := format.Length
+
+		if length == -1 {
+			length = len(input) - pos
+
+			if previous != nil && (previous.Format.DataType == DataTypeNumeric || previous.Format.DataType == DataTypeBinary) {
+				announced, err := strconv.Atoi(previous.Value)
+				if err == nil {
+					length = announced
+				}
+			}
+		}
+
+		if pos+length > len(input) {
+			return result, errors.New(fmt.Sprintf("buffer too short for field %s: need %d bytes at offset %d, have %d",
+				strings.TrimSpace(definition.FieldName), length, pos, len(input)-pos))
+		}
+
+		raw := input[pos : pos+length]
+		pos += length
+
+		field := Field{
+			Name:   strings.TrimSpace(definition.FieldName),
+			Format: format,
+			Raw:    raw,
+			Value:  fieldValue(format, raw),
+		}
+
+		if format.PossibleValues != nil {
+			for _, v := range *format.PossibleValues {
+				if v.Name == string(raw) || strings.TrimSpace(v.Name) == field.Value {
+					field.Meaning = v.Description
+					break
+				}
+			}
+		}
+
+		result = append(result, field)
+		previous = &result[len(result)-1]
+	}
+
+	if pos < len(input) {
+		return result, errors.New(fmt.Sprintf("%d unexpected bytes after last field", len(input)-pos))
+	}
+
+	return result, nil
+}
+
+// fieldValue converts the raw content of a field into a printable string.
+func fieldValue(format DataFormat, raw []byte) string {
+	switch format.DataType {
+	case DataTypeBinary:
+		switch len(raw) {
+		case 2:
+			if format.Length == 2 {
+				return strconv.FormatUint(uint64(binary.BigEndian.Uint16(raw)), 10)
+			}
+		case 4:
+			if format.Length == 4 {
+				return strconv.FormatUint(uint64(binary.BigEndian.Uint32(raw)), 10)
+			}
+		case 8:
+			if format.Length == 8 {
+				return strconv.FormatUint(binary.BigEndian.Uint64(raw), 10)
+			}
+		}
+		return hex.EncodeToString(raw)
+	case DataTypeNumeric:
+		return strings.TrimSpace(string(raw))
+	default:
+		return strings.TrimRight(string(raw), " ")
+	}
+}
@@ -0,0 +1,63 @@
+package wire
+
+import (
+	"testing"
+)
+
+func TestDecodeFields(t *testing.T) {
+	definitions := []FormatDefinition{
+		{`F X(1)`, `XXXCMD`, &[]Value{{"Z", "XXX Command"}}},
+		{`F 9(2)`, `XXXREAS`, IntMapToValues(map[int]string{3: "Three"}, 2)},
+		{`V 9(3)`, `XXXREASL`, nil},
+		{`V T(n)`, `XXXREAST`, nil},
+		{`V U(2)`, `XXXHSHL`, nil},
+		{`V U(n)`, `XXXHSH`, nil},
+	}
+
+	input := []byte("Z03005HELLO\x00\x02\xca\xfe")
+
+	fields, err := DecodeFields(definitions, input)
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []struct {
+		name    string
+		value   string
+		meaning string
+	}{
+		{"XXXCMD", "Z", "XXX Command"},
+		{"XXXREAS", "03", "Three"},
+		{"XXXREASL", "005", ""},
+		{"XXXREAST", "HELLO", ""},
+		{"XXXHSHL", "2", ""},
+		{"XXXHSH", "cafe", ""},
+	}
+
+	if len(fields) != len(expected) {
+		t.Fatalf("expected %d fields, got %d", len(expected), len(fields))
+	}
+
+	for i, e := range expected {
+		f := fields[i]
+		if f.Name != e.name || f.Value != e.value || f.Meaning != e.meaning {
+			t.Errorf("expected %s=%s (%s), got %s=%s (%s)", e.name, e.value, e.meaning, f.Name, f.Value, f.Meaning)
+		}
+	}
+}
+
+func TestDecodeFields_Truncated(t *testing.T) {
+	definitions := []FormatDefinition{
+		{`F X(1)`, `XXXCMD`, nil},
+		{`V X(8)`, `XXXUSER`, nil},
+	}
+
+	fields, err := DecodeFields(definitions, []byte("ZABC"))
+	if err == nil {
+		t.Errorf("expected error for truncated buffer")
+	}
+
+	if len(fields) != 1 {
+		t.Errorf("expected 1 field, got %d", len(fields))
+	}
+}
@@ -53,8 +53,8 @@ type EFID struct {
 // EFIDCMD is the command indicator for the EFID command.
 const EFIDCMD = "T"
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *EFID) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *EFID) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `EFIDCMD`, &[]wire.Value{{EFIDCMD, "EFID Command"}}},
 		{`V 9(17)`, `EFIDRCNT`, nil},
@@ -65,7 +65,7 @@ func (s *EFID) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *EFID) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *EFID) Command() wire.Command {
@@ -89,5 +89,5 @@ func (s *EFID) Parse(input []byte) error {
 	s.RecordCount = buffer.GetBinQWord(17)
 	s.UnitCount = buffer.GetBinQWord(17)
 
-	return nil
+	return buffer.Err()
 }
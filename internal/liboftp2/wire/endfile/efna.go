@@ -112,8 +112,8 @@ var valuesEFNAREAS = map[int]string{
 	23: "File decompression failure.",
 }
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *EFNA) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *EFNA) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `EFNACMD`, &[]wire.Value{{EFNACMD, "EFNA Command"}}},
 		{`F 9(2)`, `EFNAREAS`, wire.IntMapToValues(valuesEFNAREAS, 2)},
@@ -125,7 +125,7 @@ func (s *EFNA) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *EFNA) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *EFNA) Marshal() []byte {
@@ -143,9 +143,15 @@ func (s *EFNA) Parse(input []byte) error {
 	lenAnswerText := buffer.GetNumInt(3)
 	s.AnswerText = buffer.GetString(lenAnswerText)
 
-	return nil
+	return buffer.Err()
 }
 
 func (s *EFNA) String() string {
 	return fmt.Sprintf("EFNA - End File Negative Answer. Reason Code: %d (%s), Text: %s", s.ReasonCode, valuesEFNAREAS[s.ReasonCode], s.AnswerText)
 }
+
+// EFNAReasonText returns the description of an EFNA reason code as given in
+// the RFC. It is empty for unknown codes.
+func EFNAReasonText(reasonCode int) string {
+	return valuesEFNAREAS[reasonCode]
+}
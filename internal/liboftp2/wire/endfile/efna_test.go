@@ -22,3 +22,12 @@ func TestEFNA_RoundTrip(t *testing.T) {
 		t.Errorf("Roundtrip failed: %v != %v", a1, a2)
 	}
 }
+
+func TestEFNAReasonText(t *testing.T) {
+	if text := EFNAReasonText(11); text != "Invalid byte count." {
+		t.Errorf("wrong text for 11: %s", text)
+	}
+	if text := EFNAReasonText(42); text != "" {
+		t.Errorf("text for unknown code: %s", text)
+	}
+}
@@ -47,8 +47,8 @@ func (s *EFPA) Command() wire.Command {
 	}
 }
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *EFPA) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *EFPA) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `EFPACMD`, &[]wire.Value{{EFPACMD, "EFPA Command"}}},
 		{`F X(1)`, `EFPACD`, wire.ValueBooleanYesNo},
@@ -58,7 +58,7 @@ func (s *EFPA) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *EFPA) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *EFPA) Marshal() []byte {
@@ -74,5 +74,5 @@ func (s *EFPA) Parse(input []byte) error {
 
 	s.ChangeDirection = buffer.GetBool(1)
 
-	return nil
+	return buffer.Err()
 }
@@ -13,4 +13,9 @@ type Protocol interface {
 
 	// Marshal converts the OFTP2 command into a byte array.
 	Marshal() []byte
+
+	// FormatDefinition returns the format definition of the command as given in
+	// the RFC5024. The field names and value tables can be used to dissect raw
+	// buffers (see DecodeFields).
+	FormatDefinition() []FormatDefinition
 }
@@ -149,8 +149,8 @@ var valuesESIDREAS = map[int]string{
 	99: "Unspecified Abort code",
 }
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *ESID) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *ESID) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `ESIDCMD`, &[]wire.Value{{ESIDCMD, "ESID Command"}}},
 		{`F 9(2)`, `ESIDREAS`, wire.IntMapToValues(valuesESIDREAS, 2)},
@@ -163,7 +163,7 @@ func (s *ESID) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *ESID) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *ESID) Marshal() []byte {
@@ -182,7 +182,7 @@ func (s *ESID) Parse(input []byte) error {
 	s.ReasonText = buffer.GetString(lenReasonText)
 	_ = buffer.GetString(1)
 
-	return nil
+	return buffer.Err()
 }
 
 func (s *ESID) String() string {
@@ -196,3 +196,9 @@ func BuildESID(reasonCode int, reasonText string) ESID {
 	}
 	return esid
 }
+
+// ESIDReasonText returns the description of an ESID reason code as given in
+// the RFC. It is empty for unknown codes.
+func ESIDReasonText(reasonCode int) string {
+	return valuesESIDREAS[reasonCode]
+}
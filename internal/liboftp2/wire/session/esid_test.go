@@ -22,3 +22,12 @@ func TestESID_RoundTrip(t *testing.T) {
 		t.Errorf("Roundtrip failed: %v != %v", a1, a2)
 	}
 }
+
+func TestESIDReasonText(t *testing.T) {
+	if text := ESIDReasonText(8); text != "Resources not available" {
+		t.Errorf("wrong text for 08: %s", text)
+	}
+	if text := ESIDReasonText(42); text != "" {
+		t.Errorf("text for unknown code: %s", text)
+	}
+}
@@ -1,7 +1,6 @@
 package session
 
 import (
-	"errors"
 	"fmt"
 	"reflect"
 
@@ -218,8 +217,29 @@ var valuesSSIDLEV = map[string]string{
 // SSIDCMD is the command indicator for the SSID command.
 const SSIDCMD = "X"
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *SSID) formatDefinition() []wire.FormatDefinition {
+// ProtocolLevel is the release level of OFTP2 sent in SSIDLEV
+const ProtocolLevel = "5"
+
+// ReleaseName returns the name of the protocol release for the SSIDLEV value
+func ReleaseName(level string) string {
+	if name, ok := valuesSSIDLEV[level]; ok {
+		return name
+	}
+	return "unknown release"
+}
+
+// LevelError is returned by Parse if the partner speaks another release of the
+// protocol than OFTP2
+type LevelError struct {
+	Level string
+}
+
+func (e *LevelError) Error() string {
+	return fmt.Sprintf("wrong version %s, expected %s", e.Level, ProtocolLevel)
+}
+
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *SSID) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `SSIDCMD`, &[]wire.Value{{SSIDCMD, "SSID Command"}}},
 		{`F 9(1)`, `SSIDLEV`, wire.StringMapToValues(valuesSSIDLEV)},
@@ -241,7 +261,7 @@ func (s *SSID) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *SSID) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *SSID) Marshal() []byte {
@@ -254,7 +274,7 @@ func (s *SSID) Command() wire.Command {
 		Format: s.dataFormat(),
 		Data: []interface{}{
 			SSIDCMD,
-			"5",
+			ProtocolLevel,
 			s.Id,
 			s.Password,
 			s.BufferSize,
@@ -279,8 +299,8 @@ func (s *SSID) Parse(input []byte) error {
 
 	version := buffer.GetString(1)
 
-	if version != "5" {
-		return errors.New(fmt.Sprintf("wrong version %s, expected 5", version))
+	if version != ProtocolLevel {
+		return &LevelError{Level: version}
 	}
 
 	s.Id = buffer.GetString(25)
@@ -295,7 +315,7 @@ func (s *SSID) Parse(input []byte) error {
 	_ = buffer.GetString(4)
 	s.UserData = buffer.GetString(8)
 
-	return nil
+	return buffer.Err()
 }
 
 func (s *SSID) String() string {
@@ -312,6 +332,30 @@ func (s *SSID) String() string {
 	return result
 }
 
+// ssidPasswordOffset is the position of the SSIDPSWD field in the SSID command
+const ssidPasswordOffset = 27
+
+// ssidPasswordLength is the length of the SSIDPSWD field in the SSID command
+const ssidPasswordLength = 8
+
+// RedactPassword returns a copy of the given exchange buffer with the password
+// of an SSID command replaced by asterisks, so that the buffer can be logged or
+// traced. Buffers of other commands are returned unchanged.
+func RedactPassword(input []byte) []byte {
+	if len(input) < ssidPasswordOffset+ssidPasswordLength || string(input[0:1]) != SSIDCMD {
+		return input
+	}
+
+	result := make([]byte, len(input))
+	copy(result, input)
+
+	for i := ssidPasswordOffset; i < ssidPasswordOffset+ssidPasswordLength; i++ {
+		result[i] = '*'
+	}
+
+	return result
+}
+
 func ToSSID(data *interface{}) *SSID {
 	switch (*data).(type) {
 	case SSID:
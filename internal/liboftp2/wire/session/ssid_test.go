@@ -1,6 +1,7 @@
 package session
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -30,3 +31,53 @@ func TestSSID_RoundTrip(t *testing.T) {
 		t.Errorf("Roundtrip failed: %v != %v", a1, a2)
 	}
 }
+
+func TestSSID_Level(t *testing.T) {
+	b := (&SSID{Id: "O1818181DDD"}).Marshal()
+	b[1] = '4'
+
+	var levelError *LevelError
+	err := (&SSID{}).Parse(b)
+	if !errors.As(err, &levelError) || levelError.Level != "4" {
+		t.Fatalf("expected level error, got %v", err)
+	}
+	if ReleaseName(levelError.Level) != "Revision 1.4" || ReleaseName("9") != "unknown release" {
+		t.Errorf("wrong release names")
+	}
+}
+
+func TestRedactPassword(t *testing.T) {
+	a1 := SSID{
+		Id:         "O0013000000LOCAL",
+		Password:   "SECRET",
+		BufferSize: 1024,
+		Capability: "S",
+		Credit:     99,
+	}
+
+	b := a1.Marshal()
+	r := RedactPassword(b)
+
+	a2 := SSID{}
+	err := a2.Parse(r)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if a2.Password != "********" {
+		t.Errorf("expected redacted password, got %s", a2.Password)
+	}
+
+	if a2.Id != a1.Id || a2.BufferSize != a1.BufferSize {
+		t.Errorf("redaction changed other fields: %v != %v", a1, a2)
+	}
+
+	if a1.Password != "SECRET" || string(b[27:33]) != "SECRET" {
+		t.Errorf("redaction modified the original buffer")
+	}
+
+	esid := []byte("F00002OK\n")
+	if string(RedactPassword(esid)) != string(esid) {
+		t.Errorf("redaction modified a non SSID buffer")
+	}
+}
@@ -28,8 +28,8 @@ type SSRM struct {
 // SSRMCMD is the command indicator for the SSRM command.
 const SSRMCMD = "I"
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *SSRM) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *SSRM) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `SSRMCMD`, &[]wire.Value{{SSRMCMD, "SSRM Command"}}},
 		{`F X(17)`, `SSRMMSG`, &[]wire.Value{{"ODETTE FTP READY ", "HELO"}}},
@@ -40,7 +40,7 @@ func (s *SSRM) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *SSRM) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *SSRM) Command() wire.Command {
@@ -68,5 +68,5 @@ func (s *SSRM) Parse(input []byte) error {
 		return errors.New(fmt.Sprintf("helo %s", helo))
 	}
 
-	return nil
+	return buffer.Err()
 }
@@ -159,8 +159,8 @@ type EERP struct {
 // EERPCMD is the command indicator for the EERP command.
 const EERPCMD = "E"
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *EERP) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *EERP) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `EERPCMD`, &[]wire.Value{{EERPCMD, "EERP Command"}}},
 		{`V X(26)`, `EERPDSN`, nil},
@@ -180,7 +180,7 @@ func (s *EERP) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *EERP) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *EERP) Command() wire.Command {
@@ -233,5 +233,5 @@ func (s *EERP) Parse(input []byte) error {
 
 	s.VirtualFileDate = wire.ParseStringsToDate(fileDate, fileTime)
 
-	return nil
+	return buffer.Err()
 }
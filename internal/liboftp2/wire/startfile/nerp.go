@@ -267,8 +267,8 @@ var valuesNERPREAS = map[int]string{
 	99: "Unspecified reason.",
 }
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *NERP) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *NERP) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `NERPCMD `, &[]wire.Value{{NERPCMD, "NERP Command"}}},
 		{`V X(26)`, `NERPDSN `, nil},
@@ -291,7 +291,7 @@ func (s *NERP) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *NERP) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *NERP) Command() wire.Command {
@@ -351,9 +351,15 @@ func (s *NERP) Parse(input []byte) error {
 	s.Signature = buffer.GetBytes(int(lenSignature))
 
 	s.VirtualFileDate = wire.ParseStringsToDate(fileDate, fileTime)
-	return nil
+	return buffer.Err()
 }
 
 func (s *NERP) String() string {
 	return fmt.Sprintf("NERP - Negative End Response. Reason Code: %d (%s), Text: %s", s.ReasonCode, valuesNERPREAS[s.ReasonCode], s.ReasonText)
 }
+
+// NERPReasonText returns the description of a NERP reason code as given in the
+// RFC. It is empty for unknown codes.
+func NERPReasonText(reasonCode int) string {
+	return valuesNERPREAS[reasonCode]
+}
@@ -37,3 +37,12 @@ func TestNERP_RoundTrip(t *testing.T) {
 		t.Errorf("Roundtrip failed: %v != %v", a1, a2)
 	}
 }
+
+func TestNERPReasonText(t *testing.T) {
+	if text := NERPReasonText(35); text != "Not delivered to recipient." {
+		t.Errorf("wrong text for 35: %s", text)
+	}
+	if text := NERPReasonText(42); text != "" {
+		t.Errorf("text for unknown code: %s", text)
+	}
+}
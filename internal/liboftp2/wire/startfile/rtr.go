@@ -30,8 +30,8 @@ type RTR struct {
 // RTRCMD is the command indicator for the RTR command.
 const RTRCMD = "P"
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *RTR) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *RTR) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `RTRCMD`, &[]wire.Value{{RTRCMD, "RTR Command"}}},
 	}
@@ -40,7 +40,7 @@ func (s *RTR) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *RTR) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *RTR) Command() wire.Command {
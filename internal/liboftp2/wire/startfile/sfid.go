@@ -305,8 +305,8 @@ type SFID struct {
 // SFIDCMD is the command indicator for the SFID command.
 const SFIDCMD = "H"
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *SFID) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *SFID) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `SFIDCMD`, &[]wire.Value{{SFIDCMD, "SFID Command"}}},
 		{`V X(26)`, `SFIDDSN`, nil},
@@ -353,7 +353,7 @@ func (s *SFID) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *SFID) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *SFID) Command() wire.Command {
@@ -425,5 +425,5 @@ func (s *SFID) Parse(input []byte) error {
 
 	s.FileDateTime = wire.ParseStringsToDate(fileDate, fileTime)
 
-	return nil
+	return buffer.Err()
 }
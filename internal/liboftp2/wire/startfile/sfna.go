@@ -116,8 +116,8 @@ var valuesSFNAREAS = map[int]string{
 	99: "Unspecified reason.",
 }
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *SFNA) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *SFNA) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `SFNACMD`, &[]wire.Value{{SFNACMD, "SFNA Command"}}},
 		{`F 9(2)`, `SFNAREAS`, wire.IntMapToValues(valuesSFNAREAS, 2)},
@@ -130,7 +130,7 @@ func (s *SFNA) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *SFNA) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *SFNA) Command() wire.Command {
@@ -167,9 +167,15 @@ func (s *SFNA) Parse(input []byte) error {
 	lenAnswerText := buffer.GetNumInt(3)
 	s.ReasonText = buffer.GetString(lenAnswerText)
 
-	return nil
+	return buffer.Err()
 }
 
 func (s *SFNA) String() string {
 	return fmt.Sprintf("SFNA - Start File Negative Answer. Reason Code: %d (%s), Text: %s", s.ReasonCode, valuesSFNAREAS[s.ReasonCode], s.ReasonText)
 }
+
+// SFNAReasonText returns the description of an SFNA reason code as given in
+// the RFC. It is empty for unknown codes.
+func SFNAReasonText(reasonCode int) string {
+	return valuesSFNAREAS[reasonCode]
+}
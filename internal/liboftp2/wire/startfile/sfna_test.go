@@ -24,3 +24,12 @@ func TestSFNA_RoundTrip(t *testing.T) {
 		t.Errorf("Roundtrip failed: %v != %v", a1, a2)
 	}
 }
+
+func TestSFNAReasonText(t *testing.T) {
+	if text := SFNAReasonText(13); text != "Duplicate file." {
+		t.Errorf("wrong text for 13: %s", text)
+	}
+	if text := SFNAReasonText(42); text != "" {
+		t.Errorf("text for unknown code: %s", text)
+	}
+}
@@ -39,8 +39,8 @@ type SFPA struct {
 // SFPACMD is the command indicator for the SFPA command.
 const SFPACMD = "2"
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *SFPA) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *SFPA) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `SFPACMD`, &[]wire.Value{{SFPACMD, "SFPA Command"}}},
 		{`V 9(17)`, `SFPAACNT`, nil},
@@ -50,7 +50,7 @@ func (s *SFPA) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *SFPA) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *SFPA) Command() wire.Command {
@@ -73,5 +73,5 @@ func (s *SFPA) Parse(input []byte) error {
 
 	s.AnswerCount = buffer.GetBinQWord(17)
 
-	return nil
+	return buffer.Err()
 }
@@ -0,0 +1,125 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+/*
+8.  Stream Transmission Buffer
+
+   ODETTE-FTP uses TCP as a stream transmission service.  Every Exchange
+   Buffer is preceded by a Stream Transmission Header (STH), which gives
+   the receiver the length of the buffer.
+
+   o-------------------------------------------------------------------o
+   |   Version  |   Flags   |                 Length                   |
+   |  (4 bits)  | (4 bits)  |                (24 bits)                 |
+   o-------------------------------------------------------------------o
+
+   Version   Value: 0001 (binary)
+   Flags     Value: 0000 (binary)
+   Length    Length of the Stream Transmission Buffer, including the
+             Stream Transmission Header (4 octets).
+*/
+
+// StreamHeaderLength is the number of bytes of the Stream Transmission Header
+// (STH) preceding each exchange buffer in the TCP data stream.
+const StreamHeaderLength = 4
+
+// StreamHeaderVersion is the first octet of a valid Stream Transmission Header
+// (version 1, no flags).
+const StreamHeaderVersion = 0x10
+
+// StreamBufferLength returns the length of the exchange buffer announced by the
+// given Stream Transmission Header. The length of the header itself is not
+// included in the result.
+func StreamBufferLength(header []byte) (int, error) {
+	if len(header) < StreamHeaderLength {
+		return 0, errors.New(fmt.Sprintf("stream transmission header too short: %d bytes", len(header)))
+	}
+
+	if header[0] != StreamHeaderVersion {
+		return 0, errors.New(fmt.Sprintf("invalid stream transmission header 0x%02x", header[0]))
+	}
+
+	// the first byte does not contribute to the length information
+	length := binary.BigEndian.Uint32([]byte{0, header[1], header[2], header[3]})
+
+	if length < StreamHeaderLength {
+		return 0, errors.New(fmt.Sprintf("invalid length %d in stream transmission header", length))
+	}
+
+	return int(length) - StreamHeaderLength, nil
+}
+
+// StreamBuffer is a single exchange buffer found in a TCP data stream.
+type StreamBuffer struct {
+	// Offset of the Stream Transmission Header in the stream
+	Offset int
+
+	// Data of the exchange buffer without the header
+	Data []byte
+}
+
+// SplitStream splits a captured TCP data stream into the exchange buffers it
+// contains, using the Stream Transmission Headers. If the stream is malformed or
+// truncated, the buffers found so far are returned together with an error.
+func SplitStream(stream []byte) ([]StreamBuffer, error) {
+	result := make([]StreamBuffer, 0)
+	pos := 0
+
+	for pos < len(stream) {
+		length, err := StreamBufferLength(stream[pos:])
+		if err != nil {
+			return result, errors.New(fmt.Sprintf("offset %d: %v", pos, err))
+		}
+
+		start := pos + StreamHeaderLength
+		end := start + length
+
+		if end > len(stream) {
+			return result, errors.New(fmt.Sprintf("offset %d: buffer of %d bytes truncated after %d bytes", pos, length, len(stream)-start))
+		}
+
+		result = append(result, StreamBuffer{Offset: pos, Data: stream[start:end]})
+		pos = end
+	}
+
+	return result, nil
+}
+
+// ReadStreamBuffer reads the next exchange buffer from the reader. The Stream
+// Transmission Header is evaluated and not part of the result.
+func ReadStreamBuffer(r io.Reader) ([]byte, error) {
+	header := make([]byte, StreamHeaderLength)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := StreamBufferLength(header)
+	if err != nil {
+		return nil, err
+	}
+
+	buff := make([]byte, length)
+	_, err = io.ReadFull(r, buff)
+	if err != nil {
+		return nil, err
+	}
+
+	return buff, nil
+}
+
+// EncodeStreamBuffer prepends the Stream Transmission Header to the given
+// exchange buffer.
+func EncodeStreamBuffer(data []byte) []byte {
+	buffer := make([]byte, StreamHeaderLength+len(data))
+	binary.BigEndian.PutUint32(buffer, uint32(len(data)+StreamHeaderLength))
+	buffer[0] = StreamHeaderVersion
+	copy(buffer[StreamHeaderLength:], data)
+	return buffer
+}
@@ -0,0 +1,94 @@
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSplitStream(t *testing.T) {
+	stream := []byte{
+		0x10, 0x00, 0x00, 0x05, 'R',
+		0x10, 0x00, 0x00, 0x07, 'C', ' ', ' ',
+	}
+
+	buffers, err := SplitStream(stream)
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []StreamBuffer{
+		{Offset: 0, Data: []byte("R")},
+		{Offset: 5, Data: []byte("C  ")},
+	}
+
+	if !reflect.DeepEqual(buffers, expected) {
+		t.Errorf("expected %v, got %v", expected, buffers)
+	}
+}
+
+func TestSplitStream_Truncated(t *testing.T) {
+	stream := []byte{
+		0x10, 0x00, 0x00, 0x05, 'R',
+		0x10, 0x00, 0x00, 0x07, 'C',
+	}
+
+	buffers, err := SplitStream(stream)
+	if err == nil {
+		t.Errorf("expected error for truncated stream")
+	}
+
+	if len(buffers) != 1 {
+		t.Errorf("expected 1 buffer, got %d", len(buffers))
+	}
+}
+
+func TestStreamBufferLength_InvalidHeader(t *testing.T) {
+	_, err := StreamBufferLength([]byte{0x20, 0x00, 0x00, 0x05})
+	if err == nil {
+		t.Errorf("expected error for invalid version")
+	}
+
+	_, err = StreamBufferLength([]byte{0x10, 0x00, 0x00, 0x02})
+	if err == nil {
+		t.Errorf("expected error for invalid length")
+	}
+}
+
+func TestStreamBuffer_RoundTrip(t *testing.T) {
+	data := []byte("X5O0013000000LOCAL")
+
+	encoded := EncodeStreamBuffer(data)
+
+	if encoded[0] != StreamHeaderVersion || len(encoded) != len(data)+StreamHeaderLength {
+		t.Errorf("wrong header: %v", encoded[0:StreamHeaderLength])
+	}
+
+	decoded, err := ReadStreamBuffer(bytes.NewReader(encoded))
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !reflect.DeepEqual(data, decoded) {
+		t.Errorf("Roundtrip failed: %v != %v", data, decoded)
+	}
+}
+
+func FuzzSplitStream(f *testing.F) {
+	f.Add([]byte{0x10, 0x00, 0x00, 0x05, 'R', 0x10, 0x00, 0x00, 0x07, 'C', ' ', ' '})
+	f.Add([]byte{0x10, 0x00, 0x00})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, stream []byte) {
+		buffers, err := SplitStream(stream)
+		if err == nil {
+			joined := make([]byte, 0, len(stream))
+			for _, b := range buffers {
+				joined = append(joined, EncodeStreamBuffer(b.Data)...)
+			}
+			if !bytes.Equal(joined, stream) {
+				t.Errorf("stream not reproduced: %x != %x", joined, stream)
+			}
+		}
+	})
+}
@@ -32,8 +32,8 @@ type CDT struct {
 // CDTCMD is the command indicator for the CDT command.
 const CDTCMD = "C"
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *CDT) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *CDT) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `CDTCMD`, &[]wire.Value{{CDTCMD, "CDT Command"}}},
 		{`F X(2)`, `CDTRSV1`, &[]wire.Value{{"  ", "Reserved"}}},
@@ -43,7 +43,7 @@ func (s *CDT) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *CDT) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *CDT) Command() wire.Command {
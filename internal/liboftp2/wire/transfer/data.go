@@ -37,8 +37,8 @@ type DATA struct {
 // DATACMD is the command indicator for the DATA command.
 const DATACMD = "D"
 
-// formatDefinition returns the format definition as given in the RFC5024
-func (s *DATA) formatDefinition() []wire.FormatDefinition {
+// FormatDefinition returns the format definition as given in the RFC5024
+func (s *DATA) FormatDefinition() []wire.FormatDefinition {
 	return []wire.FormatDefinition{
 		{`F X(1)`, `DATACMD`, &[]wire.Value{{DATACMD, "DATA Command"}}},
 		{`V U(n)`, `DATABUF`, nil},
@@ -48,7 +48,7 @@ func (s *DATA) formatDefinition() []wire.FormatDefinition {
 // dataFormat returns the format definition for this command in a machine
 // readable form.
 func (s *DATA) dataFormat() []wire.DataFormat {
-	return wire.FormatDefinitionsToDataFormats(s.formatDefinition())
+	return wire.FormatDefinitionsToDataFormats(s.FormatDefinition())
 }
 
 func (s *DATA) Command() wire.Command {
@@ -70,7 +70,12 @@ func (s *DATA) Parse(input []byte) error {
 		return err
 	}
 
+	// without a known length, the buffer extends to the end of the input
+	if s.Length == 0 {
+		s.Length = uint64(len(input) - 1)
+	}
+
 	s.Buffer = buffer.GetBytes(int(s.Length))
 
-	return nil
+	return buffer.Err()
 }
@@ -0,0 +1,112 @@
+package transfer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+/*
+7.2.  Data Exchange Buffer Format
+
+   A Data Exchange Buffer contains a sequence of sub-records, each
+   preceded by a one-octet header.
+
+      o-------------------------------o
+      | E | C |                       |
+      | o | F | C O U N T             |
+      | R |   |                       |
+      o-------------------------------o
+
+   Bits 0-5  Count: number of octets in the sub-record (0-63)
+   Bit  6    Compression Flag: the sub-record contains one octet that
+             has to be repeated Count times
+   Bit  7    End of Record Flag: the sub-record is the last one of a
+             logical record
+
+   Note: the bit order shown in the RFC is reversed, see
+   splitBufferIntoSubRecords in the client package.
+*/
+
+// SubRecord is a single sub-record of a Data Exchange Buffer
+type SubRecord struct {
+	// Offset of the sub-record header in the DATA payload
+	Offset int
+
+	// Count as given in the sub-record header
+	Count int
+
+	// Compressed indicates that Data contains a single octet that is repeated
+	// Count times
+	Compressed bool
+
+	// EndOfRecord marks the last sub-record of a logical record
+	EndOfRecord bool
+
+	// Data contained in the sub-record as it appears on the wire
+	Data []byte
+}
+
+const (
+	subRecordCountMask      = 0x3f
+	subRecordCompressedFlag = 0x40
+	subRecordEndFlag        = 0x80
+)
+
+// Expand returns the user data represented by the sub-record, resolving the
+// compression.
+func (s *SubRecord) Expand() []byte {
+	if s.Compressed && len(s.Data) == 1 {
+		return bytes.Repeat(s.Data, s.Count)
+	}
+	return s.Data
+}
+
+// ParseSubRecords splits the payload of a Data Exchange Buffer (without the
+// DATA command octet) into its sub-records. Parsing stops at an empty header
+// that is only followed by padding. The sub-records found so far are returned
+// together with an error if the payload is truncated.
+func ParseSubRecords(payload []byte) ([]SubRecord, error) {
+	result := make([]SubRecord, 0)
+	pos := 0
+
+	for pos < len(payload) {
+		header := payload[pos]
+
+		if header == 0 && isPadding(payload[pos:]) {
+			break
+		}
+
+		record := SubRecord{
+			Offset:      pos,
+			Count:       int(header & subRecordCountMask),
+			Compressed:  header&subRecordCompressedFlag != 0,
+			EndOfRecord: header&subRecordEndFlag != 0,
+		}
+
+		length := record.Count
+		if record.Compressed {
+			length = 1
+		}
+
+		if pos+1+length > len(payload) {
+			return result, errors.New(fmt.Sprintf("sub-record at offset %d truncated: need %d bytes, have %d", pos, length, len(payload)-pos-1))
+		}
+
+		record.Data = payload[pos+1 : pos+1+length]
+		result = append(result, record)
+		pos += 1 + length
+	}
+
+	return result, nil
+}
+
+// isPadding checks if the given data consists only of zero bytes
+func isPadding(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
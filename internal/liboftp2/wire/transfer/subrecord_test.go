@@ -0,0 +1,48 @@
+package transfer
+
+import (
+	"testing"
+)
+
+func TestParseSubRecords(t *testing.T) {
+	payload := []byte{0x03, 'a', 'b', 'c', 0xc4, 'x', 0x00, 0x00}
+
+	records, err := ParseSubRecords(payload)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 sub records, got %d", len(records))
+	}
+
+	if records[0].Count != 3 || records[0].EndOfRecord || records[0].Compressed || string(records[0].Expand()) != "abc" {
+		t.Errorf("wrong first sub record: %v", records[0])
+	}
+
+	if records[1].Count != 4 || !records[1].EndOfRecord || !records[1].Compressed || string(records[1].Expand()) != "xxxx" {
+		t.Errorf("wrong second sub record: %v", records[1])
+	}
+}
+
+func TestParseSubRecords_Truncated(t *testing.T) {
+	_, err := ParseSubRecords([]byte{0x05, 'a', 'b'})
+	if err == nil {
+		t.Errorf("expected error for truncated sub record")
+	}
+}
+
+func FuzzParseSubRecords(f *testing.F) {
+	f.Add([]byte{0x03, 'a', 'b', 'c', 0xc4, 'x', 0x00, 0x00})
+	f.Add([]byte{0x05, 'a', 'b'})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		records, err := ParseSubRecords(payload)
+		if err == nil {
+			for _, r := range records {
+				_ = r.Expand()
+			}
+		}
+	})
+}
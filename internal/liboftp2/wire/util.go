@@ -2,6 +2,7 @@ package wire
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -27,8 +28,8 @@ func TruncateAndPadString(s string, length int) string {
 	} else if len(s) == length {
 		return s
 	} else {
-		formatString := fmt.Sprintf("%%-%ds", length)
-		return fmt.Sprintf(formatString, s)
+		// padded by bytes, a format width would count characters
+		return s + strings.Repeat(" ", length-len(s))
 	}
 }
 
@@ -54,6 +55,9 @@ func ParseDateToString(dateToParse time.Time) (string, string) {
 // ParseStringsToDate combines two strings, for date and time, into a normal Time object.
 func ParseStringsToDate(d, t string) time.Time {
 	toParse := d + t
+	if len(toParse) < 14 {
+		return time.Time{}
+	}
 	// strip away counter
 	toParse = toParse[0:14]
 	result, _ := time.Parse("20060102150405", toParse)
@@ -65,6 +65,14 @@ func TestTruncateAndPadString(t *testing.T) {
 	if r != e {
 		t.Errorf("expected %s, got %s", e, r)
 	}
+
+	// the field length is counted in bytes
+	r = TruncateAndPadString("ÄB", 5)
+	e = "ÄB  "
+
+	if r != e {
+		t.Errorf("expected %q, got %q", e, r)
+	}
 }
 
 func TestParseDateToString(t *testing.T) {
@@ -0,0 +1,132 @@
+package oftp2
+
+import (
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+)
+
+// Client holds the configuration to speak OFTP2 with a partner. Sessions are
+// opened with Client.Dial or Client.Open. The zero value is not usable, at
+// least ServerHost, ServerPort and OdetteId have to be set.
+type Client = client.OFTP2Client
+
+// Session is a connection to a partner. It owns the connection, the parameters
+// negotiated with the partner and the state of the protocol.
+type Session = client.Session
+
+// Endpoint is a further address of the partner, tried in order if the primary
+// ServerHost and ServerPort cannot be reached
+type Endpoint = client.Endpoint
+
+// Parameters are the session parameters the partner sent in its SSID
+type Parameters = client.Parameters
+
+// Phase of a session
+type Phase = client.Phase
+
+// Phases of a session
+const (
+	PhaseConnected = client.PhaseConnected
+	PhaseSession   = client.PhaseSession
+	PhaseTransfer  = client.PhaseTransfer
+	PhaseClosed    = client.PhaseClosed
+)
+
+// VirtualFile describes a virtual file sent with Session.SendStream. Its fields
+// map onto the fields of the Start File command.
+type VirtualFile = client.VirtualFile
+
+// FileFormat is the format of a virtual file
+type FileFormat = client.OFTP2FileFormat
+
+const (
+	// FileFormatFixedBinary is a binary file with fixed record structure
+	FileFormatFixedBinary = client.FileFormatFixedBinary
+
+	// FileFormatVariable is a file with a variable structure
+	FileFormatVariable = client.FileFormatVariable
+
+	// FileFormatUnstructured is an unstructured file without any substructure
+	FileFormatUnstructured = client.FileFormatUnstructured
+
+	// FileFormatText is a text file
+	FileFormatText = client.FileFormatText
+)
+
+// SecurityLevel of a virtual file
+type SecurityLevel = client.OFTP2SecurityLevel
+
+const (
+	// SecurityLevelNone indicates no security level
+	SecurityLevelNone = client.SecurityLevelNone
+
+	// SecurityLevelEncrypted indicates an encrypted file
+	SecurityLevelEncrypted = client.SecurityLevelEncrypted
+
+	// SecurityLevelSigned indicates a signed file
+	SecurityLevelSigned = client.SecurityLevelSigned
+
+	// SecurityLevelSignedAndEncrypted indicates a file which is encrypted and signed
+	SecurityLevelSignedAndEncrypted = client.SecurityLevelSignedAndEncrypted
+)
+
+// Timers used if no value is set on the client
+const (
+	DefaultResponseTimeout   = client.DefaultResponseTimeout
+	DefaultInactivityTimeout = client.DefaultInactivityTimeout
+	DefaultConnectTimeout    = client.DefaultConnectTimeout
+)
+
+var (
+	// ErrTimeout is returned (wrapped) if the partner did not answer in time
+	ErrTimeout = client.ErrTimeout
+
+	// ErrNotConnected is returned if the client is used without a connection
+	ErrNotConnected = client.ErrNotConnected
+
+	// ErrAuthenticationMismatch is returned if the partner does not agree on
+	// secure authentication
+	ErrAuthenticationMismatch = client.ErrAuthenticationMismatch
+)
+
+// GenerateOdetteId generates a RFC-compliant ODETTE id for the given
+// international code, organization code and computer address
+func GenerateOdetteId(intCode int, orgCode, subAddress string) string {
+	return client.GenerateOdetteId(intCode, orgCode, subAddress)
+}
+
+// OdetteId is an Odette ID split into its components
+type OdetteId = client.OdetteId
+
+// OdetteIdError is returned if an Odette ID does not follow the structure
+// defined by Odette. The client checks its own ID and the originator and
+// destination of a file before they are sent.
+type OdetteIdError = client.OdetteIdError
+
+// ParseOdetteId splits the Odette ID into its international code,
+// organisation code and sub-address
+func ParseOdetteId(id string) (OdetteId, error) {
+	return client.ParseOdetteId(id)
+}
+
+// ValidateOdetteId checks the structure of the Odette ID
+func ValidateOdetteId(id string) error {
+	return client.ValidateOdetteId(id)
+}
+
+// NormaliseOdetteId converts the Odette ID to upper case, removes surrounding
+// white space and checks it
+func NormaliseOdetteId(id string) (string, error) {
+	return client.NormaliseOdetteId(id)
+}
+
+// IsRetryable reports whether a transmission that failed with err may succeed
+// if it is tried again later
+func IsRetryable(err error) bool {
+	return client.IsRetryable(err)
+}
+
+// FailureReceipt creates the final status of a file the application gave up
+// on, the local equivalent of a negative end response (NERP)
+func FailureReceipt(file VirtualFile, creator string, err error) Receipt {
+	return client.FailureReceipt(file, creator, err)
+}
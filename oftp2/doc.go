@@ -0,0 +1,37 @@
+// Package oftp2 is the public API of the OFTP2 library. Other Go programs
+// import this package to speak OFTP2 (RFC 5024) with a partner without using
+// the oftp2 command line tool.
+//
+// The Client holds the configuration to reach a partner. It opens a Session,
+// which sends virtual files and ends the session again:
+//
+//	c := oftp2.Client{
+//	    ServerHost: "oftp.example.com",
+//	    ServerPort: 3305,
+//	    OdetteId:   oftp2.GenerateOdetteId(13, "EXAMPLE", ""),
+//	}
+//
+//	s, err := c.Open(ctx, "PASSWORD", false, false, false)
+//	...
+//
+// The Client is not changed by the sessions, so one Client can be used for
+// several sessions at the same time.
+//
+// Sessions are secured with TLS by setting Client.TLSConfig. If the partner
+// can only be reached through a SOCKS5 or HTTP CONNECT proxy, set
+// Client.Proxy, e.g. to the result of ParseProxy.
+//
+// Programs using the package can be tested against the scriptable partner of
+// package oftp2test, which is reached in memory via Client.Dialer.
+//
+// The command structures of the protocol (SSID, SFID, EERP, ...) are exported
+// as message types, so that the answers of a partner can be inspected. The
+// encoding of the commands on the wire stays internal to the library.
+//
+// # Versioning
+//
+// The package follows semantic versioning (https://semver.org). The version of
+// the library is available in the constant Version and as git tag of the form
+// vMAJOR.MINOR.PATCH. As long as the major version is 0, minor versions may
+// contain incompatible changes; these are listed in the release notes.
+package oftp2
@@ -0,0 +1,32 @@
+package oftp2
+
+import (
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/protocol"
+)
+
+// SessionEndedError is returned if the partner ended the session with an ESID.
+// Use errors.As to get the reason code.
+type SessionEndedError = client.SessionEndedError
+
+// SessionAbortedError describes the ESID sent when the client aborted the
+// session. It is reported to the observer with EventSessionFailed.
+type SessionAbortedError = client.SessionAbortedError
+
+// ProtocolViolationError is returned if the partner sent a command that is not
+// allowed in the state of the session, the session is aborted with ESID reason
+// 02 in this case. It is also returned without aborting the session if the
+// application tries to send a command out of order.
+type ProtocolViolationError = protocol.ViolationError
+
+// StartFileRejectedError is returned if the partner refused a file with an
+// SFNA
+type StartFileRejectedError = client.StartFileRejectedError
+
+// EndFileRejectedError is returned if the partner refused a file with an EFNA
+// after its transmission
+type EndFileRejectedError = client.EndFileRejectedError
+
+// NegativeEndResponseError describes a negative end response (NERP) for a
+// file, see Receipt.Err
+type NegativeEndResponseError = client.NegativeEndResponseError
@@ -0,0 +1,70 @@
+package oftp2_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/oftp2"
+)
+
+func ExampleClient() {
+	c := oftp2.Client{
+		ServerHost: "oftp.example.com",
+		ServerPort: 3305,
+		OdetteId:   oftp2.GenerateOdetteId(13, "EXAMPLE", ""),
+		Logger:     oftp2.NewTextLogger(os.Stderr, oftp2.LogLevelInfo),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	s, err := c.Open(ctx, "PASSWORD", false, false, false)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer s.Close()
+
+	err = s.SendFile(ctx, "DELFOR01", "/tmp/delfor.edi", oftp2.FileFormatUnstructured,
+		"O0013000000PARTNER", oftp2.SecurityLevelNone, false, false, false, false)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	err = s.End(ctx)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func ExampleParseMessage() {
+	esid := oftp2.ESID{ReasonCode: 9, ReasonText: "Time out"}
+
+	message, name, err := oftp2.ParseMessage(esid.Marshal())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(name, message.(*oftp2.ESID).ReasonCode)
+	// Output: ESID 9
+}
+
+func ExampleGenerateOdetteId() {
+	fmt.Println(oftp2.GenerateOdetteId(13, "EXAMPLE", "SUB"))
+	// Output: O0013EXAMPLE       SUB
+}
+
+func ExampleParseOdetteId() {
+	id, err := oftp2.ParseOdetteId("O0013EXAMPLE       SUB")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(id.InternationalCode, id.Organisation, id.SubAddress)
+	// Output: 13 EXAMPLE SUB
+}
@@ -0,0 +1,43 @@
+package oftp2
+
+import (
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+)
+
+// SendQueue holds files waiting for transmission, separately for each partner.
+// Session.DrainQueue sends the files of the session partner in priority order.
+type SendQueue = client.SendQueue
+
+// QueuedFile is a file waiting in a SendQueue
+type QueuedFile = client.QueuedFile
+
+// QueueResult is the outcome of sending a single queued file
+type QueueResult = client.QueueResult
+
+// NewSendQueue creates an empty send queue
+func NewSendQueue() *SendQueue {
+	return client.NewSendQueue()
+}
+
+// NewQueuedFile creates a queued file for the file at the given path
+func NewQueuedFile(path string, file VirtualFile, priority int) (QueuedFile, error) {
+	return client.NewQueuedFile(path, file, priority)
+}
+
+// Inbox stores the files received with Session.ReceiveFiles
+type Inbox = client.Inbox
+
+// InboxFile receives the content of a single file
+type InboxFile = client.InboxFile
+
+// FileRefusal is returned by an Inbox to refuse a file with a reason code
+type FileRefusal = client.FileRefusal
+
+// ReceivedFile is a file received from the partner
+type ReceivedFile = client.ReceivedFile
+
+// Receipt is an end to end response (EERP or NERP) received from the partner
+type Receipt = client.Receipt
+
+// ExchangeResult summarizes what the partner sent while it was the speaker
+type ExchangeResult = client.ExchangeResult
@@ -0,0 +1,33 @@
+package oftp2
+
+import (
+	"io"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/logging"
+)
+
+// Logger receives the log messages of the library (see Client.Logger). The
+// levels and the key/value style follow log/slog.
+type Logger = logging.Logger
+
+// LogLevel is the level of a log message
+type LogLevel = logging.Level
+
+const (
+	LogLevelDebug = logging.LevelDebug
+	LogLevelInfo  = logging.LevelInfo
+	LogLevelWarn  = logging.LevelWarn
+	LogLevelError = logging.LevelError
+)
+
+// NewTextLogger creates a logger writing key=value lines of at least the given
+// level to w.
+func NewTextLogger(w io.Writer, level LogLevel) Logger {
+	return logging.NewTextLogger(w, level)
+}
+
+// NewJSONLogger creates a logger writing JSON lines of at least the given level
+// to w.
+func NewJSONLogger(w io.Writer, level LogLevel) Logger {
+	return logging.NewJSONLogger(w, level)
+}
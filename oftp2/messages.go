@@ -0,0 +1,86 @@
+package oftp2
+
+import (
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/authentication"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/endfile"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/startfile"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/transfer"
+)
+
+// Message is implemented by all OFTP2 commands
+type Message = wire.Protocol
+
+// Session commands
+type (
+	// SSRM is the ready message of the responder
+	SSRM = session.SSRM
+
+	// SSID starts a session
+	SSID = session.SSID
+
+	// ESID ends a session
+	ESID = session.ESID
+)
+
+// Authentication commands
+type (
+	// AUCH presents an authentication challenge
+	AUCH = authentication.AUCH
+
+	// AURP answers an authentication challenge
+	AURP = authentication.AURP
+
+	// SECD changes the direction during authentication
+	SECD = authentication.SECD
+)
+
+// Start file commands
+type (
+	// SFID starts the transfer of a virtual file
+	SFID = startfile.SFID
+
+	// SFPA accepts a virtual file
+	SFPA = startfile.SFPA
+
+	// SFNA rejects a virtual file
+	SFNA = startfile.SFNA
+
+	// EERP confirms the delivery of a virtual file (end to end response)
+	EERP = startfile.EERP
+
+	// NERP reports that a virtual file could not be delivered
+	NERP = startfile.NERP
+
+	// RTR acknowledges an EERP or NERP (ready to receive)
+	RTR = startfile.RTR
+)
+
+// Transfer and end file commands
+type (
+	// DATA contains a data exchange buffer
+	DATA = transfer.DATA
+
+	// CDT sets the credit of the speaker
+	CDT = transfer.CDT
+
+	// EFID ends the transfer of a virtual file
+	EFID = endfile.EFID
+
+	// EFPA accepts the transferred virtual file
+	EFPA = endfile.EFPA
+
+	// EFNA rejects the transferred virtual file
+	EFNA = endfile.EFNA
+
+	// CD changes the direction of the session
+	CD = wire.CD
+)
+
+// ParseMessage parses an exchange buffer (without stream transmission header)
+// and returns the message contained together with its name, e.g. "SFID".
+func ParseMessage(buffer []byte) (Message, string, error) {
+	return client.DetermineMessageType(buffer)
+}
@@ -0,0 +1,30 @@
+package oftp2
+
+import (
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+)
+
+// Event is reported to Client.Observer during a session
+type Event = client.Event
+
+// EventKind identifies the kind of an Event
+type EventKind = client.EventKind
+
+// Kinds of events reported to the observer
+const (
+	EventSessionStarted  = client.EventSessionStarted
+	EventFileStarted     = client.EventFileStarted
+	EventProgress        = client.EventProgress
+	EventCreditExhausted = client.EventCreditExhausted
+	EventCreditReceived  = client.EventCreditReceived
+	EventFileSent        = client.EventFileSent
+	EventSessionEnded    = client.EventSessionEnded
+	EventSessionFailed   = client.EventSessionFailed
+	EventFileRejected    = client.EventFileRejected
+	EventFileRefused     = client.EventFileRefused
+	EventFileReceived    = client.EventFileReceived
+	EventReceiptReceived = client.EventReceiptReceived
+)
+
+// TransferStats are the statistics of a single file transfer
+type TransferStats = client.TransferStats
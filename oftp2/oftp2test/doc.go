@@ -0,0 +1,34 @@
+// Package oftp2test provides a scriptable OFTP2 partner for tests of programs
+// that use the oftp2 package.
+//
+// A Partner takes the role of the responder of a session. By default it
+// accepts the session and every file, its behaviour is changed with the
+// functions and fields of the Partner:
+//
+//	partner := &oftp2test.Partner{
+//	    StartFile: func(file oftp2.VirtualFile) oftp2test.Answer {
+//	        if file.DatasetName == "INVALID" {
+//	            return oftp2test.Reject(3, "invalid file")
+//	        }
+//	        return oftp2test.Accept()
+//	    },
+//	    Receipt: func(file oftp2test.File) oftp2test.Answer {
+//	        return oftp2test.Accept()
+//	    },
+//	}
+//
+//	c := partner.Client("O0013000000CLIENT")
+//	s, err := c.Open(ctx, "", false, false, false)
+//	...
+//
+// The partner is reached in memory with the Dialer of the client returned by
+// Partner.Client or Partner.Dialer, or over the network with
+// Partner.ServeListener. It checks the commands of the client against the
+// state tables of the protocol and aborts the session with ESID reason 02 on a
+// protocol violation. Unknown commands and session parameters out of the
+// limits of the RFC end the session with the matching ESID reason as well.
+//
+// After the client handed over the right to speak with Session.ReceiveFiles,
+// the partner sends the files listed in Partner.Files and the end to end
+// responses for the files it received, and hands the right to speak back.
+package oftp2test
@@ -0,0 +1,46 @@
+package oftp2test_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/thomsmits/oftp2-client/oftp2"
+	"github.com/thomsmits/oftp2-client/oftp2/oftp2test"
+)
+
+func ExamplePartner() {
+	partner := &oftp2test.Partner{
+		StartFile: func(file oftp2.VirtualFile) oftp2test.Answer {
+			if file.DatasetName == "UNKNOWN" {
+				return oftp2test.Reject(3, "unknown file")
+			}
+			return oftp2test.Accept()
+		},
+	}
+
+	ctx := context.Background()
+	s, err := partner.Client("O0013000000CLIENT").Open(ctx, "", false, false, false)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, name := range []string{"UNKNOWN", "DELFOR"} {
+		file := oftp2.VirtualFile{DatasetName: name, Destination: oftp2test.DefaultOdetteId}
+		err = s.SendStream(ctx, file, bytes.NewReader([]byte("content")), 7)
+		fmt.Println(name, err)
+	}
+
+	_ = s.End(ctx)
+	_ = partner.Wait()
+
+	for _, file := range partner.Received() {
+		fmt.Printf("received %s: %s\n", file.File.DatasetName, file.Data)
+	}
+
+	// Output:
+	// UNKNOWN partner does not accept file UNKNOWN: SFNA reason 03 (Invalid origin.), retry false: unknown file
+	// DELFOR <nil>
+	// received DELFOR: content
+}
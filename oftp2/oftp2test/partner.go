@@ -0,0 +1,281 @@
+package oftp2test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/oftp2"
+)
+
+// Defaults of the session parameters of a Partner
+const (
+	DefaultOdetteId   = "O0013000000PARTNER"
+	DefaultBufferSize = 4096
+	DefaultCredit     = 8
+)
+
+// Answer tells the partner how to answer a command of the client. The zero
+// value accepts the command.
+type Answer struct {
+	// ReasonCode rejects the command with an SFNA, EFNA, NERP or ESID of this
+	// reason code, depending on the command answered. 0 accepts the command.
+	ReasonCode int
+
+	// ReasonText is sent with a rejection
+	ReasonText string
+
+	// Retry sets the retry indicator of an SFNA
+	Retry bool
+
+	// Delay is waited before the answer is sent, in addition to Partner.Delay
+	Delay time.Duration
+
+	// RestartPosition is sent as answer count in the SFPA. A position greater
+	// than the one requested by the client asks it to restart the file.
+	RestartPosition uint64
+
+	// ChangeDirection requests the right to speak in the EFPA. The client has
+	// to answer with a CD, afterwards the partner sends its files and end to
+	// end responses.
+	ChangeDirection bool
+
+	// EndSession ends the session with an ESID instead of answering the
+	// command. The ESID reason is given by ReasonCode.
+	EndSession bool
+}
+
+// Accept returns an Answer accepting the command
+func Accept() Answer {
+	return Answer{}
+}
+
+// Reject returns an Answer rejecting the command with the reason code
+func Reject(reasonCode int, reasonText string) Answer {
+	return Answer{ReasonCode: reasonCode, ReasonText: reasonText}
+}
+
+// EndSession returns an Answer ending the session with an ESID of the reason
+// code instead of answering the command
+func EndSession(reasonCode int, reasonText string) Answer {
+	return Answer{ReasonCode: reasonCode, ReasonText: reasonText, EndSession: true}
+}
+
+// File is a virtual file with its content, received from the client or sent to
+// it
+type File struct {
+	File oftp2.VirtualFile
+	Data []byte
+}
+
+// Partner is a scriptable OFTP2 partner. The fields must not be changed while
+// a session is running. A Partner can serve several sessions, also at the same
+// time.
+type Partner struct {
+	// OdetteId of the partner, DefaultOdetteId if empty
+	OdetteId string
+
+	// Password sent by the partner in its SSID
+	Password string
+
+	// ExpectedPassword is the password the client has to send. If it is not
+	// empty and the client sends another one, the session is ended with ESID
+	// reason 04.
+	ExpectedPassword string
+
+	// BufferSize and Credit are offered in the SSID, DefaultBufferSize and
+	// DefaultCredit are used if they are zero
+	BufferSize uint32
+	Credit     uint32
+
+	// Restart and Compression are offered in the SSID
+	Restart     bool
+	Compression bool
+
+	// Delay is waited before every answer of the partner
+	Delay time.Duration
+
+	// Session answers the SSID of the client. If it is nil, every session is
+	// accepted.
+	Session func(ssid *oftp2.SSID) Answer
+
+	// StartFile answers the SFID of a file with an SFPA or SFNA. If it is nil,
+	// every file is accepted.
+	StartFile func(file oftp2.VirtualFile) Answer
+
+	// EndFile answers the EFID of a file with an EFPA or EFNA. If it is nil,
+	// every file is accepted.
+	EndFile func(file File) Answer
+
+	// Receipt decides on the end to end response for a file accepted with an
+	// EFPA, it is sent as EERP or NERP when the partner becomes speaker. If it
+	// is nil, no end to end responses are sent.
+	Receipt func(file File) Answer
+
+	// Files are sent to the client when it hands over the right to speak. Each
+	// file is sent once, but not to a client that announced the capability S
+	// (send only) in its SSID.
+	Files []File
+
+	// EndAfterSpeaking ends the session with an ESID after the partner sent
+	// its files and end to end responses, instead of handing back the right to
+	// speak with a CD
+	EndAfterSpeaking bool
+
+	// DropAfterSpeaking closes the connection without ending the session
+	// after the partner sent its files and end to end responses, like a
+	// broken connection
+	DropAfterSpeaking bool
+
+	mutex    sync.Mutex
+	sessions sync.WaitGroup
+	sent     int
+	received []File
+	receipts []oftp2.Receipt
+	err      error
+}
+
+// Client returns a client for the given Odette ID that reaches the partner in
+// memory
+func (p *Partner) Client(odetteId string) *oftp2.Client {
+	return &oftp2.Client{
+		ServerHost: "oftp2test",
+		ServerPort: 3305,
+		OdetteId:   odetteId,
+		Dialer:     p.Dialer(),
+	}
+}
+
+// Dialer returns a function to be used as oftp2.Client.Dialer. Each call
+// creates an in-memory connection and serves a session on it.
+func (p *Partner) Dialer() func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		local, remote := net.Pipe()
+		p.serveAsync(remote)
+		return local, nil
+	}
+}
+
+// ServeListener serves a session on each connection accepted by the listener,
+// until the listener is closed. The error of Accept is returned.
+func (p *Partner) ServeListener(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		p.serveAsync(conn)
+	}
+}
+
+// serveAsync serves a session on the connection in a new goroutine
+func (p *Partner) serveAsync(conn net.Conn) {
+	p.sessions.Add(1)
+	go func() {
+		defer p.sessions.Done()
+		_ = p.Serve(conn)
+	}()
+}
+
+// Serve runs a session as responder on the connection and closes it
+// afterwards. It returns nil if the session was ended properly with an ESID
+// of reason 00.
+func (p *Partner) Serve(conn net.Conn) error {
+	defer conn.Close()
+
+	s := newSession(p, conn)
+	err := s.run()
+	close(s.done)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+
+	return err
+}
+
+// Wait waits until all sessions started with the Dialer or ServeListener are
+// finished and returns the first error of a session
+func (p *Partner) Wait() error {
+	p.sessions.Wait()
+	return p.Err()
+}
+
+// Err returns the first error of a session, e.g. a protocol violation of the
+// client
+func (p *Partner) Err() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.err
+}
+
+// Received returns the files accepted from the client
+func (p *Partner) Received() []File {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]File(nil), p.received...)
+}
+
+// Receipts returns the end to end responses received from the client
+func (p *Partner) Receipts() []oftp2.Receipt {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]oftp2.Receipt(nil), p.receipts...)
+}
+
+// odetteId returns the Odette ID of the partner
+func (p *Partner) odetteId() string {
+	if p.OdetteId != "" {
+		return p.OdetteId
+	}
+	return DefaultOdetteId
+}
+
+// bufferSize returns the buffer size offered by the partner
+func (p *Partner) bufferSize() uint32 {
+	if p.BufferSize > 0 {
+		return p.BufferSize
+	}
+	return DefaultBufferSize
+}
+
+// credit returns the credit offered by the partner
+func (p *Partner) credit() uint32 {
+	if p.Credit > 0 {
+		return p.Credit
+	}
+	return DefaultCredit
+}
+
+// nextFile returns the next file to send to the client
+func (p *Partner) nextFile() (File, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.sent >= len(p.Files) {
+		return File{}, false
+	}
+	p.sent++
+	return p.Files[p.sent-1], true
+}
+
+// addReceived records a file accepted from the client
+func (p *Partner) addReceived(file File) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.received = append(p.received, file)
+}
+
+// addReceipt records an end to end response received from the client
+func (p *Partner) addReceipt(receipt oftp2.Receipt) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.receipts = append(p.receipts, receipt)
+}
@@ -0,0 +1,92 @@
+package oftp2test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/protocol"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/transfer"
+)
+
+func TestServeListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	partner := &Partner{}
+	go func() { _ = partner.ServeListener(listener) }()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	c := partner.Client("O0013000000CLIENT")
+	c.Dialer = nil
+	c.ServerHost = addr.IP.String()
+	c.ServerPort = addr.Port
+
+	s, err := c.Open(context.Background(), "", false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.End(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err = partner.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProtocolViolationOfClient(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+
+	partner := &Partner{}
+	result := make(chan error, 1)
+	go func() { result <- partner.Serve(remote) }()
+
+	// DATA without a start file command
+	ssid := session.SSID{Id: "O0013000000CLIENT", BufferSize: 1024, Capability: "S", Credit: 99}
+	data := transfer.DATA{Buffer: []byte{4, 't', 'e', 's', 't'}}
+
+	if _, err := wire.ReadStreamBuffer(local); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = local.Write(wire.EncodeStreamBuffer(ssid.Marshal()))
+	if _, err := wire.ReadStreamBuffer(local); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = local.Write(wire.EncodeStreamBuffer(data.Marshal()))
+
+	buffer, err := wire.ReadStreamBuffer(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	esid := session.ESID{}
+	if err = esid.Parse(buffer); err != nil || esid.ReasonCode != 2 {
+		t.Errorf("expected ESID 02, got %+v (%v)", esid, err)
+	}
+
+	var violation *protocol.ViolationError
+	if err = <-result; !errors.As(err, &violation) || violation.Command != "DATA" {
+		t.Errorf("expected protocol violation, got %v", err)
+	}
+}
+
+func TestDataBuffers(t *testing.T) {
+	data := make([]byte, 200)
+	buffers := dataBuffers(data, 129)
+
+	// 126 bytes fit into two sub records of a 129 byte buffer
+	if len(buffers) != 2 || len(buffers[0]) != 128 || len(buffers[1]) != 76 {
+		t.Fatalf("wrong buffers: %d", len(buffers))
+	}
+
+	subRecords, err := transfer.ParseSubRecords(buffers[1])
+	if err != nil || len(subRecords) != 2 || !subRecords[1].EndOfRecord || subRecords[0].EndOfRecord {
+		t.Errorf("wrong sub records %+v (%v)", subRecords, err)
+	}
+}
@@ -0,0 +1,565 @@
+package oftp2test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/client"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/protocol"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/endfile"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/startfile"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/transfer"
+	"github.com/thomsmits/oftp2-client/oftp2"
+)
+
+// ESID reason codes used by the partner
+const (
+	esidReasonNormal               = 0
+	esidReasonCommandNotRecognised = 1
+	esidReasonProtocolViolation    = 2
+	esidReasonInvalidPassword      = 4
+	esidReasonInvalidData          = 6
+	esidReasonBufferSize           = 7
+)
+
+// Limits of the session parameters offered by the client
+const (
+	minBufferSize = 128
+	maxBufferSize = 99999
+)
+
+// maxSubRecordLength is the maximum length of a sub record of a DATA buffer
+const maxSubRecordLength = 63
+
+// errSessionEnded, errConnectionDropped and errClientEnded are returned
+// internally when the session was ended or dropped as scripted or by the
+// client with ESID reason 00
+var (
+	errSessionEnded      = errors.New("session ended by partner")
+	errConnectionDropped = errors.New("connection dropped by partner")
+	errClientEnded       = errors.New("session ended by client")
+)
+
+// readResult is a buffer read from the connection or the error of the read
+type readResult struct {
+	buffer []byte
+	err    error
+}
+
+// partnerSession is a single session of a Partner
+type partnerSession struct {
+	p          *Partner
+	conn       net.Conn
+	buffers    chan readResult // buffers read from the connection
+	done       chan struct{}   // closed when the session is finished
+	machine    *protocol.Machine
+	clientId   string // Odette ID of the client
+	sendOnly   bool   // the client announced that it does not receive files
+	bufferSize uint32 // negotiated buffer size
+	credit     uint32 // negotiated credit
+	window     uint32 // DATA buffers left in the credit window
+	accepted   []File // files accepted in the session, waiting for their end to end response
+}
+
+func newSession(p *Partner, conn net.Conn) *partnerSession {
+	s := &partnerSession{
+		p:       p,
+		conn:    conn,
+		buffers: make(chan readResult, 16),
+		done:    make(chan struct{}),
+		machine: protocol.NewMachine(protocol.Responder),
+	}
+
+	// the connection is read all the time, so the client is never blocked
+	// writing while the partner delays an answer
+	go s.readLoop()
+
+	return s
+}
+
+// readLoop reads the buffers of the client until the connection fails or is
+// closed
+func (s *partnerSession) readLoop() {
+	defer close(s.buffers)
+
+	for {
+		buffer, err := wire.ReadStreamBuffer(s.conn)
+		select {
+		case s.buffers <- readResult{buffer: buffer, err: err}:
+		case <-s.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// run runs the session until it is ended
+func (s *partnerSession) run() error {
+	ssrm := session.SSRM{}
+	err := s.send(ssrm.Marshal())
+	if err != nil {
+		return err
+	}
+
+	err = s.startSession()
+	if err == nil {
+		err = s.listen()
+	}
+
+	if err == errSessionEnded || err == errConnectionDropped || err == errClientEnded {
+		return nil
+	}
+	return err
+}
+
+// startSession answers the SSID of the client
+func (s *partnerSession) startSession() error {
+	answer, t, err := s.read()
+	if err != nil {
+		return err
+	}
+	if t != "SSID" {
+		return s.endSession(esidReasonProtocolViolation, fmt.Sprintf("expected SSID, got %s", t))
+	}
+
+	ssid := answer.(*session.SSID)
+	s.clientId = ssid.Id
+	s.sendOnly = ssid.Capability == "S"
+	s.bufferSize = minUint32(s.p.bufferSize(), ssid.BufferSize)
+	s.credit = minUint32(s.p.credit(), ssid.Credit)
+
+	result := Accept()
+	if s.p.Session != nil {
+		result = s.p.Session(ssid)
+	}
+	if result.ReasonCode == 0 {
+		result = s.checkSSID(ssid)
+	}
+
+	s.delay(result)
+	if result.ReasonCode != 0 || result.EndSession {
+		return s.endSession(result.ReasonCode, result.ReasonText)
+	}
+
+	answerSSID := session.SSID{
+		Id:         s.p.odetteId(),
+		Password:   s.p.Password,
+		BufferSize: s.bufferSize,
+		Capability: "B",
+		Compress:   s.p.Compression && ssid.Compress,
+		Restart:    s.p.Restart && ssid.Restart,
+		Credit:     s.credit,
+	}
+	return s.send(answerSSID.Marshal())
+}
+
+// checkSSID checks the parameters offered by the client
+func (s *partnerSession) checkSSID(ssid *session.SSID) Answer {
+	switch {
+	case s.p.ExpectedPassword != "" && ssid.Password != s.p.ExpectedPassword:
+		return EndSession(esidReasonInvalidPassword, "Invalid password")
+	case ssid.BufferSize < minBufferSize || ssid.BufferSize > maxBufferSize:
+		return EndSession(esidReasonBufferSize, fmt.Sprintf("Invalid buffer size %d", ssid.BufferSize))
+	case ssid.Credit == 0:
+		return EndSession(esidReasonInvalidData, "Invalid credit 0")
+	}
+	return Accept()
+}
+
+// listen handles the commands of the client while it is the speaker
+func (s *partnerSession) listen() error {
+	var current *File
+
+	for {
+		answer, t, err := s.read()
+		if err != nil {
+			return err
+		}
+
+		switch t {
+		case "SFID":
+			current, err = s.startFile(answer.(*startfile.SFID))
+
+		case "DATA":
+			err = s.data(current, answer.(*transfer.DATA))
+
+		case "EFID":
+			err = s.endFile(*current, answer.(*endfile.EFID))
+			current = nil
+
+		case "EERP", "NERP":
+			s.p.addReceipt(client.ReceiptFrom(answer))
+			rtr := startfile.RTR{}
+			err = s.send(rtr.Marshal())
+
+		case "CD":
+			err = s.speak()
+
+		default:
+			return s.endSession(esidReasonProtocolViolation, fmt.Sprintf("unexpected %s", t))
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// startFile answers the SFID of the client. The file to receive is returned,
+// nil if it was rejected.
+func (s *partnerSession) startFile(sfid *startfile.SFID) (*File, error) {
+	file := client.VirtualFileFromSFID(sfid)
+
+	result := Accept()
+	if s.p.StartFile != nil {
+		result = s.p.StartFile(file)
+	}
+
+	s.delay(result)
+	if result.EndSession {
+		return nil, s.endSession(result.ReasonCode, result.ReasonText)
+	}
+
+	if result.ReasonCode != 0 {
+		sfna := startfile.SFNA{ReasonCode: result.ReasonCode, RetryIndicator: result.Retry, ReasonText: result.ReasonText}
+		return nil, s.send(sfna.Marshal())
+	}
+
+	s.window = s.credit
+	sfpa := startfile.SFPA{AnswerCount: result.RestartPosition}
+	return &File{File: file, Data: make([]byte, 0)}, s.send(sfpa.Marshal())
+}
+
+// data appends the content of a DATA buffer to the file and grants new credit
+// when the window is used up
+func (s *partnerSession) data(file *File, data *transfer.DATA) error {
+	subRecords, err := transfer.ParseSubRecords(data.Buffer)
+	if err != nil {
+		return s.endSession(esidReasonProtocolViolation, err.Error())
+	}
+
+	for _, subRecord := range subRecords {
+		file.Data = append(file.Data, subRecord.Expand()...)
+	}
+
+	s.window--
+	return s.grantCredit()
+}
+
+// endFile answers the EFID of the client
+func (s *partnerSession) endFile(file File, efid *endfile.EFID) error {
+	result := Accept()
+	if efid.UnitCount != uint64(len(file.Data)) {
+		result = Reject(11, fmt.Sprintf("received %d bytes", len(file.Data)))
+	} else if s.p.EndFile != nil {
+		result = s.p.EndFile(file)
+	}
+
+	s.delay(result)
+	if result.EndSession {
+		return s.endSession(result.ReasonCode, result.ReasonText)
+	}
+
+	if result.ReasonCode != 0 {
+		efna := endfile.EFNA{ReasonCode: result.ReasonCode, AnswerText: result.ReasonText}
+		return s.send(efna.Marshal())
+	}
+
+	s.p.addReceived(file)
+	s.accepted = append(s.accepted, file)
+
+	efpa := endfile.EFPA{ChangeDirection: result.ChangeDirection}
+	return s.send(efpa.Marshal())
+}
+
+// speak sends the files and end to end responses of the partner and hands
+// the right to speak back to the client afterwards. Like a responder following
+// RFC 5024, the partner does not send files to a client that announced it only
+// sends files.
+func (s *partnerSession) speak() error {
+	for !s.sendOnly {
+		file, ok := s.p.nextFile()
+		if !ok {
+			break
+		}
+
+		err := s.sendFile(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, file := range s.accepted {
+		if s.p.Receipt == nil {
+			break
+		}
+
+		err := s.sendReceipt(file, s.p.Receipt(file))
+		if err != nil {
+			return err
+		}
+	}
+	s.accepted = s.accepted[:0]
+
+	if s.p.DropAfterSpeaking {
+		return errConnectionDropped
+	}
+
+	if s.p.EndAfterSpeaking {
+		return s.endSession(esidReasonNormal, "OK")
+	}
+
+	cd := wire.CD{}
+	return s.send(cd.Marshal())
+}
+
+// sendFile sends a file to the client. A rejection of the client is not an
+// error.
+func (s *partnerSession) sendFile(file File) error {
+	sfid := s.sfid(file.File, len(file.Data))
+	err := s.send(sfid.Marshal())
+	if err != nil {
+		return err
+	}
+
+	_, t, err := s.read()
+	if err != nil {
+		return err
+	}
+	if t == "SFNA" {
+		return nil
+	}
+
+	s.window = s.credit
+	for _, buffer := range dataBuffers(file.Data, int(s.bufferSize)) {
+		data := transfer.DATA{Buffer: buffer}
+		err = s.send(data.Marshal())
+		if err != nil {
+			return err
+		}
+
+		s.window--
+		if s.window == 0 {
+			// wait for the CDT of the client
+			if _, _, err = s.read(); err != nil {
+				return err
+			}
+			s.window = s.credit
+		}
+	}
+
+	efid := endfile.EFID{UnitCount: uint64(len(file.Data))}
+	err = s.send(efid.Marshal())
+	if err != nil {
+		return err
+	}
+
+	_, _, err = s.read()
+	return err
+}
+
+// sendReceipt sends an EERP or NERP for the file and waits for the RTR
+func (s *partnerSession) sendReceipt(file File, result Answer) error {
+	s.delay(result)
+
+	var buffer []byte
+	if result.ReasonCode == 0 {
+		eerp := startfile.EERP{
+			VirtualDataSetName: file.File.DatasetName,
+			VirtualFileDate:    file.File.DateTime,
+			UserData:           file.File.UserData,
+			Destination:        file.File.Originator,
+			Originator:         file.File.Destination,
+		}
+		buffer = eerp.Marshal()
+	} else {
+		nerp := startfile.NERP{
+			VirtualDataSetName: file.File.DatasetName,
+			VirtualFileDate:    file.File.DateTime,
+			Destination:        file.File.Originator,
+			Originator:         file.File.Destination,
+			CreatorOfNERP:      s.p.odetteId(),
+			ReasonCode:         result.ReasonCode,
+			ReasonText:         result.ReasonText,
+		}
+		buffer = nerp.Marshal()
+	}
+
+	err := s.send(buffer)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = s.read()
+	return err
+}
+
+// sfid builds the start file command for a file sent by the partner
+func (s *partnerSession) sfid(file oftp2.VirtualFile, size int) startfile.SFID {
+	dateTime := file.DateTime
+	if dateTime.IsZero() {
+		dateTime = time.Now()
+	}
+	destination := file.Destination
+	if destination == "" {
+		destination = s.clientId
+	}
+	originator := file.Originator
+	if originator == "" {
+		originator = s.p.odetteId()
+	}
+	format := file.Format
+	if format == "" {
+		format = oftp2.FileFormatUnstructured
+	}
+
+	return startfile.SFID{
+		DatasetName:         file.DatasetName,
+		FileDateTime:        dateTime,
+		UserData:            file.UserData,
+		Destination:         destination,
+		Originator:          originator,
+		FileFormat:          string(format),
+		MaxRecordSize:       file.MaxRecordSize,
+		FileSizeInK:         uint64(size+1023) / 1024,
+		OriginalFileSizeInK: uint64(size+1023) / 1024,
+		SecurityLevel:       int(file.SecurityLevel),
+		CipherSuite:         file.CipherSuite,
+		SigningRequired:     file.SignedEERP,
+	}
+}
+
+// grantCredit sends a CDT when the client used up its credit
+func (s *partnerSession) grantCredit() error {
+	if s.window > 0 {
+		return nil
+	}
+
+	s.window = s.credit
+	cdt := transfer.CDT{}
+	return s.send(cdt.Marshal())
+}
+
+// read reads the next command of the client and checks it against the state
+// of the session. An ESID of the client ends the session with errClientEnded
+// or an error describing the abort.
+func (s *partnerSession) read() (wire.Protocol, string, error) {
+	result, ok := <-s.buffers
+	if !ok {
+		return nil, "", io.ErrUnexpectedEOF
+	}
+	if result.err != nil {
+		return nil, "", result.err
+	}
+	buffer := result.buffer
+
+	answer, t, err := client.DetermineMessageType(buffer)
+	if err != nil {
+		_ = s.endSession(esidReasonCommandNotRecognised, "Command not recognised")
+		return nil, "", err
+	}
+
+	err = s.machine.Receive(machineCommand(answer, t))
+	if err != nil {
+		_ = s.endSession(esidReasonProtocolViolation, "Protocol violation")
+		return nil, "", err
+	}
+
+	if esid, ok := answer.(*session.ESID); ok {
+		if esid.ReasonCode != esidReasonNormal {
+			return nil, "", errors.New(fmt.Sprintf("client aborted session: ESID reason %02d: %s", esid.ReasonCode, esid.ReasonText))
+		}
+		return nil, "", errClientEnded
+	}
+
+	return answer, t, nil
+}
+
+// send checks the command against the state of the session and sends it
+func (s *partnerSession) send(buffer []byte) error {
+	command, t, err := client.DetermineMessageType(buffer)
+	if err != nil {
+		return err
+	}
+	if err = s.machine.Send(machineCommand(command, t)); err != nil {
+		return err
+	}
+
+	_, err = s.conn.Write(wire.EncodeStreamBuffer(buffer))
+	return err
+}
+
+// machineCommand returns the name of the command for the state machine, an
+// EFPA requesting the right to speak and an SSID requesting secure
+// authentication have names of their own
+func machineCommand(command wire.Protocol, t string) string {
+	if efpa, ok := command.(*endfile.EFPA); ok && efpa.ChangeDirection {
+		return protocol.EFPAChangeDirection
+	}
+	if ssid, ok := command.(*session.SSID); ok && ssid.Authentication {
+		return protocol.SSIDAuthentication
+	}
+	return t
+}
+
+// endSession sends an ESID and returns errSessionEnded
+func (s *partnerSession) endSession(reasonCode int, reasonText string) error {
+	esid := session.ESID{ReasonCode: reasonCode, ReasonText: reasonText}
+	_, _ = s.conn.Write(wire.EncodeStreamBuffer(esid.Marshal()))
+	s.machine.Close()
+	return errSessionEnded
+}
+
+// delay waits before an answer is sent
+func (s *partnerSession) delay(answer Answer) {
+	time.Sleep(s.p.Delay + answer.Delay)
+}
+
+// dataBuffers splits the data into the payloads of DATA buffers of at most
+// the given size, without the command octet
+func dataBuffers(data []byte, bufferSize int) [][]byte {
+	perBuffer := (bufferSize - 1) / (maxSubRecordLength + 1) * maxSubRecordLength
+	result := make([][]byte, 0)
+
+	for start := 0; start < len(data); start += perBuffer {
+		end := start + perBuffer
+		if end > len(data) {
+			end = len(data)
+		}
+
+		buffer := make([]byte, 0, bufferSize)
+		for pos := start; pos < end; pos += maxSubRecordLength {
+			length := end - pos
+			if length > maxSubRecordLength {
+				length = maxSubRecordLength
+			}
+
+			header := byte(length)
+			if pos+length == len(data) {
+				// end of record flag on the last sub record of the file
+				header |= 0x80
+			}
+
+			buffer = append(buffer, header)
+			buffer = append(buffer, data[pos:pos+length]...)
+		}
+		result = append(result, buffer)
+	}
+
+	return result
+}
+
+// minUint32 returns the smaller of the values
+func minUint32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,30 @@
+package oftp2
+
+import (
+	"io"
+
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/trace"
+	"github.com/thomsmits/oftp2-client/internal/liboftp2/wire/session"
+)
+
+// TraceRecorder records the exchange buffers of a session (see Client.Trace)
+type TraceRecorder = trace.Recorder
+
+// TraceRecord is a single exchange buffer of a trace
+type TraceRecord = trace.Record
+
+// Replayer plays the partner of a recorded session
+type Replayer = trace.Replayer
+
+// NewTraceRecorder creates a recorder writing a trace in JSON lines format to
+// w. Passwords of SSID commands are not written to the trace.
+func NewTraceRecorder(w io.Writer) *TraceRecorder {
+	r := trace.NewRecorder(w)
+	r.Redact = session.RedactPassword
+	return r
+}
+
+// ReadTrace reads all records of a trace written by a TraceRecorder.
+func ReadTrace(r io.Reader) ([]TraceRecord, error) {
+	return trace.ReadTrace(r)
+}
@@ -0,0 +1,4 @@
+package oftp2
+
+// Version of the library, following semantic versioning
+const Version = "0.1.0"